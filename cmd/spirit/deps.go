@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/issadarkthing/spirit/deps"
+)
+
+const depsUsage = `usage: spirit deps <command>
+
+Commands:
+  resolve  print the dependencies resolved from their sources
+  lock     resolve the dependencies and pin them in ` + deps.LockFile + `
+  vendor   copy the locked dependencies into ` + deps.VendorDir + `
+`
+
+// runDeps runs a deps subcommand against the project of the working
+// directory and returns the exit code.
+func runDeps(args []string) int {
+	fs := flag.NewFlagSet("deps", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, depsUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	project, err := deps.FindProject(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	} else if project == nil {
+		fmt.Fprintf(os.Stderr, "error: no %s found\n", deps.ManifestFile)
+		return 1
+	}
+
+	var (
+		res  *deps.Resolution
+		lock *deps.Lock
+	)
+
+	switch fs.Arg(0) {
+	case "resolve":
+		if res, err = project.Resolve(); err == nil {
+			printConflicts(res)
+			printDeps(project, res.Deps)
+		}
+
+	case "lock":
+		if res, err = project.Lock(); err == nil {
+			printConflicts(res)
+			fmt.Printf("locked %d dependencies in %s\n", len(res.Deps), deps.LockFile)
+		}
+
+	case "vendor":
+		if lock, err = project.Vendor(); err == nil {
+			printDeps(project, lock.Deps)
+		}
+
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func printDeps(project *deps.Project, resolved []deps.Resolved) {
+	for _, r := range resolved {
+		version := r.Version
+		if version == "" {
+			version = "-"
+		}
+
+		via := ""
+		if r.Parent != "" {
+			via = " (via " + r.Parent + ")"
+		}
+
+		source := "local " + relTo(project.Dir, r.Local)
+		if r.Git != "" {
+			source = fmt.Sprintf("git %s@%.12s", relTo(project.Dir, r.Git), r.Rev)
+		}
+
+		fmt.Printf("%s %s %s%s\n", r.Name, version, source, via)
+	}
+}
+
+func printConflicts(res *deps.Resolution) {
+	for _, conflict := range res.Conflicts {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", conflict)
+	}
+}
+
+// projectPath returns the search path of the project the file belongs to,
+// or the project of the working directory if there is no file.
+func projectPath(file string) ([]string, error) {
+	dir := "."
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+
+	project, err := deps.FindProject(dir)
+	if err != nil || project == nil {
+		return nil, err
+	}
+
+	return project.SearchPath()
+}
+
+func relTo(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return rel
+	}
+	return path
+}
@@ -5,11 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 
-	"github.com/issadarkthing/spirit/internal"
-	"github.com/issadarkthing/spirit/internal/repl"
+	"github.com/issadarkthing/spirit/repl"
+	"github.com/issadarkthing/spirit/spirit"
 )
 
 const (
@@ -17,7 +19,8 @@ const (
 Visit https://github.com/issadarkthing/spirit for more.`
 	prompt    = " λ >>"
 	multiline = "|"
-	stdpath   = "/.local/lib/spirit/core.st"
+	coreEnv   = "SPIRIT_CORE"
+	pathEnv   = "SPIRIT_PATH"
 )
 
 var (
@@ -26,15 +29,32 @@ var (
 
 	executeStr   = flag.String("e", "", "Execute string")
 	unload       = flag.Bool("u", false, "Unload core library")
+	corePath     = flag.String("core", os.Getenv(coreEnv), "Load core library from path instead of the bundled one")
 	preload      = flag.String("p", "", "Pre-loads file")
 	printVersion = flag.Bool("v", false, "Prints spirit version and exit")
 	memProfile   = flag.String("memprofile", "", "memory profiling")
 	cpuProfile   = flag.String("cpuprofile", "", "cpu profiling")
 )
 
+// includes collects the directories of repeated -I flags.
+type includes []string
+
+func (i *includes) String() string { return strings.Join(*i, string(filepath.ListSeparator)) }
+
+func (i *includes) Set(dir string) error {
+	*i = append(*i, dir)
+	return nil
+}
+
 func main() {
+	var include includes
+	flag.Var(&include, "I", "Add directory to the search path of imports, may be repeated")
 	flag.Parse()
 
+	if flag.Arg(0) == "deps" {
+		os.Exit(runDeps(flag.Args()[1:]))
+	}
+
 	if *printVersion {
 		fmt.Println(version)
 		return
@@ -50,29 +70,35 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	sp := internal.NewSpirit()
-	sp.BindGo("*version*", version)
-
-	var result internal.Value
-	var err error
+	var opts []spirit.Option
+	// do not load standard library
+	if *unload {
+		opts = append(opts, spirit.WithoutCore())
+	} else if *corePath != "" {
+		opts = append(opts, spirit.WithCorePath(*corePath))
+	}
 
-	home, err := os.UserHomeDir()
+	// -I directories are searched before the ones of the project and then
+	// the ones of SPIRIT_PATH
+	paths := []string(include)
+	dirs, err := projectPath(flag.Arg(0))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 	}
+	paths = append(paths, dirs...)
+	if env := os.Getenv(pathEnv); env != "" {
+		paths = append(paths, filepath.SplitList(env)...)
+	}
+	opts = append(opts, spirit.WithSearchPath(paths...))
 
-	libPath := home + stdpath
-
-	core, err := os.Open(libPath)
+	sp, err := spirit.NewSpirit(opts...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
 	}
-	defer core.Close()
+	sp.BindGo("*version*", version)
 
-	// do not load standard library
-	if !*unload {
-		_, err = sp.ReadEval(core)
-	}
+	var result spirit.Value
 
 	// pre-load file
 	if *preload != "" {
@@ -88,7 +114,7 @@ func main() {
 		}
 	}
 
-	sp.SwitchNS(internal.Symbol{Value: "user"})
+	sp.SwitchNS(spirit.NewSymbol(spirit.DefaultNS))
 
 	if len(flag.Args()) > 0 {
 
@@ -0,0 +1,245 @@
+package deps_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/issadarkthing/spirit/deps"
+)
+
+func TestParseManifest(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name    string
+		src     string
+		want    *deps.Manifest
+		wantErr bool
+	}{
+		{
+			name: "Full",
+			src: `{:name "acme/app" :version "1.0.0" :paths ["src" "lib/"]
+				   :deps {b {:git "../b" :rev "v1"} a {:local "../a"}}}`,
+			want: &deps.Manifest{
+				Name:    "acme/app",
+				Version: "1.0.0",
+				Paths:   []string{"src", "lib"},
+				Deps: []deps.Dep{
+					{Name: "a", Local: "../a"},
+					{Name: "b", Git: "../b", Rev: "v1"},
+				},
+			},
+		},
+		{
+			name: "DefaultPaths",
+			src:  `{:name "acme/app"}`,
+			want: &deps.Manifest{Name: "acme/app", Paths: []string{"."}},
+		},
+		{
+			name:    "NotHashMap",
+			src:     `[:name "acme/app"]`,
+			wantErr: true,
+		},
+		{
+			name:    "PathOutside",
+			src:     `{:paths ["../src"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "NoSource",
+			src:     `{:deps {a {:rev "v1"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "LocalRev",
+			src:     `{:deps {a {:local "../a" :rev "v1"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidName",
+			src:     `{:deps {../a {:local "../a"}}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deps.ParseManifest([]byte(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseManifest() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProject_Vendor(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/spirit.edn":    `{:paths ["src"] :deps {acme.b {:local "../b"}}}`,
+		"b/spirit.edn":      `{:version "1.0.0" :paths ["src"] :deps {acme.c {:local "../c"}}}`,
+		"b/src/acme/b.st":   `(ns acme.b)`,
+		"c/acme/c.st":       `(ns acme.c)`,
+		"c/.git/HEAD":       `ignored`,
+		"c/.spirit/deps/x":  `ignored`,
+		"app/src/app/a.st":  `(ns app.a)`,
+		"app/src/other.txt": `not a module`,
+	})
+
+	project, err := deps.FindProject(filepath.Join(root, "app", "src", "app"))
+	if err != nil || project == nil {
+		t.Fatalf("FindProject() got = %v, %v", project, err)
+	}
+
+	if _, err := project.SearchPath(); err == nil {
+		t.Errorf("SearchPath() expected error for unlocked dependencies")
+	}
+
+	lock, err := project.Vendor()
+	if err != nil {
+		t.Fatalf("Vendor() unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, r := range lock.Deps {
+		names = append(names, r.Name+"@"+r.Version+" via "+r.Parent)
+	}
+	if want := []string{"acme.b@1.0.0 via ", "acme.c@ via acme.b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Vendor() got = %v, want %v", names, want)
+	}
+
+	for _, file := range []string{"acme.b/src/acme/b.st", "acme.c/acme/c.st"} {
+		if _, err := os.Stat(filepath.Join(project.Dir, deps.VendorDir, file)); err != nil {
+			t.Errorf("Vendor() missing file: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(project.Dir, deps.VendorDir, "acme.c", ".git")); err == nil {
+		t.Errorf("Vendor() copied .git directory")
+	}
+
+	paths, err := project.SearchPath()
+	if err != nil {
+		t.Fatalf("SearchPath() unexpected error: %v", err)
+	}
+	want := []string{
+		filepath.Join(project.Dir, "src"),
+		filepath.Join(project.Dir, deps.VendorDir, "acme.b", "src"),
+		filepath.Join(project.Dir, deps.VendorDir, "acme.c"),
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("SearchPath() got = %v, want %v", paths, want)
+	}
+
+	// a dependency changed since it was locked is not vendored
+	writeFiles(t, root, map[string]string{"c/acme/c.st": `(ns acme.c) (def x 1)`})
+	if _, err := project.Vendor(); err == nil || !strings.Contains(err.Error(), "acme.c") {
+		t.Errorf("Vendor() error = %v, want changed acme.c", err)
+	}
+
+	if _, err := project.Lock(); err != nil {
+		t.Fatalf("Lock() unexpected error: %v", err)
+	}
+	if _, err := project.Vendor(); err != nil {
+		t.Errorf("Vendor() unexpected error after Lock(): %v", err)
+	}
+
+	// the lock is stale once the manifest requires another dependency
+	writeFiles(t, root, map[string]string{
+		"app/spirit.edn": `{:paths ["src"] :deps {acme.b {:local "../b"} acme.c {:local "../c"}}}`,
+	})
+	if project, err = deps.FindProject(filepath.Join(root, "app")); err != nil {
+		t.Fatalf("FindProject() unexpected error: %v", err)
+	}
+	if _, err := project.Vendor(); !errors.Is(err, deps.ErrStale) {
+		t.Errorf("Vendor() error = %v, want %v", err, deps.ErrStale)
+	}
+
+	// imports are not resolved against the stale vendored dependencies
+	paths, err = project.SearchPath()
+	if !errors.Is(err, deps.ErrStale) {
+		t.Errorf("SearchPath() error = %v, want %v", err, deps.ErrStale)
+	}
+	if !reflect.DeepEqual(paths, want[:1]) {
+		t.Errorf("SearchPath() got = %v, want %v", paths, want[:1])
+	}
+}
+
+func TestProject_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/spirit.edn": `{:deps {acme.b {:git "../b" :rev "v1"}}}`,
+		"b/acme/b.st":    `(def version 1)`,
+	})
+
+	gitDo(t, filepath.Join(root, "b"), "init", "-q")
+	gitDo(t, filepath.Join(root, "b"), "add", "-A")
+	gitDo(t, filepath.Join(root, "b"), "commit", "-qm", "v1")
+	gitDo(t, filepath.Join(root, "b"), "tag", "v1")
+	writeFiles(t, root, map[string]string{"b/acme/b.st": `(def version 2)`})
+	gitDo(t, filepath.Join(root, "b"), "commit", "-qam", "v2")
+
+	project, err := deps.FindProject(filepath.Join(root, "app"))
+	if err != nil {
+		t.Fatalf("FindProject() unexpected error: %v", err)
+	}
+
+	if _, err := project.Vendor(); err != nil {
+		t.Fatalf("Vendor() unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(project.Dir, deps.VendorDir, "acme.b", "acme", "b.st"))
+	if err != nil {
+		t.Fatalf("ReadFile() unexpected error: %v", err)
+	}
+	if want := `(def version 1)`; string(got) != want {
+		t.Errorf("Vendor() got = %s, want %s", got, want)
+	}
+
+	// moving the tag makes the lock stale
+	gitDo(t, filepath.Join(root, "b"), "tag", "-f", "v1")
+	if _, err := project.Vendor(); !errors.Is(err, deps.ErrStale) {
+		t.Errorf("Vendor() error = %v, want %v", err, deps.ErrStale)
+	}
+	if _, err := project.SearchPath(); !errors.Is(err, deps.ErrStale) {
+		t.Errorf("SearchPath() error = %v, want %v", err, deps.ErrStale)
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+func gitDo(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
@@ -0,0 +1,172 @@
+package deps
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/issadarkthing/spirit/spirit"
+)
+
+const lockHeader = ";; Generated by `spirit deps lock`, do not edit.\n"
+
+// Lock is the content of a lock file, the dependencies of a project
+// pinned to the exact files they provide.
+type Lock struct {
+	Deps []Resolved
+}
+
+// ReadLock reads the lock file of the project in the directory. Paths of
+// the dependencies are resolved against the directory.
+func ReadLock(dir string) (*Lock, error) {
+	file := filepath.Join(dir, LockFile)
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := parseLock(dir, src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return lock, nil
+}
+
+func parseLock(dir string, src []byte) (*Lock, error) {
+	forms, err := spirit.NewReader(strings.NewReader(string(src))).All()
+	if err != nil {
+		return nil, err
+	}
+
+	mod := forms.(spirit.Module)
+	if len(mod) != 1 {
+		return nil, fmt.Errorf("expecting a single hash map, got %d forms", len(mod))
+	}
+
+	m, ok := spirit.ToGo(mod[0]).(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expecting a hash map, not %v", mod[0])
+	}
+
+	deps, ok := m["deps"].(map[interface{}]interface{})
+	if !ok && m["deps"] != nil {
+		return nil, fmt.Errorf("expecting hash map of :deps, not %v", m["deps"])
+	}
+
+	lock := &Lock{}
+	for name, coord := range deps {
+		r, err := parseLocked(dir, name, coord)
+		if err != nil {
+			return nil, err
+		}
+		lock.Deps = append(lock.Deps, r)
+	}
+
+	sortResolved(lock.Deps)
+	return lock, nil
+}
+
+func parseLocked(dir string, name, coord interface{}) (Resolved, error) {
+	dep, err := parseDep(name, coord)
+	if err != nil {
+		return Resolved{}, err
+	}
+
+	m := coord.(map[interface{}]interface{})
+	r := Resolved{Name: dep.Name, Rev: dep.Rev}
+
+	for key, field := range map[string]*string{
+		"version": &r.Version,
+		"sum":     &r.Sum,
+		"via":     &r.Parent,
+	} {
+		if *field, err = stringOf(m, key); err != nil {
+			return r, fmt.Errorf("dependency %s: %w", r.Name, err)
+		}
+	}
+
+	if r.Paths, err = stringsOf(m, "paths"); err != nil {
+		return r, fmt.Errorf("dependency %s: %w", r.Name, err)
+	}
+
+	switch {
+	case r.Sum == "":
+		return r, fmt.Errorf("dependency %s: missing :sum", r.Name)
+	case dep.Git != "" && r.Rev == "":
+		return r, fmt.Errorf("dependency %s: missing :rev", r.Name)
+	}
+
+	r.Local, r.Git = absPath(dir, dep.Local), absPath(dir, dep.Git)
+	return r, nil
+}
+
+// WriteLock writes the lock file of the project in the directory. Paths of
+// the dependencies are written relative to the directory, so the lock file
+// stays valid if the project and its dependencies are moved together.
+func WriteLock(dir string, lock *Lock) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(lockHeader)
+	buf.WriteString("{:deps\n {")
+	for i, r := range lock.Deps {
+		if i > 0 {
+			buf.WriteString("\n  ")
+		}
+
+		fields := []string{":local " + quote(relPath(dir, r.Local))}
+		if r.Git != "" {
+			fields = []string{
+				":git " + quote(relPath(dir, r.Git)),
+				":rev " + quote(r.Rev),
+			}
+		}
+
+		if r.Version != "" {
+			fields = append(fields, ":version "+quote(r.Version))
+		}
+
+		paths := make([]string, len(r.Paths))
+		for i, path := range r.Paths {
+			paths[i] = quote(filepath.ToSlash(path))
+		}
+		fields = append(fields, ":paths ["+strings.Join(paths, " ")+"]")
+
+		if r.Parent != "" {
+			fields = append(fields, ":via "+quote(r.Parent))
+		}
+		fields = append(fields, ":sum "+quote(r.Sum))
+
+		fmt.Fprintf(&buf, "%s\n  {%s}", r.Name, strings.Join(fields, "\n   "))
+	}
+	buf.WriteString("}}\n")
+
+	return os.WriteFile(filepath.Join(dir, LockFile), buf.Bytes(), 0644)
+}
+
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+func relPath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func absPath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
+
+func sortResolved(deps []Resolved) {
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Name < deps[j].Name
+	})
+}
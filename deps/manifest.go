@@ -0,0 +1,202 @@
+// Package deps resolves, locks and vendors the dependencies of a spirit
+// project. A project is a directory with a spirit.edn manifest:
+//
+//	{:name    "acme/app"
+//	 :version "1.0.0"
+//	 :paths   ["src"]
+//	 :deps    {acme.strings {:local "../strings"}
+//	           acme.http    {:git "/src/acme-http" :rev "v1.2.0"}}}
+//
+// Dependencies are either local directories or git checkouts already on
+// disk, pinned to a revision. Resolved dependencies are recorded in the
+// spirit.lock file next to the manifest and copied into .spirit/deps, whose
+// source paths are added to the search path of imports.
+package deps
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/issadarkthing/spirit/spirit"
+)
+
+const (
+	// ManifestFile is the name of the project manifest.
+	ManifestFile = "spirit.edn"
+	// LockFile is the name of the file dependencies are locked in.
+	LockFile = "spirit.lock"
+	// VendorDir is the directory, relative to the project, dependencies
+	// are vendored into.
+	VendorDir = ".spirit/deps"
+)
+
+// Manifest describes a project and the dependencies it requires.
+type Manifest struct {
+	Name    string
+	Version string
+	// Paths are the source directories of the project relative to the
+	// manifest. Defaults to the directory of the manifest itself.
+	Paths []string
+	Deps  []Dep
+}
+
+// Dep is a dependency declared in a manifest. Exactly one of Local and Git
+// is set.
+type Dep struct {
+	Name string
+	// Local is the directory of the dependency.
+	Local string
+	// Git is the directory of a git checkout of the dependency, Rev is the
+	// revision it is pinned to. An empty Rev pins the current HEAD.
+	Git string
+	Rev string
+}
+
+// ParseManifest reads a manifest from its source. Relative paths of local
+// and git dependencies are kept as they are.
+func ParseManifest(src []byte) (*Manifest, error) {
+	forms, err := spirit.NewReader(strings.NewReader(string(src))).All()
+	if err != nil {
+		return nil, err
+	}
+
+	mod := forms.(spirit.Module)
+	if len(mod) != 1 {
+		return nil, fmt.Errorf("expecting a single hash map, got %d forms", len(mod))
+	}
+
+	hm, ok := mod[0].(*spirit.HashMap)
+	if !ok {
+		return nil, fmt.Errorf("expecting a hash map, not %v", mod[0])
+	}
+	m := spirit.ToGo(hm).(map[interface{}]interface{})
+
+	var mf Manifest
+	if mf.Name, err = stringOf(m, "name"); err != nil {
+		return nil, err
+	}
+	if mf.Version, err = stringOf(m, "version"); err != nil {
+		return nil, err
+	}
+	if mf.Paths, err = stringsOf(m, "paths"); err != nil {
+		return nil, err
+	}
+	if len(mf.Paths) == 0 {
+		mf.Paths = []string{"."}
+	}
+	for _, path := range mf.Paths {
+		if filepath.IsAbs(path) || path == ".." ||
+			strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("path %s is outside of the project", path)
+		}
+	}
+
+	if m["deps"] == nil {
+		return &mf, nil
+	}
+
+	deps, ok := m["deps"].(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expecting hash map of :deps, not %v", m["deps"])
+	}
+
+	for name, coord := range deps {
+		dep, err := parseDep(name, coord)
+		if err != nil {
+			return nil, err
+		}
+		mf.Deps = append(mf.Deps, dep)
+	}
+
+	sort.Slice(mf.Deps, func(i, j int) bool {
+		return mf.Deps[i].Name < mf.Deps[j].Name
+	})
+
+	return &mf, nil
+}
+
+// ReadManifest reads the manifest of the project in the directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	src, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	mf, err := ParseManifest(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, ManifestFile), err)
+	}
+	return mf, nil
+}
+
+func parseDep(name, coord interface{}) (Dep, error) {
+	dep := Dep{}
+
+	var ok bool
+	if dep.Name, ok = name.(string); !ok || dep.Name == "" {
+		return dep, fmt.Errorf("expecting symbol as dependency name, not %v", name)
+	}
+	if clean := filepath.ToSlash(filepath.Clean(dep.Name)); clean != dep.Name ||
+		strings.HasPrefix(clean, "/") || clean == ".." || strings.HasPrefix(clean, "../") {
+		return dep, fmt.Errorf("invalid dependency name %s", dep.Name)
+	}
+
+	m, ok := coord.(map[interface{}]interface{})
+	if !ok {
+		return dep, fmt.Errorf("dependency %s: expecting hash map, not %v", dep.Name, coord)
+	}
+
+	var err error
+	if dep.Local, err = stringOf(m, "local"); err != nil {
+		return dep, fmt.Errorf("dependency %s: %w", dep.Name, err)
+	}
+	if dep.Git, err = stringOf(m, "git"); err != nil {
+		return dep, fmt.Errorf("dependency %s: %w", dep.Name, err)
+	}
+	if dep.Rev, err = stringOf(m, "rev"); err != nil {
+		return dep, fmt.Errorf("dependency %s: %w", dep.Name, err)
+	}
+
+	switch {
+	case (dep.Local == "") == (dep.Git == ""):
+		return dep, fmt.Errorf("dependency %s: expecting either :local or :git", dep.Name)
+	case dep.Local != "" && dep.Rev != "":
+		return dep, fmt.Errorf("dependency %s: :rev is only supported by :git", dep.Name)
+	}
+
+	return dep, nil
+}
+
+func stringOf(m map[interface{}]interface{}, key string) (string, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("expecting string for :%s, not %v", key, m[key])
+}
+
+func stringsOf(m map[interface{}]interface{}, key string) ([]string, error) {
+	if m[key] == nil {
+		return nil, nil
+	}
+
+	vals, ok := m[key].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expecting vector for :%s, not %v", key, m[key])
+	}
+
+	strs := make([]string, len(vals))
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expecting strings in :%s, not %v", key, v)
+		}
+		strs[i] = filepath.Clean(s)
+	}
+	return strs, nil
+}
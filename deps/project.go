@@ -0,0 +1,237 @@
+package deps
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrStale is returned when the lock file does not match the manifest.
+var ErrStale = errors.New("spirit.lock is out of date, run `spirit deps lock`")
+
+// Project is a directory with a manifest.
+type Project struct {
+	Dir      string
+	Manifest *Manifest
+}
+
+// FindProject returns the project of the directory, which is the nearest
+// directory up from it with a manifest. Returns nil if there is none.
+func FindProject(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	for {
+		_, err := os.Stat(filepath.Join(dir, ManifestFile))
+		if err == nil {
+			mf, err := ReadManifest(dir)
+			if err != nil {
+				return nil, err
+			}
+			return &Project{Dir: dir, Manifest: mf}, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// Resolve resolves the dependencies of the project from their sources,
+// ignoring the lock file.
+func (p *Project) Resolve() (*Resolution, error) {
+	return Resolve(p.Dir, p.Manifest)
+}
+
+// Lock resolves the dependencies of the project and pins them in the lock
+// file.
+func (p *Project) Lock() (*Resolution, error) {
+	res, err := p.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := WriteLock(p.Dir, &Lock{Deps: res.Deps}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReadLock reads the lock file of the project and checks it locks every
+// dependency of the manifest from the source it declares. Returns ErrStale
+// otherwise.
+func (p *Project) ReadLock() (*Lock, error) {
+	lock, err := ReadLock(p.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	locked := map[string]Resolved{}
+	for _, r := range lock.Deps {
+		if r.Parent == "" {
+			locked[r.Name] = r
+		}
+	}
+
+	if len(locked) != len(p.Manifest.Deps) {
+		return nil, ErrStale
+	}
+
+	for _, dep := range p.Manifest.Deps {
+		r, found := locked[dep.Name]
+		if !found || (dep.Git == "") != (r.Git == "") {
+			return nil, ErrStale
+		}
+
+		dir, lockedDir := dep.Local, r.Local
+		if dep.Git != "" {
+			dir, lockedDir = dep.Git, r.Git
+		}
+
+		dir = canonical(absPath(p.Dir, dir))
+		if dir != canonical(lockedDir) {
+			return nil, ErrStale
+		}
+
+		// an unpinned checkout stays locked to its commit until relocked
+		if dep.Rev != "" {
+			rev, err := resolveRev(dir, dep.Rev)
+			if err != nil {
+				return nil, fmt.Errorf("dependency %s: %w", dep.Name, err)
+			}
+			if rev != r.Rev {
+				return nil, ErrStale
+			}
+		}
+	}
+
+	return lock, nil
+}
+
+// Vendor copies the files of the locked dependencies into the vendor
+// directory of the project, locking them first if there is no lock file.
+// The files must match the checksums in the lock file, so a dependency
+// changed since it was locked is never vendored.
+func (p *Project) Vendor() (*Lock, error) {
+	lock, err := p.ReadLock()
+	if errors.Is(err, fs.ErrNotExist) {
+		var res *Resolution
+		if res, err = p.Lock(); err == nil {
+			lock = &Lock{Deps: res.Deps}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vendor := filepath.Join(p.Dir, filepath.FromSlash(VendorDir))
+	tmp := vendor + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, r := range lock.Deps {
+		files, err := r.source().files(r.Paths)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s: %w", r.Name, err)
+		}
+
+		if checksum(files) != r.Sum {
+			return nil, fmt.Errorf(
+				"dependency %s changed since it was locked, run `spirit deps lock`", r.Name,
+			)
+		}
+
+		root := filepath.Join(tmp, filepath.FromSlash(r.Name))
+		if err := writeFiles(root, files); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.RemoveAll(vendor); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(vendor), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return nil, err
+	}
+	return lock, os.Rename(tmp, vendor)
+}
+
+// SearchPath returns the source directories of the project followed by the
+// ones of the vendored dependencies. If the dependencies are not vendored,
+// or the lock file does not match the manifest, the directories of the
+// project are returned along with an error.
+func (p *Project) SearchPath() ([]string, error) {
+	var paths []string
+	for _, path := range p.Manifest.Paths {
+		paths = append(paths, filepath.Join(p.Dir, path))
+	}
+
+	lock, err := p.ReadLock()
+	if errors.Is(err, fs.ErrNotExist) {
+		if len(p.Manifest.Deps) > 0 {
+			return paths, fmt.Errorf("dependencies of %s are not locked, run `spirit deps vendor`", p.Dir)
+		}
+		return paths, nil
+	} else if err != nil {
+		return paths, err
+	}
+
+	var missing []string
+	vendor := filepath.Join(p.Dir, filepath.FromSlash(VendorDir))
+	for _, r := range lock.Deps {
+		root := filepath.Join(vendor, filepath.FromSlash(r.Name))
+		if _, err := os.Stat(root); err != nil {
+			missing = append(missing, r.Name)
+			continue
+		}
+
+		for _, path := range r.Paths {
+			paths = append(paths, filepath.Join(root, path))
+		}
+	}
+
+	if len(missing) > 0 {
+		return paths, fmt.Errorf("dependencies %v are not vendored, run `spirit deps vendor`", missing)
+	}
+	return paths, nil
+}
+
+func writeFiles(root string, files map[string][]byte) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func canonical(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
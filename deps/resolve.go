@@ -0,0 +1,186 @@
+package deps
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// Resolved is a dependency resolved to the exact files it provides.
+type Resolved struct {
+	Name    string
+	Version string
+	// Local is the absolute directory of a local dependency.
+	Local string
+	// Git is the absolute directory of the git checkout of the dependency
+	// and Rev the commit its files are read from.
+	Git string
+	Rev string
+	// Paths are the source directories of the dependency.
+	Paths []string
+	// Sum is the checksum of the files under Paths.
+	Sum string
+	// Parent is the name of the dependency which requires it, empty if it
+	// is required by the project itself.
+	Parent string
+}
+
+func (r Resolved) source() source {
+	if r.Git != "" {
+		return gitSource{dir: r.Git, rev: r.Rev}
+	}
+	return localSource{dir: r.Local}
+}
+
+// sameSource returns true if both are read from the same files.
+func (r Resolved) sameSource(other Resolved) bool {
+	return r.Local == other.Local && r.Git == other.Git && r.Rev == other.Rev
+}
+
+func (r Resolved) String() string {
+	if r.Git != "" {
+		return fmt.Sprintf("git %s@%.12s", r.Git, r.Rev)
+	}
+	return "local " + r.Local
+}
+
+// Resolution is the result of resolving the dependencies of a project.
+type Resolution struct {
+	// Deps are the resolved dependencies ordered by name.
+	Deps []Resolved
+	// Conflicts describe the dependencies required from different sources.
+	// The one nearest to the project is used, ties go to the first
+	// dependent by name.
+	Conflicts []string
+}
+
+// Resolve resolves the dependencies of the manifest and the dependencies
+// they require in turn. Relative paths in the manifest are resolved
+// against dir.
+func Resolve(dir string, mf *Manifest) (*Resolution, error) {
+	type pending struct {
+		dep    Dep
+		base   string
+		parent string
+	}
+
+	queue := make([]pending, 0, len(mf.Deps))
+	for _, dep := range mf.Deps {
+		queue = append(queue, pending{dep: dep, base: dir})
+	}
+
+	res := &Resolution{}
+	seen := map[string]Resolved{}
+
+	// the queue is walked breadth first, so a dependency is resolved from
+	// the dependent nearest to the project
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		r, err := locate(next.dep, next.base)
+		if err != nil {
+			return nil, err
+		}
+		r.Parent = next.parent
+
+		if found, ok := seen[r.Name]; ok {
+			if !found.sameSource(r) {
+				res.Conflicts = append(res.Conflicts, fmt.Sprintf(
+					"%s: using %s required by %s, ignoring %s required by %s",
+					r.Name, found, dependent(found.Parent), r, dependent(r.Parent),
+				))
+			}
+			continue
+		}
+
+		src := r.source()
+		dmf, err := readManifest(src)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s: %w", r.Name, err)
+		}
+
+		files, err := src.files(dmf.Paths)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %s: %w", r.Name, err)
+		}
+
+		r.Version, r.Paths, r.Sum = dmf.Version, dmf.Paths, checksum(files)
+		seen[r.Name] = r
+
+		base := r.Local
+		if r.Git != "" {
+			base = r.Git
+		}
+		for _, dep := range dmf.Deps {
+			queue = append(queue, pending{dep: dep, base: base, parent: r.Name})
+		}
+	}
+
+	for _, r := range seen {
+		res.Deps = append(res.Deps, r)
+	}
+	sortResolved(res.Deps)
+
+	return res, nil
+}
+
+// locate returns the source of the dependency declared in the manifest in
+// the base directory.
+func locate(dep Dep, base string) (Resolved, error) {
+	r := Resolved{Name: dep.Name}
+
+	dir := dep.Local
+	if dep.Git != "" {
+		dir = dep.Git
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(base, dir)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return r, err
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	} else {
+		return r, fmt.Errorf("dependency %s: %w", dep.Name, err)
+	}
+
+	if dep.Git == "" {
+		r.Local = dir
+		return r, nil
+	}
+
+	r.Git = dir
+	if r.Rev, err = resolveRev(dir, dep.Rev); err != nil {
+		return r, fmt.Errorf("dependency %s: %w", dep.Name, err)
+	}
+	return r, nil
+}
+
+// readManifest reads the manifest of a dependency. A dependency without
+// manifest provides the files of its root and has no dependencies.
+func readManifest(src source) (*Manifest, error) {
+	content, err := src.readFile(ManifestFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Manifest{Paths: []string{"."}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	mf, err := ParseManifest(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestFile, err)
+	}
+	return mf, nil
+}
+
+func dependent(parent string) string {
+	if parent == "" {
+		return "the project"
+	}
+	return parent
+}
@@ -0,0 +1,170 @@
+package deps
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// source reads the files of a dependency, either from a directory or from
+// a revision of a git checkout.
+type source interface {
+	// readFile returns the content of the file relative to the root of the
+	// dependency, or an error satisfying os.IsNotExist.
+	readFile(name string) ([]byte, error)
+	// files returns the content of the regular files under the paths
+	// mapped by their slash separated path relative to the root.
+	files(paths []string) (map[string][]byte, error)
+}
+
+type localSource struct{ dir string }
+
+func (src localSource) readFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(src.dir, name))
+}
+
+func (src localSource) files(paths []string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	for _, path := range paths {
+		root := filepath.Join(src.dir, path)
+		err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if d.Name() == ".git" || file != root && d.Name() == filepath.Dir(VendorDir) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(src.dir, file)
+			if err != nil {
+				return err
+			}
+
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = content
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// gitSource reads the files of a commit of a git checkout on disk. The
+// working tree of the checkout is never read, so the files are the same
+// however the checkout changes.
+type gitSource struct {
+	dir string
+	rev string
+}
+
+// resolveRev returns the commit the revision names in the checkout.
+func resolveRev(dir, rev string) (string, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	out, err := git(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("revision %s not found in %s", rev, dir)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (src gitSource) readFile(name string) ([]byte, error) {
+	if _, err := git(src.dir, "cat-file", "-e", src.rev+":"+filepath.ToSlash(name)); err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return git(src.dir, "show", src.rev+":"+filepath.ToSlash(name))
+}
+
+func (src gitSource) files(paths []string) (map[string][]byte, error) {
+	args := []string{"archive", "--format=tar", src.rev, "--"}
+	for _, path := range paths {
+		args = append(args, filepath.ToSlash(path))
+	}
+
+	out, err := git(src.dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(bytes.NewReader(out))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = content
+	}
+
+	return files, nil
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// checksum returns the digest of the files, which changes whenever a file
+// is added, removed, renamed or modified.
+func checksum(files map[string][]byte) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(files[name]))
+		h.Write(files[name])
+	}
+	return "sha256-" + hex.EncodeToString(h.Sum(nil))
+}
@@ -1,6 +1,6 @@
 module github.com/issadarkthing/spirit
 
-go 1.13
+go 1.16
 
 require (
 	github.com/kr/pretty v0.2.1
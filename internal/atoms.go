@@ -7,6 +7,8 @@ import (
 	"strings"
 	"sync"
 	"unicode/utf8"
+
+	"github.com/xiaq/persistent/hash"
 )
 
 // Nil represents a nil value.
@@ -25,16 +27,38 @@ func (b Bool) Eval(_ Scope) (Value, error) { return b, nil }
 
 func (b Bool) String() string { return fmt.Sprintf("%t", b) }
 
-// Number represents double precision floating point numbers
+// Hash returns 1 for true and 0 for false.
+func (b Bool) Hash() uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Number represents double precision floating point numbers. See Int,
+// BigInt, Ratio and BigDecimal for exact numbers.
 type Number float64
 
 // Eval simply returns itself since Floats evaluate to themselves.
 func (n Number) Eval(_ Scope) (Value, error) { return n, nil }
 
+// String formats the number with a decimal point even if it is a whole
+// number, so it is read back as a float rather than an Int.
 func (n Number) String() string {
-	return strconv.FormatFloat(float64(n), 'f', -1, 64)
+	s := strconv.FormatFloat(float64(n), 'f', -1, 64)
+	if !strings.ContainsAny(s, ".IN") {
+		s += ".0"
+	}
+	return s
 }
 
+// Compare returns true if the other value is a number of the same value.
+func (n Number) Compare(other Value) bool { return numEqual(n, other) }
+
+// Hash returns the hash of the value, shared with exact numbers equal to
+// it.
+func (n Number) Hash() uint32 { return hashNumber(n) }
+
 // String represents double-quoted string literals. String Form represents
 // the true string value obtained from the reader. Escape sequences are not
 // applicable at this level.
@@ -45,6 +69,9 @@ func (se String) Eval(_ Scope) (Value, error) { return se, nil }
 
 func (se String) String() string { return fmt.Sprintf("\"%s\"", string(se)) }
 
+// Hash returns the hash of the characters.
+func (se String) Hash() uint32 { return hash.String(string(se)) }
+
 // First returns the first character if string is not empty, nil otherwise.
 func (se String) First() Value {
 	if len(se) == 0 {
@@ -109,6 +136,8 @@ func (char Character) Eval(_ Scope) (Value, error) { return char, nil }
 
 func (char Character) String() string { return fmt.Sprintf("\\%c", rune(char)) }
 
+func (char Character) Hash() uint32 { return hash.DJBCombine(charSeed, uint32(char)) }
+
 // Keyword represents a keyword literal.
 type Keyword string
 
@@ -117,6 +146,10 @@ func (kw Keyword) Eval(_ Scope) (Value, error) { return kw, nil }
 
 func (kw Keyword) String() string { return fmt.Sprintf(":%s", string(kw)) }
 
+func (kw Keyword) Hash() uint32 {
+	return hash.DJBCombine(keywordSeed, hash.String(string(kw)))
+}
+
 // Invoke enables keyword lookup for maps.
 func (kw Keyword) Invoke(scope Scope, args ...Value) (Value, error) {
 	if err := verifyArgCount([]int{1, 2}, args); err != nil {
@@ -128,17 +161,25 @@ func (kw Keyword) Invoke(scope Scope, args ...Value) (Value, error) {
 		return nil, err
 	}
 
-	hm, ok := argVals[0].(*HashMap)
-	if !ok {
-		return Nil{}, nil
+	return kw.invokeValues(scope, argVals)
+}
+
+// invokeValues is like Invoke but the arguments are already evaluated.
+func (kw Keyword) invokeValues(_ Scope, argVals []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, argVals); err != nil {
+		return nil, err
 	}
 
-	value := hm.Get(kw)
-	if len(argVals) == 2 && value == nil {
-		return value, nil
+	if m, ok := argVals[0].(mapLike); ok {
+		if value, found := m.lookup(kw); found {
+			return value, nil
+		}
 	}
 
-	return value, nil
+	if len(argVals) == 2 {
+		return argVals[1], nil
+	}
+	return Nil{}, nil
 }
 
 // Symbol represents a name given to a value in memory.
@@ -156,12 +197,20 @@ func (sym Symbol) Eval(scope Scope) (Value, error) {
 		return nil, err
 	}
 
-	if _, isSpecial := target.(SpecialForm); isSpecial {
+	return sym.valueOf(target)
+}
+
+// valueOf returns error if the target bound to the symbol can not be taken
+// as value.
+func (sym Symbol) valueOf(target Value) (Value, error) {
+	switch t := target.(type) {
+	case SpecialForm:
 		return nil, fmt.Errorf("can't take value of special form '%s'", sym.Value)
-	}
 
-	if isMacro(target) {
-		return nil, fmt.Errorf("can't take value of macro '%s'", sym.Value)
+	case MultiFn:
+		if t.IsMacro {
+			return nil, fmt.Errorf("can't take value of macro '%s'", sym.Value)
+		}
 	}
 
 	return target, nil
@@ -180,25 +229,56 @@ func (sym Symbol) Compare(v Value) bool {
 
 func (sym Symbol) String() string { return sym.Value }
 
-func (sym Symbol) resolveValue(scope Scope) (Value, error) {
-	fields := strings.Split(sym.Value, ".")
+// Hash returns the hash of the name, the position is not part of it.
+func (sym Symbol) Hash() uint32 {
+	return hash.DJBCombine(symbolSeed, hash.String(sym.Value))
+}
 
-	if sym.Value == "." {
-		fields = []string{"."}
-	}
+func (sym Symbol) resolveValue(scope Scope) (Value, error) {
+	fields := sym.fields()
 
 	target, err := scope.Resolve(fields[0])
 	if len(fields) == 1 || err != nil {
 		return target, err
 	}
 
+	return resolveMembers(scope, target, fields[1:])
+}
+
+// fields splits the symbol into the name of the binding and the members
+// accessed on its value.
+// The namespace of a qualified symbol may contain dots as well.
+func (sym Symbol) fields() []string {
+	if sym.Value == "." || !strings.Contains(sym.Value, ".") {
+		return []string{sym.Value}
+	}
+
+	ns := ""
+	name := sym.Value
+	if i := strings.LastIndexByte(name, nsSeparator); i > 0 {
+		ns, name = name[:i+1], name[i+1:]
+	}
+
+	fields := strings.Split(name, ".")
+	fields[0] = ns + fields[0]
+	return fields
+}
+
+// resolveMembers does recursive member access on the target.
+func resolveMembers(scope Scope, target Value, members []string) (Value, error) {
+	var err error
+
 	rv := reflect.ValueOf(target)
-	for i := 1; i < len(fields); i++ {
+	for _, member := range members {
+		if err := checkMemberAccess(scope, rv, member); err != nil {
+			return nil, err
+		}
+
 		if rv.Type() == reflect.TypeOf(Any{}) {
 			rv = rv.Interface().(Any).V
 		}
 
-		rv, err = accessMember(rv, fields[i])
+		rv, err = accessMember(rv, member)
 		if err != nil {
 			return nil, err
 		}
@@ -230,6 +310,10 @@ func (sym Symbol) resolveValue(scope Scope) (Value, error) {
 }
 
 func resolveSpecial(scope Scope, v Value) (*SpecialForm, error) {
+	if sf, ok := v.(SpecialForm); ok {
+		return &sf, nil
+	}
+
 	sym, isSymbol := v.(Symbol)
 	if !isSymbol {
 		return nil, nil
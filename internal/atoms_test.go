@@ -94,11 +94,11 @@ func TestNil_String(t *testing.T) {
 func TestInt64_String(t *testing.T) {
 	executeStringTestCase(t, []stringTestCase{
 		{
-			value: internal.Number(10),
+			value: internal.Int(10),
 			want:  "10",
 		},
 		{
-			value: internal.Number(-10),
+			value: internal.Int(-10),
 			want:  "-10",
 		},
 	})
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"math"
+	"os"
 	"strings"
 )
 
@@ -10,16 +11,65 @@ func bindAll(scope Scope) error {
 	core := map[string]Value{
 
 		// built-in
-		"core/lazy-range*": ValueOf(lazyRange),
+		"core/lazy-range*":  ValueOf(lazyRange),
+		"core/lazy-seq*":    strictFn([]string{"f"}, false, lazySeq),
+		"core/lazy-seq?":    ValueOf(isLazy),
+		"core/lazy-map*":    strictFn([]string{"f", "colls"}, true, lazyMap),
+		"core/lazy-filter*": strictFn([]string{"pred", "coll"}, false, lazyFilter),
+		"core/lazy-take-while*": strictFn(
+			[]string{"pred", "coll"}, false, lazyTakeWhile),
+		"core/lazy-drop-while*": strictFn(
+			[]string{"pred", "coll"}, false, lazyDropWhile),
+		"core/lazy-concat*":  strictFn([]string{"colls"}, true, lazyConcat),
+		"core/lazy-flatten*": strictFn([]string{"colls"}, false, lazyFlatten),
+		"core/transduce*": strictFn(
+			[]string{"xform", "f", "init", "coll"}, false, transduce),
+		"core/into*":        strictFn([]string{"to", "from"}, true, intoColl),
+		"core/sequence*":    strictFn([]string{"xform", "coll"}, false, sequenceOf),
+		"core/eduction*":    strictFn([]string{"xforms"}, true, eduction),
+		"core/xf-comp*":     strictFn([]string{"xforms"}, true, compXforms),
+		"core/xf-map*":      strictFn([]string{"f"}, false, mapXf),
+		"core/xf-filter*":   strictFn([]string{"pred"}, false, filterXf),
+		"core/xf-remove*":   strictFn([]string{"pred"}, false, removeXf),
+		"core/xf-keep*":     strictFn([]string{"f"}, false, keepXf),
+		"core/xf-take*":     strictFn([]string{"n"}, false, takeXf),
+		"core/xf-drop*":     strictFn([]string{"n"}, false, dropXf),
+		"core/xf-take-nth*": strictFn([]string{"n"}, false, takeNthXf),
+		"core/xf-take-while*": strictFn(
+			[]string{"pred"}, false, takeWhileXf),
+		"core/xf-drop-while*": strictFn(
+			[]string{"pred"}, false, dropWhileXf),
+		"core/xf-partition-by*": strictFn(
+			[]string{"f"}, false, partitionByXf),
+		"core/xf-partition-all*": strictFn(
+			[]string{"n"}, false, partitionAllXf),
+		"core/xf-dedupe*":    strictFn(nil, false, dedupeXf),
+		"core/xf-distinct*":  strictFn(nil, false, distinctXf),
+		"core/xf-interpose*": strictFn([]string{"sep"}, false, interposeXf),
+		"core/xf-mapcat*":    strictFn([]string{"f"}, false, mapcatXf),
+		"core/cat":           catXf(),
+		"core/transducer?":   ValueOf(isTransducer),
+		"core/reduced":       ValueOf(reduced),
+		"core/reduced?":      ValueOf(isReduced),
 		"core/future*": &Fn{
 			Args:     []string{"body"},
 			Variadic: true,
 			Func:     future,
 		},
-		"core/assoc*":     ValueOf(assoc),
-		"core/keyword":    ValueOf(keyword),
-		"core/parse-json": ValueOf(parsejson),
-		"core/round":      ValueOf(math.Round),
+		"core/go*": &Fn{
+			Args:     []string{"body"},
+			Variadic: true,
+			Func:     goBlock,
+		},
+		"core/chan":    ValueOf(newChan),
+		"core/>!":      ValueOf(chanPut),
+		"core/<!":      ValueOf(chanTake),
+		"core/close!":  ValueOf(chanClose),
+		"core/alts!":   ValueOf(alts),
+		"core/timeout": ValueOf(timeout),
+		"core/assoc*":  ValueOf(assoc),
+		"core/keyword": ValueOf(keyword),
+		"core/round":   ValueOf(math.Round),
 
 		"core/time": &Fn{
 			Args:     []string{"body"},
@@ -28,7 +78,7 @@ func bindAll(scope Scope) error {
 		},
 		"core/bounded?": ValueOf(bound(scope)),
 		"core/sleep":    ValueOf(sleep),
-		"core/deref":    ValueOf(deref(scope)),
+		"core/deref":    ValueOf(deref),
 		"core/doseq": &Fn{
 			Args:     []string{"vector", "exprs"},
 			Variadic: true,
@@ -67,8 +117,9 @@ func bindAll(scope Scope) error {
 			Func: evalStr,
 		},
 		"core/loop": SpecialForm{
-			Name:  "loop",
-			Parse: parseLoop,
+			Name:    "loop",
+			Parse:   parseLoop,
+			compile: compileLoop,
 		},
 		"core/defclass": &Fn{
 			Args: []string{"hash-map", "methods"},
@@ -79,11 +130,6 @@ func bindAll(scope Scope) error {
 			Variadic: true,
 			Func:     mem,
 		},
-		"core/recur": &Fn{
-			Args:     []string{"bindings"},
-			Variadic: true,
-			Func:     recur,
-		},
 
 		"core/exception": ValueOf(Exception{}),
 
@@ -91,6 +137,7 @@ func bindAll(scope Scope) error {
 		"core/do":           Do,
 		"core/def":          Def,
 		"core/if":           If,
+		"core/recur":        Recur,
 		"core/fn*":          Lambda,
 		"core/macro*":       Macro,
 		"core/let":          Let,
@@ -98,19 +145,40 @@ func bindAll(scope Scope) error {
 		"core/quote":        SimpleQuote,
 		"core/syntax-quote": SyntaxQuote,
 
-		"core/in-ns":       ValueOf(scope.(*Spirit).SwitchNS),
-		"core/memory":      ValueOf(memory),
-		"core/macroexpand": ValueOf(macroExpand),
-		"core/type":        ValueOf(typeOf),
-		"core/to-type":     ValueOf(toType),
-		"core/impl?":       ValueOf(implements),
-		"core/realized*":   ValueOf(futureRealize),
-		"core/throw":       ValueOf(throw),
-		"core/error-is":    ValueOf(errorIs),
-		"core/substring":   ValueOf(strings.Contains),
-		"core/trim-suffix": ValueOf(strings.TrimSuffix),
-		"core/resolve":     ValueOf(resolve(scope)),
-		"core/force-gc":    ValueOf(forceGC),
+		"core/in-ns": ValueOf(scope.(*Spirit).SwitchNS),
+		"core/ns": &Fn{
+			Args:     []string{"name", "clauses"},
+			Variadic: true,
+			Func:     nsForm,
+		},
+		"core/require":       ValueOf(require),
+		"core/ns-private":    ValueOf(nsPrivate),
+		"core/ns-publics":    ValueOf(nsPublics),
+		"core/ns-map":        ValueOf(nsMap),
+		"core/ns-unmap":      ValueOf(nsUnmap),
+		"core/ns-name":       ValueOf(nsName),
+		"core/all-ns":        ValueOf(allNS),
+		"core/find-ns":       ValueOf(findNS),
+		"core/memory":        ValueOf(memory),
+		"core/macroexpand":   ValueOf(macroExpand),
+		"core/type":          ValueOf(typeOf),
+		"core/to-type":       ValueOf(toType),
+		"core/impl?":         ValueOf(implements),
+		"core/realized*":     ValueOf(isRealized),
+		"core/future-cancel": ValueOf(futureCancel),
+		"core/future-done?":  ValueOf(futureDone),
+		"core/wait-any":      ValueOf(waitAny),
+		"core/wait-all":      ValueOf(waitAll),
+		"core/throw":         ValueOf(throw),
+		"core/error-is":      ValueOf(errorIs),
+		"core/ex-info":       ValueOf(exInfo),
+		"core/ex-data":       ValueOf(exData),
+		"core/ex-message":    ValueOf(exMessage),
+		"core/ex-cause":      ValueOf(exCause),
+		"core/substring":     ValueOf(strings.Contains),
+		"core/trim-suffix":   ValueOf(strings.TrimSuffix),
+		"core/resolve":       ValueOf(resolve(scope)),
+		"core/force-gc":      ValueOf(forceGC),
 
 		// Type system functions
 		"core/str": ValueOf(makeString),
@@ -120,7 +188,9 @@ func bindAll(scope Scope) error {
 		"core/-":      ValueOf(sub),
 		"core/*":      ValueOf(multiply),
 		"core//":      ValueOf(divide),
-		"core/mod":    ValueOf(math.Mod),
+		"core/mod":    ValueOf(mod),
+		"core/quot":   ValueOf(quot),
+		"core/rem":    ValueOf(rem),
 		"core/=":      ValueOf(Compare),
 		"core/>":      ValueOf(gt),
 		"core/>=":     ValueOf(gtE),
@@ -129,16 +199,96 @@ func bindAll(scope Scope) error {
 		"core/sqrt":   ValueOf(math.Sqrt),
 		"core/prime?": ValueOf(isPrime),
 
+		"core/bit-and":         ValueOf(bitAnd),
+		"core/bit-or":          ValueOf(bitOr),
+		"core/bit-xor":         ValueOf(bitXor),
+		"core/bit-shift-left":  ValueOf(bitShiftLeft),
+		"core/bit-shift-right": ValueOf(bitShiftRight),
+
+		"core/number":      ValueOf(toNumber),
+		"core/int":         ValueOf(toInt),
+		"core/double":      ValueOf(toDouble),
+		"core/bigdec":      ValueOf(toBigDecimal),
+		"core/numerator":   ValueOf(numerator),
+		"core/denominator": ValueOf(denominator),
+		"core/number?":     ValueOf(isNumber),
+		"core/integer?":    ValueOf(isInteger),
+		"core/ratio?":      ValueOf(isRatio),
+		"core/decimal?":    ValueOf(isDecimal),
+		"core/float?":      ValueOf(isFloat),
+		"core/rational?":   ValueOf(isRational),
+
+		// Sorted collections
+		"core/sorted-map":    strictFn([]string{"kvs"}, true, sortedMap),
+		"core/sorted-map-by": strictFn([]string{"comparator", "kvs"}, true, sortedMapBy),
+		"core/sorted-set":    strictFn([]string{"vals"}, true, sortedSet),
+		"core/sorted-set-by": strictFn([]string{"comparator", "vals"}, true, sortedSetBy),
+		"core/subseq":        strictFn([]string{"sc", "test", "key"}, true, subseq),
+		"core/rsubseq":       strictFn([]string{"sc", "test", "key"}, true, rsubseq),
+		"core/compare":       ValueOf(compareFn),
+
+		// Transients
+		"core/transient":   strictFn([]string{"coll"}, false, transient),
+		"core/transient?":  ValueOf(isTransient),
+		"core/conj!":       strictFn([]string{"coll", "vals"}, true, conjBang),
+		"core/assoc!":      strictFn([]string{"coll", "kvs"}, true, assocBang),
+		"core/dissoc!":     strictFn([]string{"coll", "keys"}, true, dissocBang),
+		"core/pop!":        strictFn([]string{"coll"}, false, popBang),
+		"core/persistent!": strictFn([]string{"coll"}, false, persistentBang),
+
+		// Hashing
+		"core/hash":    ValueOf(hashFn),
+		"core/memoize": strictFn([]string{"f"}, false, memoize),
+
+		// JSON
+		"core/parse-json": strictFn([]string{"s", "opts"}, true, parseJSON),
+		"core/to-json":    strictFn([]string{"v", "opts"}, true, toJSON),
+		"core/write-json": strictFn([]string{"path", "v", "opts"}, true, writeJSON),
+		"core/json-seq":   strictFn([]string{"src", "opts"}, true, jsonSeq),
+
+		// EDN
+		"core/read-edn":      strictFn([]string{"s", "opts"}, true, readEDN),
+		"core/write-edn":     strictFn([]string{"path", "v"}, false, writeEDN),
+		"core/register-tag!": strictFn([]string{"tag", "f"}, false, registerTagFn),
+		"core/data-readers":  strictFn(nil, false, dataReaders),
+		"core/inst?":         ValueOf(isInst),
+		"core/uuid?":         ValueOf(isUUID),
+		"core/pr-str":        strictFn([]string{"vals"}, true, prStrFn),
+		"core/prn":           strictFn([]string{"vals"}, true, prn),
+
+		// Regular expressions
+		"core/re-pattern": strictFn([]string{"s"}, false, rePattern),
+		"core/re-find":    strictFn([]string{"re", "s"}, false, reFind),
+		"core/re-matches": strictFn([]string{"re", "s"}, false, reMatches),
+		"core/re-seq":     strictFn([]string{"re", "s"}, false, reSeq),
+		"core/re-groups":  strictFn([]string{"re", "s"}, false, reGroups),
+		"core/re-replace": strictFn([]string{"re", "s", "replacement"}, false, reReplace),
+		"core/re-split":   strictFn([]string{"re", "s", "limit"}, true, reSplit),
+		"core/regex?":     ValueOf(isRegex),
+
+		// Set functions
+		"core/disj":        ValueOf(disj),
+		"core/contains?":   ValueOf(contains),
+		"set/union":        ValueOf(union),
+		"set/intersection": ValueOf(intersection),
+		"set/difference":   ValueOf(difference),
+		"set/subset?":      ValueOf(isSubset),
+		"set/superset?":    ValueOf(isSuperset),
+		"set/select":       strictFn([]string{"pred", "set"}, false, selectSet),
+		"set/index":        ValueOf(index),
+
 		// io functions
-		"core/$":         ValueOf(shell),
-		"core/print":     ValueOf(println),
-		"core/printf":    ValueOf(printf),
-		"core/pprint":    ValueOf(pprint),
-		"core/read*":     ValueOf(read),
-		"core/random":    ValueOf(random),
-		"core/shuffle":   ValueOf(shuffle),
-		"core/read-file": ValueOf(readFile),
-		"core/import":    ValueOf(spiritImport(scope)),
+		"core/$":          ValueOf(shell),
+		"core/print":      ValueOf(println),
+		"core/printf":     ValueOf(printf),
+		"core/pprint":     ValueOf(pprint),
+		"core/read*":      ValueOf(read),
+		"core/*in*":       ValueOf(os.Stdin),
+		"core/random":     ValueOf(random),
+		"core/shuffle":    ValueOf(shuffle),
+		"core/read-file":  ValueOf(readFile),
+		"core/write-file": ValueOf(writeFile),
+		"core/import":     ValueOf(spiritImport(scope)),
 
 		"core/split": ValueOf(strings.Split),
 		"core/trim":  ValueOf(strings.Trim),
@@ -0,0 +1,471 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var _ Seq = (*Chan)(nil)
+
+// Chan is a channel values can be put onto and taken from by concurrent
+// evaluations. Closing the channel makes pending and further puts fail
+// while the buffered values can still be taken. Taking from a closed and
+// drained channel returns nil.
+//
+// A channel is also a Seq of the values taken from it which ends once
+// the channel is closed. Traversing it blocks until values are put. The
+// values are taken once and kept, so the channel can be traversed like any
+// other sequence. doseq instead takes every value from the channel, so the
+// values are shared between the evaluations iterating over the channel.
+// Traversing stops with the context error once the evaluation the channel
+// was created in is cancelled.
+type Chan struct {
+	ch   chan Value
+	done chan struct{}
+	once sync.Once
+	// ctx is the context of the evaluation the channel was created in.
+	// Seq methods can not be given a context, so taking values as a Seq
+	// is cancelled along with it.
+	ctx context.Context
+	// err is the error the evaluation of a go block failed with. It is
+	// returned instead of nil once the channel is drained.
+	err error
+
+	// mu guards head.
+	mu   sync.Mutex
+	head *chanSeq
+}
+
+// NewChan returns a channel buffering up to size values. A channel with
+// no buffer blocks a put until the value is taken.
+func NewChan(size int) *Chan {
+	return &Chan{
+		ch:   make(chan Value, size),
+		done: make(chan struct{}),
+		ctx:  context.Background(),
+	}
+}
+
+// newChanContext returns a channel, see NewChan, whose values taken as a Seq
+// are taken until ctx is done.
+func newChanContext(ctx context.Context, size int) *Chan {
+	c := NewChan(size)
+	c.ctx = ctx
+	return c
+}
+
+// Put puts the value onto the channel, blocking until there is room in the
+// buffer or the value is taken. Returns false if the channel is closed.
+func (c *Chan) Put(ctx context.Context, v Value) (bool, error) {
+	if v == nil || v == (Nil{}) {
+		return false, fmt.Errorf("cannot put nil on channel")
+	}
+
+	select {
+	case <-c.done:
+		return false, nil
+	default:
+	}
+
+	select {
+	case c.ch <- v:
+		return true, nil
+
+	case <-c.done:
+		return false, nil
+
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// Take takes a value from the channel, blocking until one is put. Returns
+// nil once the channel is closed and all its values have been taken.
+func (c *Chan) Take(ctx context.Context) (Value, error) {
+	v, ok, err := c.take(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return Nil{}, nil
+	}
+
+	return v, nil
+}
+
+// take returns false if the channel is closed and drained.
+func (c *Chan) take(ctx context.Context) (Value, bool, error) {
+	select {
+	case v := <-c.ch:
+		return v, true, nil
+
+	case <-c.done:
+		return c.drain()
+
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// drain takes a value left in the buffer of the closed channel.
+func (c *Chan) drain() (Value, bool, error) {
+	select {
+	case v := <-c.ch:
+		return v, true, nil
+	default:
+		return nil, false, c.err
+	}
+}
+
+// Close closes the channel. Returns false if it has already been closed.
+func (c *Chan) Close() bool {
+	return c.closeWith(nil)
+}
+
+func (c *Chan) closeWith(err error) bool {
+	closed := false
+	c.once.Do(func() {
+		c.err = err
+		close(c.done)
+		closed = true
+	})
+	return closed
+}
+
+// Closed returns true if the channel has been closed.
+func (c *Chan) Closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Eval returns the channel itself.
+func (c *Chan) Eval(_ Scope) (Value, error) {
+	return c, nil
+}
+
+func (c *Chan) String() string {
+	return fmt.Sprintf("<Chan(size: %d closed: %v)>", cap(c.ch), c.Closed())
+}
+
+// First takes the first value of the sequence, blocking until it is put.
+// Returns nil if the channel is closed.
+func (c *Chan) First() Value {
+	if head := c.seq(); head != nil {
+		return head.val
+	}
+	return nil
+}
+
+// Next returns the values following the first one.
+func (c *Chan) Next() Seq {
+	if head := c.seq(); head != nil {
+		return head.Next()
+	}
+	return nil
+}
+
+// Cons returns a sequence of the value followed by the values taken from
+// the channel.
+func (c *Chan) Cons(v Value) Seq {
+	if head := c.seq(); head != nil {
+		return head.Cons(v)
+	}
+	return &List{Values: Values{v}}
+}
+
+// Conj puts the values onto the channel and returns it.
+func (c *Chan) Conj(vals ...Value) Seq {
+	for _, v := range vals {
+		ok, err := c.Put(c.ctx, v)
+		if isAbort(err) {
+			panic(seqPanic{err: err})
+		} else if !ok || err != nil {
+			break
+		}
+	}
+	return c
+}
+
+// Size takes all the values until the channel is closed and returns the
+// number of values.
+func (c *Chan) Size() int {
+	if head := c.seq(); head != nil {
+		return head.Size()
+	}
+	return 0
+}
+
+// seq returns the sequence of the values taken from the channel. The first
+// value is taken on the first call. Returns nil if the channel is closed.
+func (c *Chan) seq() *chanSeq {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.head == nil {
+		c.head = c.takeSeq()
+	}
+	return c.head
+}
+
+// takeSeq takes a value from the channel and returns the sequence starting
+// at it. Returns nil if the channel is closed. Panics with a seqPanic if
+// the evaluation the channel was created in is cancelled.
+func (c *Chan) takeSeq() *chanSeq {
+	v, ok, err := c.take(c.ctx)
+	if isAbort(err) {
+		panic(seqPanic{err: err})
+	} else if !ok || err != nil {
+		return nil
+	}
+
+	return &chanSeq{c: c, val: v}
+}
+
+// chanSeq is a sequence of the values taken from a channel. Each value is
+// taken once, so the sequence can be traversed multiple times.
+type chanSeq struct {
+	c    *Chan
+	val  Value
+	once sync.Once
+	next *chanSeq
+}
+
+func (s *chanSeq) Eval(_ Scope) (Value, error) {
+	return s, nil
+}
+
+func (s *chanSeq) String() string {
+	return fmt.Sprintf("<ChanSeq(first: %v)>", s.val)
+}
+
+func (s *chanSeq) First() Value {
+	return s.val
+}
+
+// Next takes the next value from the channel on the first call. Returns nil
+// if the channel is closed.
+func (s *chanSeq) Next() Seq {
+	s.once.Do(func() {
+		s.next = s.c.takeSeq()
+	})
+
+	if s.next == nil {
+		return nil
+	}
+	return s.next
+}
+
+func (s *chanSeq) Cons(v Value) Seq {
+	cons := &chanSeq{c: s.c, val: v}
+	cons.once.Do(func() { cons.next = s })
+	return cons
+}
+
+func (s *chanSeq) Conj(vals ...Value) Seq {
+	return s.c.Conj(vals...)
+}
+
+func (s *chanSeq) Size() int {
+	size := 0
+	for curr := s; curr != nil; {
+		size++
+
+		next, ok := curr.Next().(*chanSeq)
+		if !ok {
+			break
+		}
+		curr = next
+	}
+	return size
+}
+
+// newChan returns a channel, buffered if the size is given.
+func newChan(scope Scope, size ...int) (*Chan, error) {
+	switch len(size) {
+	case 0:
+		return newChanContext(contextOf(scope), 0), nil
+	case 1:
+		if size[0] < 0 {
+			return nil, fmt.Errorf("channel size must not be negative")
+		}
+		return newChanContext(contextOf(scope), size[0]), nil
+	default:
+		return nil, ArgumentError{
+			Got: len(size),
+			Fn:  "chan",
+		}
+	}
+}
+
+// timeout returns a channel which is closed after given milliseconds.
+func timeout(scope Scope, ms int) *Chan {
+	c := newChanContext(contextOf(scope), 0)
+	time.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
+		c.Close()
+	})
+	return c
+}
+
+// chanPut puts the value onto the channel, blocking until there is room or
+// the evaluation is cancelled. Returns false if the channel is closed.
+func chanPut(scope Scope, c *Chan, v Value) (bool, error) {
+	return c.Put(contextOf(scope), v)
+}
+
+// chanTake takes a value from the channel, blocking until a value is put or
+// the evaluation is cancelled. Returns nil if the channel is closed.
+func chanTake(scope Scope, c *Chan) (Value, error) {
+	return c.Take(contextOf(scope))
+}
+
+func chanClose(c *Chan) {
+	c.Close()
+}
+
+// alts completes at most one of the operations, whichever is ready first,
+// and returns a vector of the result and the channel of the operation.
+// An operation is either a channel to take from or a vector of a channel
+// and a value to put onto it. With a `:default` option the default value
+// is returned right away if no operation is ready.
+func alts(scope Scope, ops Seq, opts ...Value) (Value, error) {
+	var dflt Value
+	hasDefault := false
+
+	for i := 0; i < len(opts); i += 2 {
+		if opts[i] != Keyword("default") || i+1 >= len(opts) {
+			return nil, fmt.Errorf("invalid option for alts!: %v", opts[i])
+		}
+		dflt, hasDefault = opts[i+1], true
+	}
+
+	ctx := contextOf(scope)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+
+	// chans holds the channel of each operation, every operation has two
+	// cases: the operation itself and the channel closing.
+	var chans []*Chan
+	for seq := ops; seq != nil && seq.First() != nil; seq = seq.Next() {
+		switch op := seq.First().(type) {
+		case *Chan:
+			chans = append(chans, op)
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(op.ch),
+			})
+
+		case *Vector:
+			c, ok := op.Index(0).(*Chan)
+			if !ok || op.Size() != 2 {
+				return nil, fmt.Errorf("put operation must be [channel value], got %v", op)
+			}
+
+			v := op.Index(1)
+			if v == (Nil{}) {
+				return nil, fmt.Errorf("cannot put nil on channel")
+			}
+
+			chans = append(chans, c)
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(c.ch),
+				Send: reflect.ValueOf(&v).Elem(),
+			})
+
+		default:
+			return nil, TypeError{
+				Expected: &Chan{},
+				Got:      op,
+			}
+		}
+
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(chans[len(chans)-1].done),
+		})
+	}
+
+	if hasDefault {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+
+	chosen, recv, _ := reflect.Select(cases)
+	switch {
+	case chosen == 0:
+		return nil, ctx.Err()
+
+	case chosen == len(cases)-1 && hasDefault:
+		return NewVector().Conj(dflt, Keyword("default")), nil
+	}
+
+	c := chans[(chosen-1)/2]
+	put := cases[chosen].Dir == reflect.SelectSend ||
+		cases[chosen-1].Dir == reflect.SelectSend
+
+	var result Value
+	switch {
+	case put:
+		// the value was put unless the channel was closed
+		result = Bool(cases[chosen].Dir == reflect.SelectSend)
+
+	case chosen%2 == 1:
+		result = recv.Interface().(Value)
+
+	default:
+		v, ok, err := c.drain()
+		if err != nil {
+			return nil, err
+		}
+
+		result = Nil{}
+		if ok {
+			result = v
+		}
+	}
+
+	return NewVector().Conj(result, c), nil
+}
+
+// goBlock evaluates the body in another goroutine and returns a channel
+// which receives the result before it is closed. If the evaluation fails,
+// taking from the channel returns the error.
+func goBlock(scope Scope, args []Value) (Value, error) {
+	ctx := contextOf(scope)
+	c := newChanContext(ctx, 1)
+
+	goScope := snapshot(scope)
+	goScope.exec = newExecution(ctx)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.closeWith(newEvalErr(args[0], panicError(r)))
+			}
+		}()
+
+		v, err := args[0].Eval(goScope)
+		if err != nil {
+			c.closeWith(newEvalErr(args[0], err))
+			return
+		}
+
+		if v != nil && v != (Nil{}) {
+			if _, err := c.Put(ctx, v); err != nil {
+				c.closeWith(err)
+				return
+			}
+		}
+		c.Close()
+	}()
+
+	return c, nil
+}
@@ -0,0 +1,1025 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// errRecurTail is returned for recur used out of tail position.
+var errRecurTail = errors.New("can only recur from tail position")
+
+// code is the executable form of a compiled form. It runs in the frame of
+// the let, loop or function body enclosing the form.
+type code func(env *frame) (Value, error)
+
+// compiler analyzes forms into code. Macros are expanded and special forms
+// are resolved once at compile time. Symbols bound by the enclosing let,
+// loop and fn* forms are resolved to frame slots, other symbols are looked
+// up when the code runs with the global bindings cached until they change.
+// recur is checked to be in tail position of the loop or fn* it rebinds.
+type compiler struct {
+	// scope is the scope the compilation was started in. Macros are
+	// expanded and special forms resolved against it.
+	scope  Scope
+	locals *layout
+	// recur is the loop or fn* method recur rebinds, nil if it is only
+	// known when the code runs, such as for forms evaluated by fexprs.
+	recur *recurTarget
+	// tail is true if the value of the form compiled is the value of the
+	// loop or fn* method recur rebinds.
+	tail bool
+}
+
+// recurTarget is a loop or fn* method that can be rebound by recur.
+type recurTarget struct {
+	arity int
+}
+
+// at returns the compiler for a form in tail position or not.
+func (c *compiler) at(tail bool) *compiler {
+	if c.tail == tail {
+		return c
+	}
+
+	inner := *c
+	inner.tail = tail
+	return &inner
+}
+
+// dynamic returns the compiler for a form which may be evaluated by an
+// fexpr. Whether recur in such a form is in tail position is only known
+// when the code runs.
+func (c *compiler) dynamic() *compiler {
+	inner := *c
+	inner.recur, inner.tail = nil, true
+	return &inner
+}
+
+// compiledList is the code of a list compiled for the locals of a frame.
+type compiledList struct {
+	spirit *Spirit
+	ns     string
+	locals *layout
+	run    code
+}
+
+// compile returns the code of the list for the locals of the given frame.
+// The code is compiled once and reused while the frame layout and the
+// current namespace are the same.
+func (lf *List) compile(env *frame) (code, error) {
+	ns := env.root.CurrentNS()
+	cl, _ := lf.compiled.Load().(*compiledList)
+	if cl != nil && cl.spirit == env.root && cl.ns == ns && cl.locals == env.layout {
+		return cl.run, nil
+	}
+
+	c := &compiler{scope: env, locals: env.layout, tail: true}
+	run, err := c.compileList(lf)
+	if err != nil {
+		return nil, err
+	}
+
+	lf.compiled.Store(&compiledList{
+		spirit: env.root,
+		ns:     ns,
+		locals: env.layout,
+		run:    run,
+	})
+	return run, nil
+}
+
+func (c *compiler) compile(form Value) (code, error) {
+	switch f := form.(type) {
+	case Nil, Bool, Number, Int, BigInt, Ratio, BigDecimal, String, Character, Keyword:
+		return constant(f), nil
+
+	case Symbol:
+		return c.compileSymbol(f), nil
+
+	case *List:
+		return c.compileList(f)
+
+	case *Vector:
+		return c.at(false).compileVector(f)
+
+	case *HashMap:
+		return c.at(false).compileHashMap(f)
+
+	case *Set:
+		return c.at(false).compileSet(f)
+	}
+
+	return func(env *frame) (Value, error) {
+		return form.Eval(env)
+	}, nil
+}
+
+func (c *compiler) compileAll(forms []Value) ([]code, error) {
+	codes := make([]code, len(forms))
+	for i, form := range forms {
+		run, err := c.compile(form)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = run
+	}
+	return codes, nil
+}
+
+// compileSeq compiles the forms to code which evaluates them in order and
+// returns the result of the last one. Only the last form is in tail
+// position.
+func (c *compiler) compileSeq(forms []Value) (code, error) {
+	var codes []code
+	if len(forms) > 0 {
+		var err error
+		last := len(forms) - 1
+		if codes, err = c.at(false).compileAll(forms[:last]); err != nil {
+			return nil, err
+		}
+
+		run, err := c.compile(forms[last])
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, run)
+	}
+
+	switch len(codes) {
+	case 0:
+		return constant(Nil{}), nil
+
+	case 1:
+		run, form := codes[0], forms[0]
+		return func(env *frame) (Value, error) {
+			v, err := run(env)
+			if err != nil {
+				return nil, newEvalErr(form, err)
+			}
+			return v, nil
+		}, nil
+	}
+
+	return func(env *frame) (Value, error) {
+		var result Value = Nil{}
+		for i, run := range codes {
+			v, err := run(env)
+			if err != nil {
+				return nil, newEvalErr(forms[i], err)
+			}
+			result = v
+		}
+		return result, nil
+	}, nil
+}
+
+func constant(v Value) code {
+	return func(_ *frame) (Value, error) { return v, nil }
+}
+
+func (c *compiler) compileSymbol(sym Symbol) code {
+	ref := c.ref(sym)
+	return func(env *frame) (Value, error) {
+		target, err := ref(env)
+		if err != nil {
+			return nil, err
+		}
+		return sym.valueOf(target)
+	}
+}
+
+// ref returns code resolving the value bound to the symbol.
+func (c *compiler) ref(sym Symbol) code {
+	fields := sym.fields()
+	name := fields[0]
+
+	var base code
+	if depth, idx, found := c.locals.lookup(name); found {
+		base = local(name, depth, idx)
+	} else {
+		base = (&globalRef{name: name, ns: c.namespace()}).resolve
+	}
+
+	if len(fields) == 1 {
+		return base
+	}
+
+	members := fields[1:]
+	return func(env *frame) (Value, error) {
+		target, err := base(env)
+		if err != nil {
+			return nil, err
+		}
+		return resolveMembers(env, target, members)
+	}
+}
+
+// namespace returns the namespace the code is compiled in.
+func (c *compiler) namespace() string {
+	if root, ok := RootScope(c.scope).(*Spirit); ok {
+		return root.CurrentNS()
+	}
+	return ""
+}
+
+func (c *compiler) isLocal(sym Symbol) bool {
+	_, _, found := c.locals.lookup(sym.fields()[0])
+	return found
+}
+
+func local(name string, depth, idx int) code {
+	return func(env *frame) (Value, error) {
+		f := env
+		for i := 0; i < depth; i++ {
+			f = f.up
+		}
+
+		if v := f.slots[idx]; v != nil {
+			return v, nil
+		}
+
+		// the local is not bound yet when referenced by a form compiled
+		// at run time for the complete layout.
+		return f.parent.Resolve(name)
+	}
+}
+
+func (ref *globalRef) resolve(env *frame) (Value, error) {
+	for f := env; f != nil; f = f.up {
+		if v, found := f.dynamic[ref.name]; found {
+			return v, nil
+		}
+	}
+
+	if !env.global {
+		return env.outer.Resolve(ref.name)
+	}
+
+	return env.root.resolveGlobal(ref)
+}
+
+func (c *compiler) compileVector(vec *Vector) (code, error) {
+	codes, err := c.compileAll(vec.GetValues())
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *frame) (Value, error) {
+		vals := make([]Value, len(codes))
+		for i, run := range codes {
+			v, err := run(env)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return NewVector().Conj(vals...), nil
+	}, nil
+}
+
+func (c *compiler) compileHashMap(hm *HashMap) (code, error) {
+	var keys, vals []code
+	for it := hm.Data.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+
+		key, err := c.compile(k.(Value))
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := c.compile(v.(Value))
+		if err != nil {
+			return nil, err
+		}
+
+		keys, vals = append(keys, key), append(vals, val)
+	}
+
+	return func(env *frame) (Value, error) {
+		res := NewHashMap()
+		for i := range keys {
+			k, err := keys[i](env)
+			if err != nil {
+				return nil, err
+			}
+
+			v, err := vals[i](env)
+			if err != nil {
+				return nil, err
+			}
+
+			res.Data = res.Data.Assoc(k, v)
+		}
+		return res, nil
+	}, nil
+}
+
+func (c *compiler) compileSet(set *Set) (code, error) {
+	forms := set.values()
+	codes, err := c.compileAll(forms)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *frame) (Value, error) {
+		vals, err := evalCodes(env, codes, forms)
+		if err != nil {
+			return nil, err
+		}
+		return NewSet().Conj(vals...), nil
+	}, nil
+}
+
+// evalCodes runs the codes compiled from the forms and returns the values.
+func evalCodes(env *frame, codes []code, forms []Value) ([]Value, error) {
+	vals := make([]Value, len(codes))
+	for i, run := range codes {
+		v, err := run(env)
+		if err != nil {
+			return nil, newEvalErr(forms[i], err)
+		}
+
+		if isRecur(v) {
+			return nil, newEvalErr(forms[i], errRecurTail)
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+func (c *compiler) compileList(lf *List) (code, error) {
+	if lf.Size() == 0 {
+		return constant(lf), nil
+	}
+
+	// special forms are also used as values by generated forms
+	if sf, ok := lf.Values[0].(SpecialForm); ok {
+		return c.compileSpecial(lf, sf)
+	}
+
+	if sym, ok := lf.Values[0].(Symbol); ok && !c.isLocal(sym) {
+		target, err := sym.resolveValue(c.scope)
+		if err == nil {
+			switch t := target.(type) {
+			case SpecialForm:
+				return c.compileSpecial(lf, t)
+
+			case MultiFn:
+				if t.IsMacro {
+					return c.compileMacro(lf, t)
+				}
+			}
+		}
+	}
+
+	return c.compileCall(lf)
+}
+
+func (c *compiler) compileSpecial(lf *List, sf SpecialForm) (code, error) {
+	args := lf.Values[1:]
+
+	if sf.compile == nil {
+		return c.invocation(lf, func(env *frame) (Value, error) {
+			return invokeSpecial(env, sf, args)
+		}), nil
+	}
+
+	body, err := sf.compile(c, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sf.Name, err)
+	}
+
+	return c.invocation(lf, body), nil
+}
+
+func invokeSpecial(scope Scope, sf SpecialForm, args []Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+
+	fn, err := sf.Parse(scope, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sf.Name, err)
+	}
+
+	return fn.Invoke(scope, args...)
+}
+
+func (c *compiler) compileMacro(lf *List, macro MultiFn) (code, error) {
+	form, err := macro.Expand(c.scope, lf.Values[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.compile(form)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.invocation(lf, body), nil
+}
+
+// invocation wraps the body of a special form or macro like a call to it,
+// so it shows in the stack trace.
+func (c *compiler) invocation(lf *List, body code) code {
+	call := Call{
+		Name:     lf.Values[0].String(),
+		Position: lf.Position,
+	}
+
+	return func(env *frame) (Value, error) {
+		if err := env.check(); err != nil {
+			return nil, err
+		}
+
+		if err := env.exec.enter(env.root); err != nil {
+			return nil, newEvalErr(lf, err)
+		}
+
+		env.exec.stack.Push(call)
+		v, err := runCode(env, body)
+		env.exec.leave()
+		if err != nil {
+			err = newEvalErr(lf, err)
+			return nil, addStackTrace(*env.exec.stack, err)
+		}
+		env.exec.stack.Pop()
+
+		return v, nil
+	}
+}
+
+func (c *compiler) compileCall(lf *List) (code, error) {
+	var head code
+	if sym, ok := lf.Values[0].(Symbol); ok {
+		head = c.ref(sym)
+	} else {
+		run, err := c.at(false).compile(lf.Values[0])
+		if err != nil {
+			return nil, err
+		}
+		head = run
+	}
+
+	// the arguments of a target which is not known to be strict may be
+	// evaluated by an fexpr, in tail position or not
+	argc := c.dynamic()
+	if c.isStrictCall(lf.Values[0]) {
+		argc = c.at(false)
+	}
+
+	args := lf.Values[1:]
+	codes, err := argc.compileAll(args)
+	if err != nil {
+		return nil, err
+	}
+	tail := c.tail
+
+	call := Call{
+		Name:     lf.Values[0].String(),
+		Position: lf.Position,
+	}
+
+	return func(env *frame) (Value, error) {
+		if err := env.check(); err != nil {
+			return nil, err
+		}
+
+		target, err := head(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := env.exec.enter(env.root); err != nil {
+			return nil, newEvalErr(lf, err)
+		}
+
+		var val Value
+		switch {
+		case isStrict(target):
+			env.exec.stack.Push(call)
+			var vals []Value
+			vals, err = evalCodes(env, codes, args)
+			if err == nil {
+				val, err = invokeStrict(env, target, vals)
+			}
+
+		default:
+			if sf, isSpecial := target.(SpecialForm); isSpecial {
+				env.exec.stack.Push(call)
+				val, err = invokeSpecial(env, sf, args)
+				break
+			}
+
+			invokable, ok := target.(Invokable)
+			if !ok {
+				env.exec.leave()
+				return nil, ImplementError{
+					Name: invokableStr,
+					Val:  target,
+				}
+			}
+
+			env.exec.stack.Push(call)
+			val, err = invokeForms(env, invokable, args)
+		}
+		env.exec.leave()
+
+		if err == nil && !tail && isRecur(val) {
+			err = errRecurTail
+		}
+
+		if err != nil {
+			err = newEvalErr(lf, err)
+			return nil, addStackTrace(*env.exec.stack, err)
+		}
+		env.exec.stack.Pop()
+
+		return val, nil
+	}, nil
+}
+
+// isStrictCall returns true if the head of a call resolves to a strict
+// target at compile time.
+func (c *compiler) isStrictCall(head Value) bool {
+	sym, ok := head.(Symbol)
+	if !ok || c.isLocal(sym) {
+		return false
+	}
+
+	target, err := sym.resolveValue(c.scope)
+	return err == nil && isStrict(target)
+}
+
+// isStrict returns true if the target evaluates all of its arguments when
+// invoked. Compiled code evaluates the arguments of such targets itself
+// and passes the values to invokeStrict().
+func isStrict(target Value) bool {
+	switch fn := target.(type) {
+	case MultiFn:
+		return !fn.IsMacro
+
+	case *Fn:
+		return fn.strict != nil
+
+	case Keyword, *Vector, *Transducer, *HashMap, *Set, *SortedMap, *SortedSet:
+		return true
+	}
+
+	return false
+}
+
+func invokeStrict(scope Scope, target Value, args []Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+
+	switch fn := target.(type) {
+	case MultiFn:
+		return fn.invokeValues(scope, args)
+
+	case *Fn:
+		return fn.strict(scope, args)
+
+	case Keyword:
+		return fn.invokeValues(scope, args)
+
+	case *Vector:
+		return fn.invokeValues(scope, args)
+
+	case *Transducer:
+		return fn.invokeValues(scope, args)
+
+	case *HashMap:
+		return fn.invokeValues(scope, args)
+
+	case *Set:
+		return fn.invokeValues(scope, args)
+
+	case *SortedMap:
+		return fn.invokeValues(scope, args)
+
+	case *SortedSet:
+		return fn.invokeValues(scope, args)
+	}
+
+	return nil, ImplementError{
+		Name: invokableStr,
+		Val:  target,
+	}
+}
+
+func compileDo(c *compiler, args []Value) (code, error) {
+	return c.compileSeq(args)
+}
+
+func compileIf(c *compiler, args []Value) (code, error) {
+	if err := verifyArgCount([]int{2, 3}, args); err != nil {
+		return nil, err
+	}
+
+	test, err := c.at(false).compile(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := c.compileAll(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	then, otherwise := codes[0], constant(Nil{})
+	if len(codes) == 2 {
+		otherwise = codes[1]
+	}
+
+	return func(env *frame) (Value, error) {
+		v, err := test(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if isTruthy(v) {
+			return then(env)
+		}
+		return otherwise(env)
+	}, nil
+}
+
+func compileDef(c *compiler, args []Value) (code, error) {
+	if err := verifyArgCount([]int{2}, args); err != nil {
+		return nil, err
+	}
+
+	sym, isSymbol := args[0].(Symbol)
+	if !isSymbol {
+		return nil, TypeError{
+			Expected: Symbol{},
+			Got:      args[0],
+		}
+	}
+
+	value, err := c.at(false).compile(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *frame) (Value, error) {
+		v, err := value(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := env.root.Bind(sym.Value, v); err != nil {
+			return nil, err
+		}
+
+		return sym, nil
+	}, nil
+}
+
+func compileRecur(c *compiler, args []Value) (code, error) {
+	if !c.tail {
+		return nil, errRecurTail
+	}
+
+	if c.recur != nil && len(args) != c.recur.arity {
+		return nil, ArgumentError{
+			Got: len(args),
+			Fn:  "recur",
+		}
+	}
+
+	codes, err := c.at(false).compileAll(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *frame) (Value, error) {
+		vals, err := evalCodes(env, codes, args)
+		if err != nil {
+			return nil, err
+		}
+		return &recurSignal{args: vals}, nil
+	}, nil
+}
+
+func compileSimpleQuote(_ *compiler, args []Value) (code, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	return constant(args[0]), nil
+}
+
+func compileSyntaxQuote(_ *compiler, args []Value) (code, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	return func(env *frame) (Value, error) {
+		return recursiveQuote(env, args[0])
+	}, nil
+}
+
+func compileTry(c *compiler, args []Value) (code, error) {
+	tf, err := readTry(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// recur cannot rebind across try, the value of the body may still be
+	// replaced by a catch clause or finally may fail
+	c = c.at(false)
+
+	if tf.legacy() {
+		return c.compileHandlerTry(tf)
+	}
+
+	body, err := c.compileSeq(tf.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	catches := make([]code, len(tf.Catches))
+	locals := make([]*layout, len(tf.Catches))
+	for i, cc := range tf.Catches {
+		inner := &compiler{
+			scope:  c.scope,
+			locals: &layout{names: []string{cc.Name}, parent: c.locals},
+			recur:  c.recur,
+		}
+
+		catches[i], err = inner.compileSeq(cc.Body)
+		if err != nil {
+			return nil, err
+		}
+		locals[i] = inner.locals
+	}
+
+	var finally code
+	if tf.Finally != nil {
+		finally, err = c.compileSeq(tf.Finally)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(env *frame) (Value, error) {
+		depth := env.exec.stack.Size()
+
+		v, err := body(env)
+		if err != nil {
+			if i, caught := tf.catch(err); caught {
+				env.exec.stack.unwind(depth)
+
+				catchEnv := newFrame(locals[i], env, env.exec)
+				catchEnv.slots[0] = ValueOf(err)
+				v, err = catches[i](catchEnv)
+			}
+		}
+
+		if finally != nil {
+			if _, ferr := finally(env); ferr != nil {
+				return nil, ferr
+			}
+		}
+
+		return v, err
+	}, nil
+}
+
+// compileHandlerTry compiles a try passing the error to a handler fn.
+func (c *compiler) compileHandlerTry(tf *tryForm) (code, error) {
+	forms := tf.Body
+	if tf.Handler != nil {
+		forms = []Value{tf.Body[0], tf.Handler}
+	}
+
+	codes, err := c.compileAll(forms)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *frame) (Value, error) {
+		depth := env.exec.stack.Size()
+
+		v, tryErr := codes[0](env)
+		if tryErr == nil || isAbort(tryErr) {
+			return v, tryErr
+		}
+		env.exec.stack.unwind(depth)
+
+		if len(codes) < 2 {
+			return ValueOf(nil), nil
+		}
+
+		handler, err := codes[1](env)
+		if err != nil {
+			return nil, err
+		}
+
+		fn, isFn := handler.(MultiFn)
+		if !isFn {
+			return ValueOf(nil), TypeError{Expected: MultiFn{}, Got: handler}
+		}
+
+		return fn.invokeValues(env, []Value{ValueOf(tryErr)})
+	}, nil
+}
+
+// compileBindings compiles the bindings of let and loop forms into a new
+// layout. Each binding is visible to the bindings following it and to the
+// body. The body of a loop is the target of recur.
+func (c *compiler) compileBindings(args []Value, loop bool) (*compiler, []code, code, error) {
+	bindings, err := readBindings(args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	inner := &compiler{
+		scope:  c.scope,
+		locals: &layout{parent: c.locals},
+		recur:  c.recur,
+	}
+
+	exprs := make([]code, len(bindings))
+	for i, b := range bindings {
+		exprs[i], err = inner.compile(b.Expr)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		inner.locals.names = append(inner.locals.names, b.Name)
+	}
+
+	inner.tail = c.tail
+	if loop {
+		inner.recur, inner.tail = &recurTarget{arity: len(bindings)}, true
+	}
+
+	body, err := inner.compileSeq(args[1:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return inner, exprs, body, nil
+}
+
+func compileLet(c *compiler, args []Value) (code, error) {
+	inner, exprs, body, err := c.compileBindings(args, false)
+	if err != nil {
+		return nil, err
+	}
+
+	locals := inner.locals
+	return func(env *frame) (Value, error) {
+		letEnv := newFrame(locals, env, env.exec)
+		for i, expr := range exprs {
+			v, err := expr(letEnv)
+			if err != nil {
+				return nil, err
+			}
+			letEnv.slots[i] = v
+		}
+
+		return body(letEnv)
+	}, nil
+}
+
+func compileLoop(c *compiler, args []Value) (code, error) {
+	inner, exprs, body, err := c.compileBindings(unpackLoop(args), true)
+	if err != nil {
+		return nil, err
+	}
+
+	locals := inner.locals
+	return func(env *frame) (Value, error) {
+		loopEnv := newFrame(locals, env, env.exec)
+		for i, expr := range exprs {
+			v, err := expr(loopEnv)
+			if err != nil {
+				return nil, err
+			}
+			loopEnv.slots[i] = v
+		}
+
+		result, err := body(loopEnv)
+		for err == nil && isRecur(result) {
+			if err := loopEnv.exec.err(); err != nil {
+				return nil, err
+			}
+
+			newBindings := result.(*recurSignal).args
+			if len(newBindings) != len(exprs) {
+				return nil, ArgumentError{
+					Got: len(newBindings),
+					Fn:  "recur",
+				}
+			}
+
+			// every iteration gets its own frame as closures created in
+			// the previous one may still refer to it
+			loopEnv = newFrame(locals, env, env.exec)
+			copy(loopEnv.slots, newBindings)
+			result, err = body(loopEnv)
+		}
+
+		return result, err
+	}, nil
+}
+
+func fnCompiler(isMacro bool) func(c *compiler, forms []Value) (code, error) {
+	return func(c *compiler, forms []Value) (code, error) {
+		if len(forms) < 1 {
+			return nil, fmt.Errorf("insufficient args (%d) for 'fn'", len(forms))
+		}
+
+		nextIndex := 0
+		def := MultiFn{
+			IsMacro: isMacro,
+		}
+
+		name, isName := forms[nextIndex].(Symbol)
+		if isName {
+			def.Name = name.String()
+			nextIndex++
+		}
+
+		if nextIndex < len(forms) {
+			doc, isDoc := forms[nextIndex].(String)
+			if isDoc {
+				def.Doc = string(doc)
+				nextIndex++
+			}
+		}
+
+		if nextIndex >= len(forms) {
+			return nil, fmt.Errorf("insufficient args (%d) for 'fn'", len(forms))
+		}
+
+		if _, isList := forms[nextIndex].(*List); isList {
+			for _, arg := range forms[nextIndex:] {
+				spec, isList := arg.(*List)
+				if !isList {
+					return nil, fmt.Errorf("expected arg to be list, not %s",
+						reflect.TypeOf(arg))
+				}
+
+				fn, err := c.compileMethod(spec.Values)
+				if err != nil {
+					return nil, err
+				}
+				def.Methods = append(def.Methods, *fn)
+			}
+		} else {
+			fn, err := c.compileMethod(forms[nextIndex:])
+			if err != nil {
+				return nil, err
+			}
+			def.Methods = append(def.Methods, *fn)
+		}
+
+		if err := def.validate(); err != nil {
+			return nil, err
+		}
+
+		return func(env *frame) (Value, error) {
+			multiFn := def
+			multiFn.Methods = make([]Fn, len(def.Methods))
+			for i, method := range def.Methods {
+				method.Scope = env
+				multiFn.Methods[i] = method
+			}
+			return multiFn, nil
+		}, nil
+	}
+}
+
+// compileMethod compiles the body of a function with the arguments bound
+// to the first slots of its layout.
+func (c *compiler) compileMethod(spec []Value) (*Fn, error) {
+	if len(spec) < 1 {
+		return nil, fmt.Errorf("insufficient args (%d) for 'fn'", len(spec))
+	}
+
+	spec = unpackParams(spec)
+	fn := &Fn{Body: Module(spec[1:])}
+	if err := fn.parseArgSpec(spec[0]); err != nil {
+		return nil, err
+	}
+
+	inner := &compiler{
+		scope: c.scope,
+		locals: &layout{
+			names:  append([]string(nil), fn.Args...),
+			parent: c.locals,
+		},
+		recur: &recurTarget{arity: len(fn.Args)},
+		tail:  true,
+	}
+
+	body, err := inner.compileSeq(spec[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	fn.locals, fn.code = inner.locals, body
+	return fn, nil
+}
@@ -0,0 +1,412 @@
+package internal
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name    string
+		src     string
+		want    Value
+		wantErr bool
+		// compiledOnly skips cases the interpreter does not handle.
+		compiledOnly bool
+	}{
+		{
+			name: "ShadowedLocal",
+			src:  `(let* [x 1 x (+ x 1)] x)`,
+			want: Number(2),
+		},
+		{
+			name: "NestedLet",
+			src:  `(let* [x 1] (let* [y 2] (let* [z 3] (+ x y z))))`,
+			want: Number(6),
+		},
+		{
+			name: "Closure",
+			src: `(def make-adder (fn* [n] (fn* [x] (+ x n))))
+				  (let* [add (make-adder 2)] (add 3))`,
+			want: Number(5),
+		},
+		{
+			name: "ClosureOverLet",
+			src: `(def f (let* [a 10] (fn* [x] (let* [b 1] (+ a b x)))))
+				  (f 1)`,
+			want: Number(12),
+		},
+		{
+			name: "Variadic",
+			src: `(def f (fn* [a & rest] rest))
+				  (f 1 2 3)`,
+			want: &List{Values: Values{Number(2), Number(3)}},
+		},
+		{
+			name: "Loop",
+			src: `(loop [i 0 acc 0]
+					(if (< i 10) (recur (+ i 1) (+ acc i)) acc))`,
+			want: Number(45),
+		},
+		{
+			name: "LoopClosure",
+			src: `(loop [i 0 f nil]
+					(if (< i 3) (recur (+ i 1) (fn* [] i)) (f)))`,
+			want:         Number(2),
+			compiledOnly: true,
+		},
+		{
+			name:         "LoopRecurArity",
+			src:          `(loop [i 0] (recur 1 2))`,
+			wantErr:      true,
+			compiledOnly: true,
+		},
+		{
+			name:         "RecurNotTail",
+			src:          `(loop [i 0] (+ 1 (recur i)))`,
+			wantErr:      true,
+			compiledOnly: true,
+		},
+		{
+			name:         "RecurAcrossTry",
+			src:          `(loop [i 0] (try (recur (+ i 1))))`,
+			wantErr:      true,
+			compiledOnly: true,
+		},
+		{
+			name: "RecurThroughFexpr",
+			src:  `(loop [i 0] (case (< i 5) true (recur (+ i 1)) false i))`,
+			want: Number(5),
+		},
+		{
+			name:    "RecurFexprNotTail",
+			src:     `(loop [i 0] (+ 1 (case i 0 (recur 1))))`,
+			wantErr: true,
+		},
+		{
+			name:    "RecurTopLevel",
+			src:     `(recur 1)`,
+			wantErr: true,
+		},
+		{
+			name: "RecurQuoted",
+			src:  `(def f (fn* [] '(recur 1))) (f)`,
+			want: &List{Values: Values{Symbol{Value: "recur"}, Number(1)}},
+		},
+		{
+			name: "FnRecur",
+			src: `(def sum (fn* [n acc] (if (< n 1) acc (recur (- n 1) (+ acc n)))))
+				  (sum 100 0)`,
+			want: Number(5050),
+		},
+		{
+			name: "GlobalRedefined",
+			src: `(def f (fn* [] 1))
+				  (def g (fn* [] (f)))
+				  (g)
+				  (def f (fn* [] 2))
+				  (g)`,
+			want: Number(2),
+		},
+		{
+			name: "MacroDefinedAfterUse",
+			src: `(def g (fn* [] (m)))
+				  (def m (macro* [] 42))
+				  (g)`,
+			want: Number(42),
+		},
+		{
+			name: "EvalInDifferentLocals",
+			src: `(def form '(+ x 1))
+				  (+ (let* [x 1] (eval form))
+				     (let* [y 0 x 10] (eval form)))`,
+			want: Number(13),
+		},
+		{
+			name: "DynamicBinding",
+			src: `(def f (fn* [xs] (doseq [x xs] x) x))
+				  (f [1 2 3])`,
+			want: Number(3),
+		},
+		{
+			name: "SwapLocal",
+			src: `(let* [x 10]
+					(let* [] (unsafe/swap x 1000))
+					x)`,
+			want: Number(1000),
+		},
+		{
+			name: "Collections",
+			src: `(let* [a 1]
+					[a {:k a} #{a}])`,
+			want: NewVector().Conj(
+				Number(1),
+				NewHashMap().Set(Keyword("k"), Number(1)),
+				NewSet().Conj(Number(1)),
+			),
+		},
+		{
+			name: "Try",
+			src: `(try (throw "failed")
+					   (fn* [err] :caught))`,
+			want: Keyword("caught"),
+		},
+		{
+			name: "TryCatch",
+			src: `(try (throw "failed" :my/error)
+					   (catch TypeError err :type)
+					   (catch :my/error err (ex-message err)))`,
+			want: String("failed"),
+		},
+		{
+			name: "TryCatchKind",
+			src: `(try (+ 1 "a")
+					   (catch :default err :default)
+					   (catch TypeError err :type))`,
+			want: Keyword("default"),
+		},
+		{
+			name: "TryFinally",
+			src: `(def cleaned false)
+				  (try (throw "failed")
+					   (catch ResolveError err :resolve)
+					   (catch Exception err :exception)
+					   (finally (def cleaned true)))
+				  cleaned`,
+			want: Bool(true),
+		},
+		{
+			name:    "TryUncaught",
+			src:     `(try (throw "failed") (catch TypeError err :type))`,
+			wantErr: true,
+		},
+		{
+			name: "DestructureSeq",
+			src:  `(let* [[a [b] & more :as all] [1 [2] 3 4]] [a b more all])`,
+			want: NewVector().Conj(
+				Number(1), Number(2),
+				NewVector().Conj(Number(3), Number(4)),
+				NewVector().Conj(Number(1), NewVector().Conj(Number(2)), Number(3), Number(4)),
+			),
+		},
+		{
+			name: "DestructureMap",
+			src: `(let* [{:keys [x y] :or {y 2} z :z :as m} {:x 1 :z 3}]
+					[x y z (:z m)])`,
+			want: NewVector().Conj(Number(1), Number(2), Number(3), Number(3)),
+		},
+		{
+			name: "DestructureMapNestedVector",
+			src:  `(let* [{[b c] :bc [d & more] :ds} {:bc [2 3] :ds [4 5 6]}] [b c d more])`,
+			want: NewVector().Conj(Number(2), Number(3), Number(4), NewVector().Conj(Number(5), Number(6))),
+		},
+		{
+			name: "DestructureMapNestedMap",
+			src: `(let* [{{x :x {:keys [y]} :q} :p :as m} {:p {:x 1 :q {:y 2}}}]
+					[x y (:x (:p m))])`,
+			want: NewVector().Conj(Number(1), Number(2), Number(1)),
+		},
+		{
+			name: "DestructureRestKeys",
+			src:  `(let* [[x & {:keys [a b] :or {b 9}}] [1 :a 2]] [x a b])`,
+			want: NewVector().Conj(Number(1), Number(2), Number(9)),
+		},
+		{
+			name: "DestructureRestKeysNone",
+			src:  `(let* [[x & {:keys [a] :or {a 9}}] [1]] [x a])`,
+			want: NewVector().Conj(Number(1), Number(9)),
+		},
+		{
+			name: "DestructureRestKeysParams",
+			src:  `(let* [f (fn* [[x & {:keys [a]}]] [x a])] (f (quote (1 :a 2))))`,
+			want: NewVector().Conj(Number(1), Number(2)),
+		},
+		{
+			name: "DestructureParams",
+			src: `(def f (fn* [[a b] & {:keys [c]}] (+ a b c)))
+				  (f [1 2] :c 3)`,
+			want: Number(6),
+		},
+		{
+			name: "DestructureLoop",
+			src: `(loop [[x & xs] [1 2 3] acc 0]
+					(if x (recur xs (+ acc x)) acc))`,
+			want: Number(6),
+		},
+		{
+			name:    "DestructureInvalid",
+			src:     `(let* [1 2] 1)`,
+			wantErr: true,
+		},
+		{
+			name: "LazyFailureCaught",
+			src: `(def s (lazy-map* (fn* [x] (throw "boom")) (lazy-range* 0 10 1)))
+				  (try (s.First) (catch :default e :caught))`,
+			want: Keyword("caught"),
+		},
+		{
+			name:    "LazyFailureInGoFn",
+			src:     `(doseq [x (lazy-map* (fn* [x] (throw "boom")) (lazy-range* 0 10 1))] x)`,
+			wantErr: true,
+		},
+		{
+			name: "LazyNested",
+			src:  `(def s (lazy-seq* (fn* [] (lazy-seq* (fn* [] [1 2]))))) (s.Size)`,
+			want: Number(2),
+		},
+		{
+			name:    "UnboundSymbol",
+			src:     `(let* [x 1] y)`,
+			wantErr: true,
+		},
+		{
+			name:    "MacroAsValue",
+			src:     `(def m (macro* [] 1)) (let* [x m] x)`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range table {
+		for _, interpret := range []bool{false, true} {
+			if interpret && tt.compiledOnly {
+				continue
+			}
+
+			name := tt.name + "/Compiled"
+			if interpret {
+				name = tt.name + "/Interpreted"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				sp := NewSpirit()
+				sp.interpret = interpret
+				_ = sp.Bind("let*", Let)
+
+				got, err := sp.ReadEvalStr(tt.src)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("ReadEvalStr() error = %v, wantErr %v", err, tt.wantErr)
+				}
+
+				if !tt.wantErr && !Compare(got, tt.want) {
+					t.Errorf("ReadEvalStr() got = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+var benchmarks = []struct {
+	name  string
+	setup string
+	src   string
+}{
+	{
+		name:  "Fib",
+		setup: `(defn fib [n] (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))`,
+		src:   `(fib 15)`,
+	},
+	{
+		name: "Loop",
+		src: `(loop [i 0 acc 0]
+				(if (< i 1000) (recur (inc i) (+ acc i)) acc))`,
+	},
+	{
+		name: "Locals",
+		setup: `(defn dist [x1 y1 x2 y2]
+				  (let [dx (- x2 x1)
+						dy (- y2 y1)]
+					(sqrt (+ (* dx dx) (* dy dy)))))`,
+		src: `(loop [i 0 acc 0]
+				(if (< i 500) (recur (inc i) (+ acc (dist i 0 0 i))) acc))`,
+	},
+	{
+		name: "Seq",
+		src:  `(reduce + 0 (map inc (filter even? (range 500))))`,
+	},
+	{
+		name: "HashMap",
+		setup: `(defn index [xs]
+				  (reduce (fn [acc x] (assoc acc x (* x x))) {} xs))`,
+		src: `(count (index (range 300)))`,
+	},
+}
+
+// TestEval_Workloads checks the benchmark workloads evaluate to the same
+// values whether walked or compiled, so BenchmarkEval compares the two.
+func TestEval_Workloads(t *testing.T) {
+	for _, bm := range benchmarks {
+		t.Run(bm.name, func(t *testing.T) {
+			var results []Value
+			for _, interpret := range []bool{true, false} {
+				sp := loadCore(t, interpret)
+				if _, err := sp.ReadEvalStr(bm.setup); err != nil {
+					t.Fatalf("setup failed: %v", err)
+				}
+
+				got, err := sp.ReadEvalStr(bm.src)
+				if err != nil {
+					t.Fatalf("ReadEvalStr() unexpected error (interpret %v): %v", interpret, err)
+				}
+				results = append(results, got)
+			}
+
+			if !Compare(results[0], results[1]) {
+				t.Errorf("interpreted = %v, compiled = %v", results[0], results[1])
+			}
+		})
+	}
+}
+
+// BenchmarkEval compares evaluating common workloads by walking the forms
+// against running the compiled code.
+func BenchmarkEval(b *testing.B) {
+	for _, bm := range benchmarks {
+		for _, interpret := range []bool{true, false} {
+			name := bm.name + "/Compiled"
+			if interpret {
+				name = bm.name + "/Interpreted"
+			}
+
+			b.Run(name, func(b *testing.B) {
+				sp := loadCore(b, interpret)
+				if _, err := sp.ReadEvalStr(bm.setup); err != nil {
+					b.Fatalf("setup failed: %v", err)
+				}
+
+				form, err := NewReader(strings.NewReader(bm.src)).All()
+				if err != nil {
+					b.Fatalf("failed to read source: %v", err)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := sp.Eval(form); err != nil {
+						b.Fatalf("Eval() unexpected error: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// loadCore returns an instance with the core library loaded, evaluating
+// lists by walking the forms if interpret is set.
+func loadCore(tb testing.TB, interpret bool) *Spirit {
+	tb.Helper()
+
+	core, err := os.ReadFile("../lib/core.st")
+	if err != nil {
+		tb.Fatalf("failed to read core library: %v", err)
+	}
+
+	sp := NewSpirit()
+	sp.interpret = interpret
+	if _, err := sp.ReadEval(strings.NewReader(string(core))); err != nil {
+		tb.Fatalf("failed to load core library: %v", err)
+	}
+	_ = sp.SwitchNS(Symbol{Value: defaultNS})
+	return sp
+}
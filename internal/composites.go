@@ -1,8 +1,11 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/xiaq/persistent/hash"
 	"github.com/xiaq/persistent/hashmap"
@@ -20,22 +23,40 @@ type List struct {
 	Position
 
 	special *Fn
+	// compiled holds the *compiledList of the last compilation. It is
+	// replaced atomically as the list may be evaluated by several futures.
+	compiled atomic.Value
+	hash     uint32
 }
 
-// Eval performs an invocation.
+// Eval performs an invocation. The list is compiled on the first
+// evaluation and the compiled code is reused afterwards.
 func (lf *List) Eval(scope Scope) (Value, error) {
 	if lf.Size() == 0 {
 		return lf, nil
 	}
 
-	err := lf.parse(scope)
+	env := frameOf(scope)
+	if env == nil {
+		return nil, fmt.Errorf("InternalError: cannot find root scope")
+	}
+
+	if env.root.interpret {
+		return lf.interpret(scope)
+	}
+
+	run, err := lf.compile(env)
 	if err != nil {
 		return nil, err
 	}
 
-	fnCall := Call{
-		Name:     lf.Values[0].String(),
-		Position: lf.Position,
+	return run(env)
+}
+
+// interpret performs the invocation by walking the forms.
+func (lf *List) interpret(scope Scope) (Value, error) {
+	if err := checkContext(scope); err != nil {
+		return nil, err
 	}
 
 	root := RootScope(scope)
@@ -44,14 +65,32 @@ func (lf *List) Eval(scope Scope) (Value, error) {
 		return nil, fmt.Errorf("InternalError: cannot find root scope")
 	}
 
+	exec := executionOf(scope)
+	stack := exec.stack
+
+	err := lf.parse(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	fnCall := Call{
+		Name:     lf.Values[0].String(),
+		Position: lf.Position,
+	}
+
 	if lf.special != nil {
-		spirit.Push(fnCall)
-		val, err := lf.special.Invoke(scope, lf.Values[1:]...)
+		if err := exec.enter(spirit); err != nil {
+			return nil, newEvalErr(lf, err)
+		}
+
+		stack.Push(fnCall)
+		val, err := invokeForms(scope, lf.special, lf.Values[1:])
+		exec.leave()
 		if err != nil {
 			err = newEvalErr(lf, err)
-			return nil, addStackTrace(spirit.Stack, err)
+			return nil, addStackTrace(*stack, err)
 		}
-		spirit.Pop()
+		stack.Pop()
 		return val, nil
 	}
 
@@ -68,13 +107,18 @@ func (lf *List) Eval(scope Scope) (Value, error) {
 		}
 	}
 
-	spirit.Push(fnCall)
-	val, err := invokable.Invoke(scope, lf.Values[1:]...)
+	if err := exec.enter(spirit); err != nil {
+		return nil, newEvalErr(lf, err)
+	}
+
+	stack.Push(fnCall)
+	val, err := invokeForms(scope, invokable, lf.Values[1:])
+	exec.leave()
 	if err != nil {
 		err = newEvalErr(lf, err)
-		return nil, addStackTrace(spirit.Stack, err)
+		return nil, addStackTrace(*stack, err)
 	}
-	spirit.Pop()
+	stack.Pop()
 
 	return val, nil
 }
@@ -83,6 +127,11 @@ func (lf List) String() string {
 	return containerString(lf.Values, "(", ")", " ")
 }
 
+// Hash returns the hash of the values in order, the same as a vector of them.
+func (lf *List) Hash() uint32 {
+	return cachedHash(&lf.hash, func() uint32 { return hashSeq(lf.Values) })
+}
+
 func (lf *List) parse(scope Scope) error {
 	if lf.Size() == 0 {
 		return nil
@@ -98,6 +147,7 @@ func (lf *List) parse(scope Scope) error {
 			Symbol{Value: "do"},
 			form,
 		}
+		atomic.StoreUint32(&lf.hash, 0)
 	}
 
 	special, err := resolveSpecial(scope, lf.First())
@@ -109,46 +159,157 @@ func (lf *List) parse(scope Scope) error {
 
 	fn, err := special.Parse(scope, lf.Values[1:])
 	if err != nil {
-		return fmt.Errorf("%s: %v", special.Name, err)
+		return fmt.Errorf("%s: %w", special.Name, err)
 	}
 	lf.special = fn
 	return nil
 }
 
-// Set represents a list of unique values. (Experimental)
+// Set is a persistent set of unique values. Like HashMap, it does not
+// mutate on change and shares structure with the set it was derived from.
+// Values are unique by Compare, so 1 and 1.0 are the same member.
 type Set struct {
-	Values
 	Position
+	Data hashmap.Map
+	hash uint32
+}
+
+// NewSet returns an empty set.
+func NewSet() *Set {
+	return &Set{Data: emptyMap}
+}
+
+func (set *Set) SetPosition(pos Position) *Set {
+	set.Position = pos
+	return set
 }
 
 // Eval evaluates each value in the set form and returns the resultant
 // values as new set.
-func (set Set) Eval(scope Scope) (Value, error) {
-	vals, err := EvalValueList(scope, set.Uniq())
+func (set *Set) Eval(scope Scope) (Value, error) {
+	vals, err := EvalValueList(scope, set.values())
 	if err != nil {
 		return nil, err
 	}
 
-	return Set{Values: Values(vals).Uniq()}, nil
+	return NewSet().Conj(vals...), nil
 }
 
-func (set Set) String() string {
-	return containerString(set.Values, "#{", "}", " ")
+func (set *Set) String() string {
+	return containerString(set.values(), "#{", "}", " ")
 }
 
-// TODO: Remove this naive solution
-func (set Set) valid() bool {
-	s := map[string]struct{}{}
+// Size returns the number of members.
+func (set *Set) Size() int {
+	return set.Data.Len()
+}
 
-	for _, v := range set.Values {
-		str := v.String()
-		if _, found := s[str]; found {
-			return false
-		}
-		s[v.String()] = struct{}{}
+// First returns a member of the set. Like HashMap, the order of members is
+// unstable.
+func (set *Set) First() Value {
+	it := set.Data.Iterator()
+	if !it.HasElem() {
+		return nil
 	}
 
-	return true
+	k, _ := it.Elem()
+	return k.(Value)
+}
+
+func (set *Set) Next() Seq {
+	if set.Size() < 2 {
+		return nil
+	}
+
+	k, _ := set.Data.Iterator().Elem()
+	return &Set{Data: set.Data.Dissoc(k)}
+}
+
+// Cons returns a new set with v as member.
+func (set *Set) Cons(v Value) Seq {
+	return set.Conj(v)
+}
+
+// Conj returns a new set with the values as members.
+func (set *Set) Conj(vals ...Value) Seq {
+	if len(vals) == 1 {
+		return &Set{Data: set.Data.Assoc(vals[0], vals[0]), Position: set.Position}
+	}
+
+	data := newTransientMap(set.Data)
+	for _, v := range vals {
+		data.assoc(v, v)
+	}
+	return &Set{Data: data.persistent(), Position: set.Position}
+}
+
+// Disj returns a new set without the values.
+func (set *Set) Disj(vals ...Value) *Set {
+	data := set.Data
+	for _, v := range vals {
+		data = data.Dissoc(v)
+	}
+	return &Set{Data: data, Position: set.Position}
+}
+
+// Contains returns true if v is a member of the set.
+func (set *Set) Contains(v Value) bool {
+	_, found := set.Data.Index(v)
+	return found
+}
+
+// Get returns the member equal to v, nil if there is none.
+func (set *Set) Get(v Value) Value {
+	member, found := set.Data.Index(v)
+	if !found {
+		return nil
+	}
+	return member.(Value)
+}
+
+// Invoke returns the member equal to the argument, or the default value
+// if it is not a member.
+func (set *Set) Invoke(scope Scope, args ...Value) (Value, error) {
+	vals, err := EvalValueList(scope, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return set.invokeValues(scope, vals)
+}
+
+// invokeValues is like Invoke but the arguments are already evaluated.
+func (set *Set) invokeValues(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+		return nil, err
+	}
+
+	if member := set.Get(args[0]); member != nil {
+		return member, nil
+	} else if len(args) == 2 {
+		return args[1], nil
+	}
+	return Nil{}, nil
+}
+
+// Compare returns true if the other value is a set with the same members.
+func (set *Set) Compare(other Value) bool {
+	return compareSets(set, other)
+}
+
+// Hash returns the hash of the members regardless of their order.
+func (set *Set) Hash() uint32 {
+	return cachedHash(&set.hash, func() uint32 { return hashUnordered(set.values()) })
+}
+
+// values returns the members of the set.
+func (set *Set) values() []Value {
+	vals := make([]Value, 0, set.Size())
+	for it := set.Data.Iterator(); it.HasElem(); it.Next() {
+		k, _ := it.Elem()
+		vals = append(vals, k.(Value))
+	}
+	return vals
 }
 
 // Module represents a group of forms. Evaluating a module leads to evaluation
@@ -194,10 +355,11 @@ func (mod Module) String() string { return containerString(mod, "", "\n", "\n")
 type HashMap struct {
 	Position
 	Data hashmap.Map
+	hash uint32
 }
 
 func NewHashMap() *HashMap {
-	return &HashMap{Data: hashmap.New(compare, hasher)}
+	return &HashMap{Data: emptyMap}
 }
 
 func (hm *HashMap) Set(k, v Value) Value {
@@ -255,27 +417,40 @@ func (hm *HashMap) Cons(v Value) Seq {
 }
 
 func (hm *HashMap) Conj(vals ...Value) Seq {
-	var h Seq = hm
+	if len(vals) == 1 {
+		return hm.Cons(vals[0])
+	}
+
+	data := newTransientMap(hm.Data)
 	for _, v := range vals {
-		h = h.Cons(v)
+		if vec, ok := v.(*Vector); ok && vec.Size() == 2 {
+			data.assoc(vec.Index(0), vec.Index(1))
+		}
 	}
-	return h
+	return &HashMap{Data: data.persistent()}
 }
 
 func (hm *HashMap) Invoke(scope Scope, args ...Value) (Value, error) {
+	vals, err := EvalValueList(scope, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return hm.invokeValues(scope, vals)
+}
 
+// invokeValues is like Invoke but the arguments are already evaluated.
+func (hm *HashMap) invokeValues(_ Scope, args []Value) (Value, error) {
 	if len(args) < 1 || len(args) > 2 {
 		return nil, fmt.Errorf("invoking hash map requires 1 or 2 arguments")
 	}
 
-	key := args[0]
-	value := hm.Get(key)
-
-	if len(args) == 2 && value == nil {
+	if value, found := hm.lookup(args[0]); found {
 		return value, nil
+	} else if len(args) == 2 {
+		return args[1], nil
 	}
-
-	return value, nil
+	return Nil{}, nil
 }
 
 func (hm *HashMap) Delete(k Value) *HashMap {
@@ -285,35 +460,33 @@ func (hm *HashMap) Delete(k Value) *HashMap {
 // Compare implements Comparable. It compares each key and value recursively,
 // note that order is not important when comparing
 func (hm *HashMap) Compare(other Value) bool {
+	return compareMaps(hm, other)
+}
 
-	otherMap, ok := other.(*HashMap)
-	if !ok {
-		return false
-	}
+// Hash returns the hash of the entries regardless of their order.
+func (hm *HashMap) Hash() uint32 {
+	return cachedHash(&hm.hash, func() uint32 { return hashEntries(hm) })
+}
 
-	if otherMap.Data.Len() != hm.Data.Len() {
-		return false
+func (hm *HashMap) lookup(k Value) (Value, bool) {
+	v, found := hm.Data.Index(k)
+	if !found {
+		return nil, false
 	}
+	return v.(Value), true
+}
 
+func (hm *HashMap) entries() []Value {
+	vals := make([]Value, 0, hm.Size())
 	for it := hm.Data.Iterator(); it.HasElem(); it.Next() {
-
-		k1, v1 := it.Elem()
-
-		v2, ok := otherMap.Data.Index(k1)
-		if !ok {
-			return false
-		}
-
-		if !compare(v1, v2) {
-			return false
-		}
+		k, v := it.Elem()
+		vals = append(vals, NewVector().Conj(k.(Value), v.(Value)))
 	}
-
-	return true
+	return vals
 }
 
 func (hm *HashMap) Eval(scope Scope) (Value, error) {
-	res := &HashMap{Data: hashmap.New(compare, hasher)}
+	res := newTransientMap(emptyMap)
 
 	for it := hm.Data.Iterator(); it.HasElem(); it.Next() {
 		k, v := it.Elem()
@@ -328,10 +501,10 @@ func (hm *HashMap) Eval(scope Scope) (Value, error) {
 			return nil, err
 		}
 
-		res.Data = res.Data.Assoc(key, value)
+		res.assoc(key, value)
 	}
 
-	return res, nil
+	return &HashMap{Data: res.persistent()}, nil
 }
 
 func (hm HashMap) PrettyPrint(indent int) string {
@@ -383,11 +556,12 @@ func (hm HashMap) String() string {
 
 type Vector struct {
 	Position
-	Vec vector.Vector
+	Vec  vector.Vector
+	hash uint32
 }
 
 func NewVector() *Vector {
-	return &Vector{Vec: vector.Empty}
+	return &Vector{Vec: emptyVector}
 }
 
 func (p *Vector) Eval(scope Scope) (Value, error) {
@@ -460,6 +634,17 @@ func (p *Vector) Conj(vals ...Value) Seq {
 		Vec:      p.Vec,
 		Position: p.Position,
 	}
+
+	// a transient of a sub vector would copy it
+	if _, ok := p.Vec.(*pvector); ok && len(vals) > 1 {
+		vec := newTransientVector(p.Vec)
+		for _, v := range vals {
+			vec.conj(v)
+		}
+		pv.Vec = vec.persistent()
+		return pv
+	}
+
 	for _, v := range vals {
 		pv.Vec = pv.Vec.Cons(v)
 	}
@@ -480,14 +665,20 @@ func (p *Vector) Cons(v Value) Seq {
 }
 
 func (p *Vector) Set(i Value, v Value) Value {
+	index, _ := toIndex(i)
 	return &Vector{
-		Vec:      p.Vec.Assoc(int(i.(Number)), v),
+		Vec:      p.Vec.Assoc(index, v),
 		Position: p.Position,
 	}
 }
 
 func (p *Vector) Get(i Value) Value {
-	value, ok := p.Vec.Index(int(i.(Number)))
+	index, isInt := toIndex(i)
+	if !isInt {
+		return nil
+	}
+
+	value, ok := p.Vec.Index(index)
 	if !ok {
 		return nil
 	}
@@ -515,12 +706,12 @@ func (p *Vector) SetPosition(pos Position) *Vector {
 }
 
 // Compare implements Comparable which recursively compare values between
-// other value. Order is important
+// other value. Order is important, a list of the same values is equal.
 func (p *Vector) Compare(other Value) bool {
 
 	pv2, ok := other.(*Vector)
 	if !ok {
-		return false
+		return isSequential(other) && compareSeq(p, other)
 	}
 
 	if p.Size() != pv2.Size() {
@@ -542,6 +733,11 @@ func (p *Vector) Compare(other Value) bool {
 	return true
 }
 
+// Hash returns the hash of the values in order, the same as a list of them.
+func (p *Vector) Hash() uint32 {
+	return cachedHash(&p.hash, func() uint32 { return hashSeq(p) })
+}
+
 func (p *Vector) Size() int {
 	return p.Vec.Len()
 }
@@ -560,17 +756,20 @@ func (p *Vector) Invoke(scope Scope, args ...Value) (Value, error) {
 		return nil, err
 	}
 
+	return p.invokeValues(scope, vals)
+}
+
+// invokeValues is like Invoke but the arguments are already evaluated.
+func (p *Vector) invokeValues(_ Scope, vals []Value) (Value, error) {
 	if len(vals) != 1 {
 		return nil, fmt.Errorf("call requires exactly 1 argument, got %d", len(vals))
 	}
 
-	index, isInt := vals[0].(Number)
+	i, isInt := toIndex(vals[0])
 	if !isInt {
 		return nil, fmt.Errorf("key must be integer")
 	}
 
-	i := int(index)
-
 	if i >= p.Size() {
 		return nil, fmt.Errorf("index out of bounds")
 	}
@@ -605,6 +804,13 @@ func (s Stack) Size() int {
 	return len(s)
 }
 
+// unwind pops the calls above the given size of the stack.
+func (s *Stack) unwind(size int) {
+	if size < s.Size() {
+		*s = (*s)[:size]
+	}
+}
+
 // Pops removes function call from Stack
 func (s *Stack) Pop() Call {
 
@@ -636,25 +842,89 @@ func (s *Stack) StackTrace() string {
 	return str.String()
 }
 
+// Future is the result of an evaluation running in another goroutine.
+// Channel is closed once the future is done, Realized and Value must not be
+// read before.
 type Future struct {
 	Realized bool
 	Value    Value
 	Channel  chan Value
+
+	// err is the error the evaluation failed with.
+	err    error
+	once   sync.Once
+	cancel context.CancelFunc
 }
 
+// Submit evaluates the form in a new goroutine. The error of a failed
+// evaluation is kept to be returned by deref.
 func (c *Future) Submit(scope Scope, form Value) {
 	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.finish(nil, newEvalErr(form, panicError(r)))
+			}
+		}()
+
 		val, err := form.Eval(scope)
 		if err != nil {
-			panic(err)
+			err = newEvalErr(form, err)
 		}
-		c.Value = val
-		c.Realized = true
+		c.finish(val, err)
 	}()
 }
 
-func (c Future) String() string {
-	return fmt.Sprintf("<Future(realized: %v value: %v)>", c.Realized, c.Value)
+// finish completes the future with the result of the evaluation. Returns
+// false if the future has already been completed or cancelled.
+func (c *Future) finish(val Value, err error) bool {
+	finished := false
+	c.once.Do(func() {
+		if err == nil {
+			c.Value = val
+			c.Realized = true
+		}
+		c.err = err
+		close(c.Channel)
+		finished = true
+	})
+
+	if finished && c.cancel != nil {
+		c.cancel()
+	}
+	return finished
+}
+
+// Cancel stops the evaluation of the future. Returns false if the future
+// is already done.
+func (c *Future) Cancel() bool {
+	return c.finish(nil, CancelError{})
+}
+
+// Result returns the value of the future or the error its evaluation
+// failed with. It must not be called before the future is done.
+func (c *Future) Result() (Value, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.Value, nil
+}
+
+// done returns true if the future has completed or has been cancelled.
+func (c *Future) done() bool {
+	select {
+	case <-c.Channel:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Future) String() string {
+	realized, value := false, Value(Nil{})
+	if c.done() {
+		realized, value = c.Realized, c.Value
+	}
+	return fmt.Sprintf("<Future(realized: %v value: %v)>", realized, value)
 }
 
 func (c *Future) Eval(_ Scope) (Value, error) {
@@ -671,7 +941,7 @@ type LazySeq struct {
 func (l LazySeq) First() Value {
 
 	if l.Min >= l.Max {
-		return ValueOf(nil)
+		return nil
 	}
 
 	return ValueOf(l.Min)
@@ -716,7 +986,18 @@ func (l LazySeq) Conj(v ...Value) Seq {
 }
 
 func (l LazySeq) Size() int {
-	return ((l.Max - l.Min) / l.Step)
+	if l.Min >= l.Max {
+		return 0
+	}
+	return (l.Max - l.Min + l.Step - 1) / l.Step
+}
+
+// Compare returns true if the other value is a sequence of the same values.
+func (l LazySeq) Compare(other Value) bool {
+	if r, ok := other.(LazySeq); ok && r == l {
+		return true
+	}
+	return compareSeq(l, other)
 }
 
 func (l LazySeq) String() string {
@@ -882,6 +1163,19 @@ func (o Object) Eval(_ Scope) (Value, error) {
 	return o, nil
 }
 
+// Compare returns true if the other value is an object of the same class
+// with equal members.
+func (o Object) Compare(other Value) bool {
+	obj, ok := other.(Object)
+	return ok && obj.InstanceOf.Name == o.InstanceOf.Name &&
+		o.Members.Compare(obj.Members)
+}
+
+// Hash returns the hash of the class name and the members.
+func (o Object) Hash() uint32 {
+	return hash.DJB(hash.String(o.InstanceOf.Name), o.Members.Hash())
+}
+
 func (o Object) Set(key, value Value) Value {
 	return Object{
 		InstanceOf: o.InstanceOf,
@@ -958,11 +1252,14 @@ func (o Object) GetMethod(name Keyword) (Invokable, bool) {
 // ------------------ helper functions ---------------------------
 
 func hasher(s interface{}) uint32 {
-	return hash.String(s.(Value).String())
+	v, _ := s.(Value)
+	return hashOf(v)
 }
 
 func compare(k1, k2 interface{}) bool {
-	return Compare(k1.(Value), k2.(Value))
+	v1, _ := k1.(Value)
+	v2, _ := k2.(Value)
+	return Compare(v1, v2)
 }
 
 func containerString(vals []Value, begin, end, sep string) string {
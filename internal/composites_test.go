@@ -10,7 +10,7 @@ import (
 
 var (
 	_ internal.Seq   = &internal.List{}
-	_ internal.Seq   = internal.Set{}
+	_ internal.Seq   = &internal.Set{}
 	_ internal.Value = &internal.Future{}
 )
 
@@ -129,34 +129,34 @@ func TestSet_Eval(t *testing.T) {
 	executeEvalTests(t, []evalTestCase{
 		{
 			name:  "Empty",
-			value: internal.Set{},
-			want:  internal.Set{},
+			value: internal.NewSet(),
+			want:  internal.NewSet(),
 		},
 		{
 			name: "ValidWithoutDuplicates",
 			getScope: func() internal.Scope {
 				return internal.NewScope(nil)
 			},
-			value: internal.Set{Values: []internal.Value{internal.String("hello")}},
-			want:  internal.Set{Values: []internal.Value{internal.String("hello")}},
+			value: internal.NewSet().Conj(internal.String("hello")),
+			want:  internal.NewSet().Conj(internal.String("hello")),
 		},
 		{
 			name: "ValidWithtDuplicates",
 			getScope: func() internal.Scope {
 				return internal.NewScope(nil)
 			},
-			value: internal.Set{Values: []internal.Value{
+			value: internal.NewSet().Conj(
 				internal.String("hello"),
 				internal.String("hello"),
-			}},
-			want: internal.Set{Values: []internal.Value{internal.String("hello")}},
+			),
+			want: internal.NewSet().Conj(internal.String("hello")),
 		},
 		{
 			name: "Failure",
 			getScope: func() internal.Scope {
 				return internal.NewScope(nil)
 			},
-			value:   internal.Set{Values: []internal.Value{internal.Symbol{Value: "hello"}}},
+			value:   internal.NewSet().Conj(internal.Symbol{Value: "hello"}),
 			wantErr: true,
 		},
 	})
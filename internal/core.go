@@ -1,7 +1,7 @@
 package internal
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -60,7 +60,19 @@ func macroExpand(scope Scope, f Value) (Value, error) {
 
 // Throw converts args to strings and returns an error with all the strings
 // joined.
-func throw(scope Scope, args ...Value) error {
+func throw(scope Scope, vals ...interface{}) error {
+	// rethrow exceptions created by ex-info and errors caught by try
+	if len(vals) == 1 {
+		if err, ok := vals[0].(error); ok {
+			return err
+		}
+	}
+
+	args := make([]Value, len(vals))
+	for i, v := range vals {
+		args[i] = ValueOf(v)
+	}
+
 	if err := verifyArgCount([]int{1, 2}, args); err != nil {
 		return err
 	}
@@ -89,16 +101,94 @@ func throw(scope Scope, args ...Value) error {
 	}
 }
 
-func errorIs(keyword Keyword, err EvalError) Bool {
-	exception, ok := err.Cause.(Exception)
-
-	if !ok {
+func errorIs(keyword Keyword, err error) Bool {
+	var exception Exception
+	if !errors.As(err, &exception) || exception.id == nil {
 		return false
 	}
 
 	return *exception.id == keyword
 }
 
+// exInfo creates an exception with the message, a map of data or nil and
+// the error which caused it, if given. The exception can be caught by the
+// keyword under :type in the data.
+func exInfo(message String, data Value, cause ...error) (Value, error) {
+	if len(cause) > 1 {
+		return nil, ArgumentError{
+			Got: len(cause) + 2,
+			Fn:  "ex-info",
+		}
+	}
+
+	exception := Exception{
+		message: string(message),
+	}
+
+	switch m := data.(type) {
+	case Nil:
+
+	case Assoc:
+		exception.data = m
+		if id, ok := m.Get(Keyword("type")).(Keyword); ok {
+			exception.id = &id
+		}
+
+	default:
+		return nil, TypeError{
+			Expected: NewHashMap(),
+			Got:      data,
+		}
+	}
+
+	if len(cause) == 1 {
+		exception.cause = cause[0]
+	}
+
+	return exception, nil
+}
+
+// exData returns the data of an exception created by ex-info, nil for
+// other errors.
+func exData(err error) Value {
+	var exception Exception
+	if !errors.As(err, &exception) || exception.data == nil {
+		return Nil{}
+	}
+
+	return exception.data
+}
+
+// exMessage returns the message of the error without the position and the
+// stack trace of the evaluation.
+func exMessage(err error) String {
+	var exception Exception
+	if errors.As(err, &exception) {
+		return String(exception.message)
+	}
+
+	for {
+		ee, ok := err.(EvalError)
+		if !ok {
+			break
+		}
+		err = ee.Cause
+	}
+
+	return String(err.Error())
+}
+
+// exCause returns the error which caused an exception created by ex-info,
+// nil for other errors.
+func exCause(err error) Value {
+	var exception Exception
+	if !errors.As(err, &exception) || exception.cause == nil {
+		return Nil{}
+	}
+
+	return ValueOf(exception.cause)
+}
+
 // Realize realizes a sequence by continuously calling First() and Next()
 // until the sequence becomes nil.
 func realize(seq Seq) *List {
@@ -153,6 +243,10 @@ func toType(to Type, val Value) (Value, error) {
 
 			return Number(fl), nil
 		}
+
+		if isNumber(val) {
+			return Number(toFloat(val)), nil
+		}
 	}
 
 	rv := reflect.ValueOf(val)
@@ -231,13 +325,52 @@ func doSeq(scope Scope, args []Value) (Value, error) {
 		}
 	}
 
-	symbol, ok := vecs.Index(0).(Symbol)
+	// a pattern is bound to a generated symbol which the body destructures
+	names, body, _ := hoistPatterns([]Value{vecs.Index(0)}, args[1:])
+	symbol, ok := names[0].(Symbol)
+	if !ok {
+		return nil, fmt.Errorf("cannot bind to %v, expecting symbol, vector or hash map", names[0])
+	}
+
 	var result Value
 
+	// values are taken from a channel until it is closed instead of
+	// traversing its sequence, so several evaluations can share the work.
+	if c, isChan := coll.(*Chan); isChan {
+		for {
+			v, more, err := c.take(contextOf(scope))
+			if err != nil {
+				return nil, err
+			}
+
+			if !more {
+				return result, nil
+			}
+
+			scope.Bind(symbol.Value, v)
+			for _, form := range body {
+				result, err = form.Eval(scope)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// an eduction transforms its values again for each call to First, so
+	// they are traversed once from a single transformation.
+	if e, isEduction := coll.(*Eduction); isEduction {
+		l = e.seq()
+	}
+
 	for curr := l; curr != nil && curr.First() != nil; curr = curr.Next() {
+		if err := checkContext(scope); err != nil {
+			return nil, err
+		}
+
 		scope.Bind(symbol.Value, curr.First())
-		for _, body := range args[1:] {
-			result, err = body.Eval(scope)
+		for _, form := range body {
+			result, err = form.Eval(scope)
 			if err != nil {
 				return nil, err
 			}
@@ -286,23 +419,6 @@ func swap(scope Scope, args []Value) (Value, error) {
 	return value, nil
 }
 
-// Returns '(recur & expressions) so it'll be recognize by fn.Invoke method as
-// tail recursive function
-func recur(scope Scope, args []Value) (Value, error) {
-
-	symbol := Symbol{
-		Value: "recur",
-	}
-
-	results, err := EvalValueList(scope, args)
-	if err != nil {
-		return nil, err
-	}
-
-	results = append([]Value{symbol}, results...)
-	return &List{Values: Values(results)}, nil
-}
-
 // Returns string representation of type
 func stringTypeOf(v interface{}) string {
 	return reflect.TypeOf(v).String()
@@ -311,36 +427,192 @@ func stringTypeOf(v interface{}) string {
 // Evaluate the expressions in another goroutine; returns chan
 func future(scope Scope, args []Value) (Value, error) {
 
+	// the future runs in its own execution which inherits the context of
+	// the spawning one, so cancelling the caller also stops the future.
+	ctx, cancel := context.WithCancel(contextOf(scope))
+
 	ch := &Future{
 		Channel: make(chan Value),
 		Value:   Nil{},
+		cancel:  cancel,
 	}
 
-	ch.Submit(scope, args[0])
+	futureScope := snapshot(scope)
+	futureScope.exec = newExecution(ctx)
+
+	ch.Submit(futureScope, args[0])
 
 	return ch, nil
 }
 
-type chanWrapper func(*Future) (Value, error)
+// Deref chan from future to get the value. This call is blocking until future
+// is resolved or the evaluation is cancelled. The error of a failed future is
+// returned instead of the value. Given a timeout in milliseconds and a default
+// value, the default value is returned if the future is not done in time.
+// Waiting with a timeout requires the time capability.
+func deref(scope Scope, ch *Future, args ...Value) (Value, error) {
+	var timeout <-chan time.Time
+	var dflt Value = Nil{}
 
-// Deref chan from future to get the value. This call is blocking until future is resolved.
-// The result will be cached.
-func deref(scope Scope) chanWrapper {
-	return func(ch *Future) (Value, error) {
-		for {
-			if ch.Realized {
-				return ch.Value, nil
+	switch len(args) {
+	case 0:
+	case 2:
+		if err := checkCapability(scope, "core/deref", CapTime); err != nil {
+			return nil, err
+		}
+
+		if !isNumber(args[0]) {
+			return nil, TypeError{
+				Expected: Number(0),
+				Got:      args[0],
 			}
 		}
+
+		ms := toFloat(args[0])
+		timer := time.NewTimer(time.Duration(ms * float64(time.Millisecond)))
+		defer timer.Stop()
+
+		timeout = timer.C
+		dflt = args[1]
+
+	default:
+		return nil, ArgumentError{
+			Got: len(args) + 1,
+			Fn:  "deref",
+		}
+	}
+
+	ctx := contextOf(scope)
+
+	select {
+	case <-ch.Channel:
+		return ch.Result()
+
+	case <-timeout:
+		return dflt, nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// futureCancel cancels the future unless it is done already. Returns true if
+// the future has been cancelled.
+func futureCancel(ch *Future) bool {
+	return ch.Cancel()
+}
+
+// futureDone returns true if the future has completed or has been cancelled.
+func futureDone(ch *Future) bool {
+	return ch.done()
+}
+
+// waitAny blocks until any of the futures is done and returns its value.
+// Values which are not futures are returned right away.
+func waitAny(scope Scope, futures Seq) (Value, error) {
+	ctx := contextOf(scope)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+
+	var pending []*Future
+	for seq := futures; seq != nil && seq.First() != nil; seq = seq.Next() {
+		ch, ok := seq.First().(*Future)
+		if !ok {
+			return seq.First(), nil
+		}
+
+		pending = append(pending, ch)
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(ch.Channel),
+		})
+	}
+
+	if len(pending) == 0 {
+		return Nil{}, nil
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == 0 {
+		return nil, ctx.Err()
 	}
+
+	return pending[chosen-1].Result()
 }
 
-func sleep(s int) {
-	time.Sleep(time.Millisecond * time.Duration(s))
+// waitAll blocks until all of the futures are done and returns their values
+// in order. Values which are not futures are returned as they are. It fails
+// as soon as any of the futures fails, without waiting for the others.
+func waitAll(scope Scope, futures Seq) (Value, error) {
+	ctx := contextOf(scope)
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+
+	// pending holds the index of the future of each case in vals
+	var vals []Value
+	var pending []int
+	for seq := futures; seq != nil && seq.First() != nil; seq = seq.Next() {
+		if ch, ok := seq.First().(*Future); ok {
+			pending = append(pending, len(vals))
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(ch.Channel),
+			})
+		}
+
+		vals = append(vals, seq.First())
+	}
+
+	for len(pending) > 0 {
+		chosen, _, _ := reflect.Select(cases)
+		if chosen == 0 {
+			return nil, ctx.Err()
+		}
+
+		idx := pending[chosen-1]
+		v, err := vals[idx].(*Future).Result()
+		if err != nil {
+			return nil, err
+		}
+		vals[idx] = v
+
+		cases = append(cases[:chosen], cases[chosen+1:]...)
+		pending = append(pending[:chosen-1], pending[chosen:]...)
+	}
+
+	if _, ok := futures.(*Vector); ok {
+		return NewVector().Conj(vals...), nil
+	}
+
+	return &List{Values: vals}, nil
 }
 
-func futureRealize(ch *Future) bool {
-	return ch.Realized
+// sleep pauses the evaluation for given milliseconds or until the
+// evaluation is cancelled.
+func sleep(scope Scope, s int) error {
+	select {
+	case <-time.After(time.Millisecond * time.Duration(s)):
+		return nil
+	case <-contextOf(scope).Done():
+		return contextOf(scope).Err()
+	}
+}
+
+func isRealized(v Value) (bool, error) {
+	switch v := v.(type) {
+	case *Future:
+		return v.done(), nil
+
+	case *Lazy:
+		return v.Realized(), nil
+	}
+
+	return false, TypeError{
+		Expected: &Future{},
+		Got:      v,
+	}
 }
 
 func xlispTime(scope Scope, args []Value) (Value, error) {
@@ -361,36 +633,10 @@ func xlispTime(scope Scope, args []Value) (Value, error) {
 }
 
 func parseLoop(scope Scope, args []Value) (*Fn, error) {
-	if len(args) < 1 {
-		return nil, fmt.Errorf("call requires at-least bindings argument")
-	}
-
-	vec, isVector := args[0].(*Vector)
-	if !isVector {
-		return nil, fmt.Errorf(
-			"first argument to let must be bindings vector, not %v",
-			reflect.TypeOf(args[0]),
-		)
-	}
-
-	if vec.Size()%2 != 0 {
-		return nil, fmt.Errorf("bindings must contain even forms")
-	}
-
-	var bindings []binding
-	for i := 0; i < vec.Size(); i += 2 {
-		sym, isSymbol := vec.Index(i).(Symbol)
-		if !isSymbol {
-			return nil, fmt.Errorf(
-				"item at %d must be symbol, not %s",
-				i, vec.Index(i),
-			)
-		}
-
-		bindings = append(bindings, binding{
-			Name: sym.Value,
-			Expr: vec.Index(i + 1),
-		})
+	args = unpackLoop(args)
+	bindings, err := readBindings(args)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Fn{
@@ -410,8 +656,18 @@ func parseLoop(scope Scope, args []Value) (*Fn, error) {
 			}
 
 			for isRecur(result) {
+				if err := checkContext(letScope); err != nil {
+					return nil, err
+				}
+
+				newBindings := result.(*recurSignal).args
+				if len(newBindings) != len(bindings) {
+					return nil, ArgumentError{
+						Got: len(newBindings),
+						Fn:  "recur",
+					}
+				}
 
-				newBindings := result.(*List).Values[1:]
 				for i, b := range bindings {
 					letScope.Bind(b.Name, newBindings[i])
 				}
@@ -515,15 +771,15 @@ func assoc(hm Assoc, args ...Value) (Assoc, error) {
 
 		if vec, ok := h.(*Vector); ok {
 
-			index, ok := key.(Number)
+			index, ok := toIndex(key)
 			if !ok {
 				return nil, TypeError{
-					Expected: Number(0),
+					Expected: Int(0),
 					Got:      key,
 				}
 			}
 
-			if int(index) < 0 || int(index) > vec.Size()-1 {
+			if index < 0 || index > vec.Size()-1 {
 				return nil, fmt.Errorf("vector out of bound")
 			}
 		}
@@ -534,60 +790,6 @@ func assoc(hm Assoc, args ...Value) (Assoc, error) {
 	return h, nil
 }
 
-func parsejson(rawJson string) (Value, error) {
-
-	if isArray(rawJson) {
-		var data []interface{}
-		err := json.Unmarshal([]byte(rawJson), &data)
-		if err != nil {
-			return nil, err
-		}
-		return convertVector(data), nil
-	}
-
-	var data map[string]interface{}
-	err := json.Unmarshal([]byte(rawJson), &data)
-	if err != nil {
-		return nil, err
-	}
-
-	return convertHashMap(data), nil
-}
-
-func isArray(rawJson string) bool {
-	rawJson = strings.TrimPrefix(rawJson, " ")
-	return rawJson[0] == '['
-}
-
-func convert(content interface{}) interface{} {
-
-	if hashMap, ok := content.(map[string]interface{}); ok {
-		return convertHashMap(hashMap)
-	} else if vector, ok := content.([]interface{}); ok {
-		return convertVector(vector)
-	} else {
-		return ValueOf(content)
-	}
-}
-
-func convertVector(data []interface{}) *Vector {
-	var vec Seq = NewVector()
-	for _, v := range data {
-		value := convert(v)
-		vec = vec.Conj(value.(Value))
-	}
-	return vec.(*Vector)
-}
-
-func convertHashMap(data map[string]interface{}) *HashMap {
-	pm := NewHashMap()
-	for k, v := range data {
-		value := convert(v)
-		pm = pm.Set(Keyword(k), value.(Value)).(*HashMap)
-	}
-	return pm
-}
-
 func apply(scope Scope, args []Value) (Value, error) {
 
 	argc := len(args)
@@ -603,14 +805,6 @@ func apply(scope Scope, args []Value) (Value, error) {
 		return nil, err
 	}
 
-	fn, ok := evaledArgs[0].(Invokable)
-	if !ok {
-		return nil, ImplementError{
-			Name: invokableStr,
-			Val:  evaledArgs[0],
-		}
-	}
-
 	fnArgs := evaledArgs[1 : len(evaledArgs)-1]
 
 	lastArg := evaledArgs[len(evaledArgs)-1]
@@ -622,18 +816,10 @@ func apply(scope Scope, args []Value) (Value, error) {
 		}
 	}
 
-	if coll.Size() != 0 {
-		for it := coll; it != nil; it = it.Next() {
-			fnArgs = append(fnArgs, it.First())
-		}
-	}
-
-	val, err := fn.Invoke(scope, fnArgs...)
-	if err != nil {
-		return nil, err
-	}
-
-	return val, nil
+	// the values are passed as they are to functions, macros and special
+	// forms receive them as forms
+	fnArgs = append(fnArgs, realize(coll).Values...)
+	return invoke(scope, evaledArgs[0], fnArgs...)
 }
 
 func eval(scope Scope, args []Value) (Value, error) {
@@ -682,11 +868,6 @@ func spiritImport(scope Scope) func(string) (Value, error) {
 			return nil, fmt.Errorf("expecting Spirit instance")
 		}
 
-		value, err := spirit.ReadFile(file)
-		if err != nil {
-			return nil, err
-		}
-
 		nsSymbol, ok := ns.(Symbol)
 		if !ok {
 			return nil, TypeError{
@@ -694,13 +875,9 @@ func spiritImport(scope Scope) func(string) (Value, error) {
 				Got:      ns,
 			}
 		}
+		defer func() { _ = spirit.SwitchNS(nsSymbol) }()
 
-		err = spirit.SwitchNS(nsSymbol)
-		if err != nil {
-			return nil, err
-		}
-
-		return value, nil
+		return spirit.ReadFile(file)
 	}
 }
 
@@ -0,0 +1,420 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+var assocStr = reflect.TypeOf((*Assoc)(nil)).Elem().Name()
+
+// gensymCount numbers the symbols generated for destructuring so they
+// never shadow each other.
+var gensymCount uint64
+
+func gensym(prefix string) Symbol {
+	n := atomic.AddUint64(&gensymCount, 1)
+	return Symbol{Value: fmt.Sprintf("%s__%d", prefix, n)}
+}
+
+// destructure expands binding the value of expr to the pattern into
+// bindings of plain symbols. A pattern is either a symbol, a vector which
+// destructures sequences or a hash map which destructures associative
+// values:
+//
+//	[a b & rest :as all]
+//	{:keys [x y] :strs [s] :syms [z] :or {x 1} :as m, v :key}
+//
+// Nested patterns are destructured in turn. Values missing from the
+// destructured value are bound to nil or the default given in :or.
+func destructure(pattern, expr Value) ([]binding, error) {
+	switch p := pattern.(type) {
+	case Symbol:
+		return []binding{{Name: p.Value, Expr: expr}}, nil
+
+	case *Vector:
+		return destructureSeq(p, expr)
+
+	case *HashMap:
+		return destructureMap(p, expr)
+	}
+
+	return nil, fmt.Errorf(
+		"cannot bind to %v, expecting symbol, vector or hash map", pattern,
+	)
+}
+
+func destructureSeq(pattern *Vector, expr Value) ([]binding, error) {
+	seq := gensym("vec")
+	bindings := []binding{{Name: seq.Value, Expr: expr}}
+
+	vals := pattern.GetValues()
+	for i := 0; i < len(vals); i++ {
+		var expr Value
+
+		switch {
+		case isSymbol(vals[i], "&") && i+1 < len(vals):
+			i++
+			expr = &List{Values: Values{nthNextFn, seq, Int(i - 1)}}
+
+			// the rest of keyword arguments, [x & {:keys [a]}], is
+			// destructured as a map of the keys to the values following
+			// them.
+			if _, isMap := vals[i].(*HashMap); isMap {
+				expr = &List{Values: Values{restAssocFn, expr}}
+			}
+
+		case vals[i] == Keyword("as") && i+1 < len(vals):
+			sym, ok := vals[i+1].(Symbol)
+			if !ok {
+				return nil, fmt.Errorf("expecting symbol after :as, not %v", vals[i+1])
+			}
+			bindings = append(bindings, binding{Name: sym.Value, Expr: seq})
+			i++
+			continue
+
+		case isSymbol(vals[i], "&") || vals[i] == Keyword("as"):
+			return nil, fmt.Errorf("expecting one more form after '%v'", vals[i])
+
+		default:
+			expr = &List{Values: Values{nthFn, seq, Int(i)}}
+		}
+
+		nested, err := destructure(vals[i], expr)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, nested...)
+	}
+
+	return bindings, nil
+}
+
+func destructureMap(pattern *HashMap, expr Value) ([]binding, error) {
+	m := gensym("map")
+	bindings := []binding{{
+		Name: m.Value,
+		Expr: &List{Values: Values{toAssocFn, expr}},
+	}}
+
+	defaults := map[string]Value{}
+	if or := pattern.Get(Keyword("or")); or != nil {
+		hm, ok := or.(*HashMap)
+		if !ok {
+			return nil, fmt.Errorf("expecting hash map after :or, not %v", or)
+		}
+
+		for it := hm.Data.Iterator(); it.HasElem(); it.Next() {
+			k, v := it.Elem()
+			sym, ok := k.(Symbol)
+			if !ok {
+				return nil, fmt.Errorf("expecting symbol as :or key, not %v", k)
+			}
+			defaults[sym.Value] = v.(Value)
+		}
+	}
+
+	// lookup returns the form getting the key from the map, which falls
+	// back to the default of the symbol it is bound to.
+	lookup := func(local, key Value) Value {
+		form := Values{getFn, m, key}
+		if sym, ok := local.(Symbol); ok {
+			if dflt, found := defaults[sym.Value]; found {
+				form = append(form, dflt)
+			}
+		}
+		return &List{Values: form}
+	}
+
+	for it := pattern.Data.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+
+		switch k {
+		case Keyword("or"):
+			continue
+
+		case Keyword("as"):
+			sym, ok := v.(Symbol)
+			if !ok {
+				return nil, fmt.Errorf("expecting symbol after :as, not %v", v)
+			}
+			bindings = append(bindings, binding{Name: sym.Value, Expr: m})
+			continue
+
+		case Keyword("keys"), Keyword("strs"), Keyword("syms"):
+			names, ok := v.(*Vector)
+			if !ok {
+				return nil, fmt.Errorf("expecting vector after %v, not %v", k, v)
+			}
+
+			for _, name := range names.GetValues() {
+				local, key, err := mapKey(k.(Keyword), name)
+				if err != nil {
+					return nil, err
+				}
+
+				bindings = append(bindings, binding{
+					Name: local.Value,
+					Expr: lookup(local, key),
+				})
+			}
+			continue
+		}
+
+		nested, err := destructure(k.(Value), lookup(k.(Value), v.(Value)))
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, nested...)
+	}
+
+	return bindings, nil
+}
+
+// mapKey returns the local a name of :keys, :strs or :syms binds and the
+// form of the key it looks up. The namespace of a qualified name is kept
+// in the key only.
+func mapKey(kind Keyword, name Value) (Symbol, Value, error) {
+	var full string
+	switch n := name.(type) {
+	case Symbol:
+		full = n.Value
+	case Keyword:
+		full = string(n)
+	default:
+		return Symbol{}, nil, fmt.Errorf("expecting symbol in %v, not %v", kind, name)
+	}
+
+	local := Symbol{Value: full[strings.LastIndex(full, "/")+1:]}
+	switch kind {
+	case Keyword("strs"):
+		return local, String(full), nil
+	case Keyword("syms"):
+		return local, &List{Values: Values{SimpleQuote, Symbol{Value: full}}}, nil
+	default:
+		return local, Keyword(full), nil
+	}
+}
+
+func isSymbol(v Value, name string) bool {
+	sym, ok := v.(Symbol)
+	return ok && sym.Value == name
+}
+
+// hoistPatterns replaces the patterns among the names with generated
+// symbols. The body is wrapped in a let destructuring the values bound to
+// them, so the names can still be bound one to one as fn arguments or loop
+// bindings. Returns false if there are no patterns.
+func hoistPatterns(names []Value, body []Value) ([]Value, []Value, bool) {
+	var lets []Value
+	hoisted := make([]Value, len(names))
+
+	for i, name := range names {
+		switch name.(type) {
+		case *Vector, *HashMap:
+			sym := gensym("p")
+			lets = append(lets, name, sym)
+			hoisted[i] = sym
+
+		default:
+			hoisted[i] = name
+		}
+	}
+
+	if lets == nil {
+		return names, body, false
+	}
+
+	let := append(Values{Let, NewVector().Conj(lets...)}, body...)
+	return hoisted, []Value{&List{Values: let}}, true
+}
+
+// unpackParams hoists the patterns out of the argument vector of a fn
+// spec. See hoistPatterns().
+func unpackParams(spec []Value) []Value {
+	params, ok := spec[0].(*Vector)
+	if !ok {
+		return spec
+	}
+
+	names, body, ok := hoistPatterns(params.GetValues(), spec[1:])
+	if !ok {
+		return spec
+	}
+
+	return append([]Value{NewVector().Conj(names...)}, body...)
+}
+
+// unpackLoop hoists the patterns out of the bindings of a loop form, so
+// recur rebinds the values before they are destructured.
+func unpackLoop(args []Value) []Value {
+	if len(args) == 0 {
+		return args
+	}
+
+	vec, ok := args[0].(*Vector)
+	if !ok || vec.Size()%2 != 0 {
+		return args
+	}
+
+	vals := vec.GetValues()
+	names := make([]Value, 0, len(vals)/2)
+	for i := 0; i < len(vals); i += 2 {
+		names = append(names, vals[i])
+	}
+
+	hoisted, body, ok := hoistPatterns(names, args[1:])
+	if !ok {
+		return args
+	}
+
+	bindings := make([]Value, len(vals))
+	for i := range hoisted {
+		bindings[2*i], bindings[2*i+1] = hoisted[i], vals[2*i+1]
+	}
+
+	return append([]Value{NewVector().Conj(bindings...)}, body...)
+}
+
+var (
+	nthFn = strictFn([]string{"coll", "index"}, false,
+		func(_ Scope, args []Value) (Value, error) {
+			return nthOf(args[0], int(args[1].(Int)))
+		})
+
+	nthNextFn = strictFn([]string{"coll", "index"}, false,
+		func(_ Scope, args []Value) (Value, error) {
+			return nthNextOf(args[0], int(args[1].(Int)))
+		})
+
+	toAssocFn = strictFn([]string{"coll"}, false,
+		func(_ Scope, args []Value) (Value, error) {
+			return toAssoc(args[0])
+		})
+
+	restAssocFn = strictFn([]string{"coll"}, false,
+		func(_ Scope, args []Value) (Value, error) {
+			return restAssoc(args[0])
+		})
+
+	getFn = strictFn([]string{"coll", "key", "default"}, false,
+		func(_ Scope, args []Value) (Value, error) {
+			return lookupKey(args[0], args[1], args[2:]...)
+		})
+)
+
+// nthOf returns the value at the index of the sequence, or nil if the
+// sequence is shorter.
+func nthOf(coll Value, n int) (Value, error) {
+	switch c := coll.(type) {
+	case nil, Nil:
+		return Nil{}, nil
+
+	case *Vector:
+		if n < c.Size() {
+			return c.Index(n), nil
+		}
+		return Nil{}, nil
+
+	case Seq:
+		seq := c
+		for i := 0; i < n && seq != nil; i++ {
+			seq = seq.Next()
+		}
+
+		if seq == nil || seq.First() == nil {
+			return Nil{}, nil
+		}
+		return seq.First(), nil
+	}
+
+	return nil, ImplementError{Name: seqStr, Val: coll}
+}
+
+// nthNextOf returns the sequence following the index, or nil if there are
+// no more values.
+func nthNextOf(coll Value, n int) (Value, error) {
+	switch c := coll.(type) {
+	case nil, Nil:
+		return Nil{}, nil
+
+	case *Vector:
+		if n < c.Size() {
+			return c.SubVector(n, c.Size()), nil
+		}
+		return Nil{}, nil
+
+	case Seq:
+		seq := c
+		for i := 0; i < n && seq != nil; i++ {
+			seq = seq.Next()
+		}
+
+		if seq == nil || seq.First() == nil {
+			return Nil{}, nil
+		}
+		return seq, nil
+	}
+
+	return nil, ImplementError{Name: seqStr, Val: coll}
+}
+
+// toAssoc returns the hash map of the alternating keys and values of a
+// list, such as the rest arguments of a fn. Other values are returned as
+// they are.
+func toAssoc(coll Value) (Value, error) {
+	list, ok := coll.(*List)
+	if !ok {
+		return coll, nil
+	}
+
+	if list.Size()%2 != 0 {
+		return nil, fmt.Errorf("no value supplied for key %v", list.Values[list.Size()-1])
+	}
+
+	var hm Value = NewHashMap()
+	for i := 0; i < list.Size(); i += 2 {
+		hm = hm.(*HashMap).Set(list.Values[i], list.Values[i+1])
+	}
+	return hm, nil
+}
+
+// restAssoc returns the hash map of the alternating keys and values of the
+// rest of a sequence, nil if there is no rest.
+func restAssoc(coll Value) (Value, error) {
+	seq, ok := coll.(Seq)
+	if !ok {
+		return coll, nil
+	}
+	return toAssoc(realize(seq))
+}
+
+// lookupKey returns the value of the key in an associative value, or the
+// default if the key is not found.
+func lookupKey(coll, key Value, dflt ...Value) (Value, error) {
+	var v Value
+	switch c := coll.(type) {
+	case nil, Nil:
+
+	case *Vector:
+		if i, ok := toIndex(key); ok && i >= 0 {
+			v = c.Get(key)
+		}
+
+	case Assoc:
+		v = c.Get(key)
+
+	default:
+		return nil, ImplementError{Name: assocStr, Val: coll}
+	}
+
+	switch {
+	case v != nil:
+		return v, nil
+	case len(dflt) > 0:
+		return dflt[0], nil
+	default:
+		return Nil{}, nil
+	}
+}
@@ -0,0 +1,549 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/xiaq/persistent/hash"
+)
+
+// TagReader returns the value of a tagged literal from its form.
+type TagReader func(form Value) (Value, error)
+
+// builtinTags are the readers of the tagged literals read without
+// registering them.
+var builtinTags = map[string]TagReader{
+	"inst":           readInst,
+	"uuid":           readUUID,
+	"spirit/keyword": readKeywordTag,
+	"spirit/symbol":  readSymbolTag,
+}
+
+// readBuiltinTag reads the tagged literals of the built-in tags only.
+func readBuiltinTag(tag Symbol, form Value) (Value, error) {
+	if fn, found := builtinTags[tag.Value]; found {
+		return fn(form)
+	}
+	return nil, fmt.Errorf("no reader function for tag '%s'", tag)
+}
+
+// RegisterTag registers the reader of the tagged literals of the tag, such
+// as "point" for #point [1 2]. A nil reader removes it. The literals are
+// read by read-edn and by the readers of the instance, see Reader.SetScope.
+func (s *Spirit) RegisterTag(tag string, fn TagReader) {
+	if fn == nil {
+		s.registerTag(tag, nil)
+		return
+	}
+
+	s.registerTag(tag, strictFn([]string{"form"}, false, func(_ Scope, args []Value) (Value, error) {
+		return fn(args[0])
+	}))
+}
+
+func (s *Spirit) registerTag(tag string, fn Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fn == nil {
+		delete(s.tags, tag)
+		return
+	}
+
+	if s.tags == nil {
+		s.tags = map[string]Value{}
+	}
+	s.tags[tag] = fn
+}
+
+func (s *Spirit) tagReader(tag string) Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tags[tag]
+}
+
+// TaggedLiteral is a tagged literal read without a reader for its tag. It
+// is printed back as it was read.
+type TaggedLiteral struct {
+	Tag  Symbol
+	Form Value
+}
+
+// Eval returns the literal itself.
+func (tl TaggedLiteral) Eval(_ Scope) (Value, error) { return tl, nil }
+
+func (tl TaggedLiteral) String() string { return prStr(tl) }
+
+// Compare returns true if the other value is a literal of the same tag and
+// an equal form.
+func (tl TaggedLiteral) Compare(other Value) bool {
+	o, ok := other.(TaggedLiteral)
+	return ok && o.Tag.Value == tl.Tag.Value && Compare(tl.Form, o.Form)
+}
+
+// Hash returns the hash of the tag and the form.
+func (tl TaggedLiteral) Hash() uint32 {
+	return hash.DJB(tl.Tag.Hash(), hashOf(tl.Form))
+}
+
+// newEDNReader returns a reader of data only. Forms are read as they are
+// written, without the quoting and the function literals of code, and the
+// keys of maps can be any value.
+func newEDNReader(r io.Reader, tagged func(tag Symbol, form Value) (Value, error)) *Reader {
+	rd := NewReader(r)
+	rd.tagged = tagged
+
+	rd.macros['{'] = readEDNMap
+	for _, r := range "'`~" {
+		rd.macros[r] = unsupportedEDN
+	}
+
+	rd.dispatch = map[rune]ReaderMacro{
+		'{': readSet,
+		'}': unmatchedDelimiter,
+		'_': readDiscard,
+		'#': readSymbolicValue,
+	}
+	for _, r := range "([!?\"" {
+		rd.dispatch[r] = unsupportedEDN
+	}
+	return rd
+}
+
+func readEDNMap(rd *Reader, _ rune) (Value, error) {
+	pi := rd.Position()
+	forms, err := readContainer(rd, '{', '}', "hash-map")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(forms)%2 != 0 {
+		return nil, errors.New("expecting even number of forms within {}")
+	}
+
+	m := newTransientMap(emptyMap)
+	for i := 0; i < len(forms); i += 2 {
+		if _, found := m.index(forms[i]); found {
+			return nil, fmt.Errorf("duplicate key in map: %s", prStr(forms[i]))
+		}
+		m.assoc(forms[i], forms[i+1])
+	}
+
+	return &HashMap{Position: pi, Data: m.persistent()}, nil
+}
+
+// readSymbolicValue reads the floats which have no literal, ##Inf, ##-Inf
+// and ##NaN.
+func readSymbolicValue(rd *Reader, _ rune) (Value, error) {
+	token, err := readToken(rd, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case "Inf":
+		return Number(math.Inf(1)), nil
+	case "-Inf":
+		return Number(math.Inf(-1)), nil
+	case "NaN":
+		return Number(math.NaN()), nil
+	}
+	return nil, fmt.Errorf("unknown symbolic value '##%s'", token)
+}
+
+func unsupportedEDN(_ *Reader, init rune) (Value, error) {
+	return nil, fmt.Errorf("'%c' is not supported in EDN", init)
+}
+
+// ednOptions are the options of read-edn.
+type ednOptions struct {
+	// readers maps tag symbols to the readers of their literals, they
+	// take precedence over the registered readers.
+	readers mapLike
+	// defaultFn reads the literals of tags without a reader, from the tag
+	// and the form.
+	defaultFn Value
+}
+
+// readEDN reads the first value of the string as data, nil if there is
+// none. Tagged literals are read by the :readers given, the readers
+// registered, the built-in ones or the class of the tag. Literals of other
+// tags are given to the :default function if any, or read as they are.
+func readEDN(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+		return nil, err
+	}
+
+	src, ok := args[0].(String)
+	if !ok {
+		return nil, TypeError{
+			Expected: String(""),
+			Got:      args[0],
+		}
+	}
+
+	var opts ednOptions
+	if len(args) == 2 {
+		m, ok := args[1].(mapLike)
+		if !ok {
+			return nil, TypeError{
+				Expected: NewHashMap(),
+				Got:      args[1],
+			}
+		}
+
+		if v, found := m.lookup(Keyword("readers")); found && v != (Nil{}) {
+			if opts.readers, ok = v.(mapLike); !ok {
+				return nil, TypeError{
+					Expected: NewHashMap(),
+					Got:      v,
+				}
+			}
+		}
+		if v, found := m.lookup(Keyword("default")); found && v != (Nil{}) {
+			opts.defaultFn = v
+		}
+	}
+
+	rd := newEDNReader(strings.NewReader(string(src)), func(tag Symbol, form Value) (Value, error) {
+		v, found, err := readTag(scope, opts.readers, tag, form)
+		if err != nil || found {
+			return v, err
+		} else if opts.defaultFn != nil {
+			return invoke(scope, opts.defaultFn, tag, form)
+		}
+		return TaggedLiteral{Tag: tag, Form: form}, nil
+	})
+	rd.File = "<edn>"
+
+	v, err := rd.One()
+	if err == io.EOF {
+		return Nil{}, nil
+	}
+	return v, err
+}
+
+// readTag returns the value of the tagged literal read by the reader of
+// its tag in readers, registered on the instance, built in, or the class of
+// the tag. found is false if there is none.
+func readTag(scope Scope, readers mapLike, tag Symbol, form Value) (Value, bool, error) {
+	var fn Value
+	found := false
+	if readers != nil {
+		fn, found = readers.lookup(tag)
+	}
+
+	if sp, err := spiritOf(scope); !found && err == nil {
+		fn = sp.tagReader(tag.Value)
+		found = fn != nil
+	}
+
+	if found {
+		v, err := invoke(scope, fn, form)
+		return v, true, err
+	}
+
+	if builtin, ok := builtinTags[tag.Value]; ok {
+		v, err := builtin(form)
+		return v, true, err
+	}
+
+	// objects are printed tagged with the name of their class
+	if v, err := scope.Resolve(tag.Value); err == nil {
+		if class, ok := v.(Class); ok {
+			members, ok := form.(*HashMap)
+			if !ok {
+				return nil, true, fmt.Errorf("members of #%s must be a hash map", tag)
+			}
+			return Object{InstanceOf: class, Members: members}, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// registerTagFn registers a function as the reader of the literals of the
+// tag. A nil function removes it.
+func registerTagFn(scope Scope, args []Value) (Value, error) {
+	tag, ok := args[0].(Symbol)
+	if !ok {
+		return nil, TypeError{
+			Expected: Symbol{},
+			Got:      args[0],
+		}
+	}
+
+	sp, err := spiritOf(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if args[1] == (Nil{}) {
+		sp.registerTag(tag.Value, nil)
+		return Nil{}, nil
+	}
+
+	sp.registerTag(tag.Value, args[1])
+	return Nil{}, nil
+}
+
+// dataReaders returns the readers registered by tag symbol.
+func dataReaders(scope Scope, _ []Value) (Value, error) {
+	sp, err := spiritOf(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+
+	readers := newTransientMap(emptyMap)
+	for tag, fn := range sp.tags {
+		readers.assoc(Symbol{Value: tag}, fn)
+	}
+	return &HashMap{Data: readers.persistent()}, nil
+}
+
+// writeEDN writes the value printed by pr-str to a file.
+func writeEDN(_ Scope, args []Value) (Value, error) {
+	path, ok := args[0].(String)
+	if !ok {
+		return nil, TypeError{
+			Expected: String(""),
+			Got:      args[0],
+		}
+	}
+
+	return Nil{}, os.WriteFile(string(path), []byte(prStr(args[1])+"\n"), 0644)
+}
+
+// prStrFn returns the values printed so that they can be read back by the
+// reader, separated by spaces.
+func prStrFn(_ Scope, args []Value) (Value, error) {
+	return String(prValues(args)), nil
+}
+
+// prn prints the values like pr-str followed by a newline.
+func prn(_ Scope, args []Value) (Value, error) {
+	_, err := fmt.Println(prValues(args))
+	return Nil{}, err
+}
+
+func prValues(vals []Value) string {
+	var b strings.Builder
+	for i, v := range vals {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		writeReadable(&b, v)
+	}
+	return b.String()
+}
+
+// prStr returns the value printed so that the reader reads it back to an
+// equal value. Values which are not data, such as functions, are printed
+// by their String method.
+func prStr(v Value) string {
+	var b strings.Builder
+	writeReadable(&b, v)
+	return b.String()
+}
+
+func writeReadable(b *strings.Builder, v Value) {
+	switch val := v.(type) {
+	case nil:
+		b.WriteString("nil")
+
+	case Number:
+		switch f := float64(val); {
+		case math.IsNaN(f):
+			b.WriteString("##NaN")
+		case math.IsInf(f, 1):
+			b.WriteString("##Inf")
+		case math.IsInf(f, -1):
+			b.WriteString("##-Inf")
+		default:
+			b.WriteString(val.String())
+		}
+
+	case String:
+		writeReadableString(b, string(val))
+
+	case Character:
+		writeReadableChar(b, rune(val))
+
+	case *List:
+		writeReadableSeq(b, val, "(", ")")
+
+	case *Vector:
+		writeReadableSeq(b, val, "[", "]")
+
+	case *Set:
+		writeReadableSeq(b, val, "#{", "}")
+
+	case *SortedSet:
+		writeReadableSeq(b, val, "#{", "}")
+
+	case mapLike:
+		writeReadableMap(b, val)
+
+	case Object:
+		b.WriteString("#" + val.InstanceOf.Name + " ")
+		writeReadableMap(b, val.Members)
+
+	case TaggedLiteral:
+		b.WriteString("#" + val.Tag.Value + " ")
+		writeReadable(b, val.Form)
+
+	case Seq:
+		if isSequential(val) {
+			writeReadableSeq(b, val, "(", ")")
+		} else {
+			b.WriteString(val.String())
+		}
+
+	case Keyword:
+		if readableName(string(val)) {
+			b.WriteString(val.String())
+		} else {
+			b.WriteString("#spirit/keyword ")
+			writeReadableString(b, string(val))
+		}
+
+	case Symbol:
+		if readableSymbol(val.Value) {
+			b.WriteString(val.Value)
+		} else {
+			b.WriteString("#spirit/symbol ")
+			writeReadableString(b, val.Value)
+		}
+
+	default:
+		b.WriteString(v.String())
+	}
+}
+
+// terminals are the runes which end the name of a keyword or symbol.
+var terminals = defaultReadTable()
+
+// readableName returns true if the name is read back whole as the name of a
+// keyword or symbol. Other names are printed as tagged literals.
+func readableName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		if _, found := terminals[r]; found || isSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// readableSymbol returns true if the name is read back as a symbol rather
+// than a number or one of nil, true and false.
+func readableSymbol(name string) bool {
+	if !readableName(name) || name[0] == dispatchTrigger {
+		return false
+	}
+
+	if _, found := predefSymbols[name]; found {
+		return false
+	}
+
+	r, _ := utf8.DecodeRuneInString(name)
+	if unicode.IsNumber(r) {
+		return false
+	}
+
+	if (r == '+' || r == '-') && len(name) > 1 {
+		r2, _ := utf8.DecodeRuneInString(name[1:])
+		return !unicode.IsNumber(r2)
+	}
+	return true
+}
+
+func writeReadableSeq(b *strings.Builder, seq Seq, begin, end string) {
+	b.WriteString(begin)
+	first := true
+	for seq != nil {
+		vals, rest := nextChunk(seq)
+		for _, v := range vals {
+			if !first {
+				b.WriteByte(' ')
+			}
+			first = false
+			writeReadable(b, v)
+		}
+		seq = rest
+	}
+	b.WriteString(end)
+}
+
+func writeReadableMap(b *strings.Builder, m mapLike) {
+	b.WriteByte('{')
+	for i, kv := range m.entries() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		pair := kv.(*Vector)
+		writeReadable(b, pair.Index(0))
+		b.WriteByte(' ')
+		writeReadable(b, pair.Index(1))
+	}
+	b.WriteByte('}')
+}
+
+// writeReadableString writes the string quoted, escaping the quotes, the
+// backslashes and the control characters.
+func writeReadableString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+func writeReadableChar(b *strings.Builder, r rune) {
+	for name, char := range charLiterals {
+		if char == r {
+			b.WriteString(`\` + name)
+			return
+		}
+	}
+
+	if unicode.IsGraphic(r) && !unicode.IsSpace(r) {
+		b.WriteString(`\` + string(r))
+	} else {
+		fmt.Fprintf(b, `\u%04x`, r)
+	}
+}
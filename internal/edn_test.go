@@ -0,0 +1,174 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/issadarkthing/spirit/internal"
+)
+
+func TestEDN_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name string
+		src  string
+	}{
+		{name: "Nil", src: `nil`},
+		{name: "Numbers", src: `[1 -2.0 1.5 1/3 2.50M 123456789012345678901234567890]`},
+		{name: "Infinity", src: `[(/ 1.0 0) (/ -1.0 0)]`},
+		{name: "String", src: `"quote \" slash \\ tab \t newline \n bell \u0007"`},
+		{name: "Characters", src: `[\a \space \newline \tab \é \u0000]`},
+		{name: "KeywordsAndSymbols", src: `[:a :ns/b (quote c) (quote ns/d)]`},
+		{name: "List", src: `(quote (1 (2 [3]) ()))`},
+		{name: "Map", src: `{:a {:b [1 2]} "c" nil}`},
+		{name: "MapWithVectorKey", src: `(assoc* {:a 1} [1 2] #{3})`},
+		{name: "Set", src: `#{1 "a" :b [2]}`},
+		{name: "SortedMap", src: `(sorted-map 2 :b 1 :a)`},
+		{name: "SortedSet", src: `(sorted-set 3 1 2)`},
+		{name: "TaggedLiteral", src: `(read-edn "#point {:x 1}")`},
+		{name: "JSONKeys", src: `(parse-json "{\"a b\": 1, \"\": 2, \"[x]\": 3}")`},
+		{name: "UnreadableNames", src: `[(keyword "a:b") (read-edn "#spirit/symbol \"x y\"")
+			(read-edn "#spirit/symbol \"1a\"") (read-edn "#spirit/symbol \"nil\"")]`},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := internal.NewSpirit()
+
+			want, err := scope.ReadEvalStr(tt.src)
+			if err != nil {
+				t.Fatalf("ReadEvalStr() unexpected error: %v", err)
+			}
+			_ = scope.Bind("x", want)
+
+			got, err := scope.ReadEvalStr(`(read-edn (pr-str x))`)
+			if err != nil {
+				t.Fatalf("read-edn unexpected error: %v", err)
+			}
+
+			if !internal.Compare(got, want) {
+				t.Errorf("read-edn = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestEDN_Read(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+			src:  ``,
+			want: `nil`,
+		},
+		{
+			name: "FirstForm",
+			src:  `; config` + "\n" + `{:a 1} {:b 2}`,
+			want: `{:a 1}`,
+		},
+		{
+			name: "Discard",
+			src:  `[1 #_ 2 #_ #_ 3 4 5]`,
+			want: `[1 5]`,
+		},
+		{
+			name: "NotEvaluated",
+			src:  `(inc x)`,
+			want: `(inc x)`,
+		},
+		{
+			name: "UnderscoreInSet",
+			src:  `#{:snake_case}`,
+			want: `#{:snake_case}`,
+		},
+		{
+			name:    "Quote",
+			src:     `'a`,
+			wantErr: true,
+		},
+		{
+			name:    "FunctionLiteral",
+			src:     `#(inc %1)`,
+			wantErr: true,
+		},
+		{
+			name:    "Regex",
+			src:     `#"a+"`,
+			wantErr: true,
+		},
+		{
+			name:    "DuplicateMapKey",
+			src:     `{:a 1 :b 2 :a 3}`,
+			wantErr: true,
+		},
+		{
+			name:    "DuplicateSetMember",
+			src:     `#{1 2 1}`,
+			wantErr: true,
+		},
+		{
+			name: "KeywordTag",
+			src:  `#spirit/keyword "a b"`,
+			want: `#spirit/keyword "a b"`,
+		},
+		{
+			name:    "Unterminated",
+			src:     `{:a [1`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := internal.NewSpirit()
+			_ = scope.Bind("src", internal.String(tt.src))
+
+			got, err := scope.ReadEvalStr(`(read-edn src)`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read-edn error = %v, wantErr %v", err, tt.wantErr)
+			} else if tt.wantErr {
+				return
+			}
+
+			if want := mustRead(t, tt.want); !internal.Compare(got, want) {
+				t.Errorf("read-edn = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSpirit_RegisterTag(t *testing.T) {
+	t.Parallel()
+
+	scope := internal.NewSpirit()
+	scope.RegisterTag("double", func(form internal.Value) (internal.Value, error) {
+		return internal.Int(2 * form.(internal.Int)), nil
+	})
+
+	got, err := scope.ReadEvalStr(`(read-edn "[#double 2 #other 3]")`)
+	if err != nil {
+		t.Fatalf("read-edn unexpected error: %v", err)
+	}
+
+	if want := "[4 #other 3]"; got.String() != want {
+		t.Errorf("read-edn = %v, want %v", got, want)
+	}
+
+	got, err = scope.ReadEvalStr(`(register-tag! 'triple (fn* [x] (* 3 x))) #triple 2`)
+	if err != nil {
+		t.Fatalf("ReadEvalStr() unexpected error: %v", err)
+	} else if got != internal.Int(6) {
+		t.Errorf("ReadEvalStr() = %v, want 6 from a tag registered earlier in the source", got)
+	}
+
+	scope.RegisterTag("double", nil)
+	if got, _ := scope.ReadEvalStr(`(read-edn "#double 2")`); got.String() != "#double 2" {
+		t.Errorf("read-edn = %v after removing the reader, want #double 2", got)
+	}
+}
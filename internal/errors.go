@@ -1,16 +1,29 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
 type TypeError struct {
 	Expected Value
 	Got      Value
+
+	// expectedType and gotType are set instead of the values when an
+	// argument can not be converted to the Go type of a parameter.
+	expectedType reflect.Type
+	gotType      reflect.Type
 }
 
 func (t TypeError) Error() string {
+	if t.expectedType != nil {
+		return fmt.Sprintf(
+			"TypeError: expected %s instead got %s",
+			RemovePrefix(t.expectedType.String()), RemovePrefix(t.gotType.String()),
+		)
+	}
 
 	expected := TypeOf(t.Expected)
 	got := TypeOf(t.Got)
@@ -35,9 +48,16 @@ func (a ArgumentError) Error() string {
 
 type ResolveError struct {
 	Sym Symbol
+	// Private is set if the symbol is defined private to another
+	// namespace.
+	Private bool
 }
 
 func (r ResolveError) Error() string {
+	if r.Private {
+		return fmt.Sprintf("ResolveError: symbol '%s' is private", r.Sym)
+	}
+
 	return fmt.Sprintf(
 		"ResolveError: unable to resolve symbol '%s'", r.Sym,
 	)
@@ -71,17 +91,116 @@ func (i ImportError) Error() string {
 	return fmt.Sprintf("ImportError: %v", i.err)
 }
 
+// PermissionError is returned when a sandboxed instance uses a builtin
+// that requires a capability it is not granted.
+type PermissionError struct {
+	Name       string
+	Capability Capability
+}
+
+func (p PermissionError) Error() string {
+	return fmt.Sprintf(
+		"PermissionError: %s requires '%s' capability", p.Name, p.Capability,
+	)
+}
+
+// LimitError is returned when a sandboxed instance exceeds one of its
+// limits. It aborts the evaluation and can not be caught by try.
+type LimitError struct {
+	Limit string
+	Max   uint64
+}
+
+func (l LimitError) Error() string {
+	return fmt.Sprintf("LimitError: exceeded %s limit of %d", l.Limit, l.Max)
+}
+
+// StackOverflowError is returned when calls are nested deeper than the
+// depth limit of the instance. See Sandbox.MaxDepth.
+type StackOverflowError struct {
+	Depth int
+}
+
+func (s StackOverflowError) Error() string {
+	return fmt.Sprintf("StackOverflowError: exceeded call depth of %d", s.Depth)
+}
+
+// ArithmeticError is returned when an arithmetic operation has no result,
+// such as a division by zero.
+type ArithmeticError struct {
+	Reason string
+}
+
+func (a ArithmeticError) Error() string {
+	return fmt.Sprintf("ArithmeticError: %s", a.Reason)
+}
+
+// TransientError is returned when a transient is used after persistent! or
+// by an execution other than the one that created it.
+type TransientError struct {
+	Reason string
+}
+
+func (t TransientError) Error() string {
+	return fmt.Sprintf("TransientError: %s", t.Reason)
+}
+
+// CancelError is returned when dereferencing a future which has been
+// cancelled.
+type CancelError struct{}
+
+func (c CancelError) Error() string {
+	return "CancelError: future has been cancelled"
+}
+
+// Exception is the error raised by throw. Exceptions created by ex-info
+// also carry a map of data and the error that caused them.
 type Exception struct {
 	message string
 	id      *Keyword
+	data    Value
+	cause   error
 }
 
 func (e Exception) Error() string {
 	return fmt.Sprintf("Exception: %v", e.message)
 }
 
+// String returns the message of the exception along with its data.
+func (e Exception) String() string {
+	if e.data == nil {
+		return e.Error()
+	}
+	return fmt.Sprintf("Exception: %v %v", e.message, e.data)
+}
+
+// Eval returns the exception itself.
+func (e Exception) Eval(_ Scope) (Value, error) {
+	return e, nil
+}
+
+// errorKinds are the kinds of errors that can be caught by name with a
+// catch clause of try.
+var errorKinds = map[string]func(err error) bool{
+	"TypeError":       func(err error) bool { return errors.As(err, &TypeError{}) },
+	"ArgumentError":   func(err error) bool { return errors.As(err, &ArgumentError{}) },
+	"ResolveError":    func(err error) bool { return errors.As(err, &ResolveError{}) },
+	"ImplementError":  func(err error) bool { return errors.As(err, &ImplementError{}) },
+	"OSError":         func(err error) bool { return errors.As(err, &OSError{}) },
+	"ImportError":     func(err error) bool { return errors.As(err, &ImportError{}) },
+	"PermissionError": func(err error) bool { return errors.As(err, &PermissionError{}) },
+	"CancelError":     func(err error) bool { return errors.As(err, &CancelError{}) },
+	"ArithmeticError": func(err error) bool { return errors.As(err, &ArithmeticError{}) },
+	"TransientError":  func(err error) bool { return errors.As(err, &TransientError{}) },
+	"StackOverflowError": func(err error) bool {
+		return errors.As(err, &StackOverflowError{})
+	},
+	"ReadError": func(err error) bool { return errors.As(err, &ReadError{}) },
+	"Exception": func(err error) bool { return errors.As(err, &Exception{}) },
+}
+
 func RemovePrefix(str string) string {
-	str = strings.TrimLeft(str, "*")
-	str = strings.TrimLeft(str, "internal.")
+	str = strings.TrimPrefix(str, "*")
+	str = strings.TrimPrefix(str, "internal.")
 	return str
 }
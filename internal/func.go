@@ -68,8 +68,7 @@ func (multiFn MultiFn) Invoke(scope Scope, args ...Value) (Value, error) {
 		return form.Eval(scope)
 	}
 
-	fn, err := multiFn.selectMethod(args)
-	if err != nil {
+	if _, err := multiFn.selectMethod(args); err != nil {
 		return nil, err
 	}
 
@@ -78,26 +77,29 @@ func (multiFn MultiFn) Invoke(scope Scope, args ...Value) (Value, error) {
 		return nil, err
 	}
 
-	result, err := fn.Invoke(scope, argVals...)
+	return multiFn.invokeValues(scope, argVals)
+}
 
+// invokeValues is like Invoke but the arguments are already evaluated.
+func (multiFn MultiFn) invokeValues(scope Scope, args []Value) (Value, error) {
+	fn, err := multiFn.selectMethod(args)
 	if err != nil {
 		return nil, err
 	}
 
-	if !isRecur(result) {
-		return result, nil
+	result, err := fn.Invoke(scope, args...)
+	if err != nil {
+		return nil, err
 	}
 
 	for isRecur(result) {
+		if err := checkContext(scope); err != nil {
+			return nil, err
+		}
 
-		args = result.(*List).Values[1:]
-		argCount := len(args)
-
-		if argCount != len(fn.Args) {
-			return nil, ArgumentError{
-				Got: argCount,
-				Fn:  multiFn.Name,
-			}
+		args, err = fn.recurArgs(multiFn.Name, result.(*recurSignal).args)
+		if err != nil {
+			return nil, err
 		}
 
 		result, err = fn.Invoke(scope, args...)
@@ -109,23 +111,52 @@ func (multiFn MultiFn) Invoke(scope Scope, args ...Value) (Value, error) {
 	return result, nil
 }
 
-func isRecur(value Value) bool {
-
-	list, ok := value.(*List)
-	if !ok {
-		return false
+// recurArgs returns the arguments to invoke the method with for the values
+// passed to recur. The rest arguments of a variadic method are passed to
+// recur as a single sequence.
+func (fn *Fn) recurArgs(name string, vals []Value) ([]Value, error) {
+	if len(vals) != len(fn.Args) {
+		return nil, ArgumentError{
+			Got: len(vals),
+			Fn:  name,
+		}
 	}
 
-	sym, ok := list.First().(Symbol)
-	if !ok {
-		return false
+	if !fn.Variadic {
+		return vals, nil
 	}
 
-	if sym.Value != "recur" {
-		return false
+	last := len(vals) - 1
+	args := append([]Value(nil), vals[:last]...)
+
+	switch rest := vals[last].(type) {
+	case nil, Nil:
+		return args, nil
+
+	case Seq:
+		return append(args, realize(rest).Values...), nil
 	}
 
-	return true
+	return nil, ImplementError{Name: seqStr, Val: vals[last]}
+}
+
+// recurSignal is returned by recur to the loop or function it rebinds. It
+// passes unchanged through the forms in tail position, the compiler makes
+// sure recur is not used anywhere else.
+type recurSignal struct {
+	args []Value
+}
+
+// Eval returns the signal itself.
+func (r *recurSignal) Eval(_ Scope) (Value, error) { return r, nil }
+
+func (r *recurSignal) String() string {
+	return (&List{Values: append(Values{Symbol{Value: "recur"}}, r.args...)}).String()
+}
+
+func isRecur(value Value) bool {
+	_, ok := value.(*recurSignal)
+	return ok
 }
 
 // Expand executes the macro body and returns the result of the expansion.
@@ -136,7 +167,8 @@ func (multiFn MultiFn) Expand(scope Scope, args []Value) (Value, error) {
 	}
 
 	if !multiFn.IsMacro {
-		return &fn, nil
+		method := *fn
+		return &method, nil
 	}
 	return fn.Invoke(scope, args...)
 }
@@ -166,14 +198,14 @@ func (multiFn MultiFn) Compare(v Value) bool {
 	return true
 }
 
-func (multiFn MultiFn) selectMethod(args []Value) (Fn, error) {
-	for _, fn := range multiFn.Methods {
-		if fn.matchArity(args) {
-			return fn, nil
+func (multiFn MultiFn) selectMethod(args []Value) (*Fn, error) {
+	for i := range multiFn.Methods {
+		if multiFn.Methods[i].matchArity(args) {
+			return &multiFn.Methods[i], nil
 		}
 	}
 
-	return Fn{}, ArgumentError{
+	return nil, ArgumentError{
 		Got: len(args),
 		Fn:  multiFn.Name,
 	}
@@ -220,6 +252,32 @@ type Fn struct {
 	Body     Value
 	Scope    Scope
 	Func     func(scope Scope, args []Value) (Value, error)
+
+	// strict is set if Func evaluates all the arguments and passes them
+	// to strict. Compiled code calls it directly with evaluated arguments.
+	strict func(scope Scope, args []Value) (Value, error)
+
+	// locals and code are set if the body has been compiled.
+	locals *layout
+	code   code
+}
+
+// strictFn returns a function which evaluates its arguments before
+// passing them to f.
+func strictFn(args []string, variadic bool, f func(scope Scope, args []Value) (Value, error)) *Fn {
+	return &Fn{
+		Args:     args,
+		Variadic: variadic,
+		Func: func(scope Scope, args []Value) (Value, error) {
+			argVals, err := EvalValueList(scope, args)
+			if err != nil {
+				return nil, err
+			}
+
+			return f(scope, argVals)
+		},
+		strict: f,
+	}
 }
 
 // Eval returns the function itself.
@@ -245,9 +303,14 @@ func (fn *Fn) Invoke(scope Scope, args ...Value) (Value, error) {
 		return fn.Func(scope, args)
 	}
 
+	if fn.code != nil {
+		return fn.run(scope, args)
+	}
+
 	// lexical scoping as it captures variables at the point of function
 	// creation instead of function invocation
 	fnScope := NewScope(fn.Scope)
+	fnScope.exec = executionOf(scope)
 	if s, ok := scope.(*MapScope); ok {
 		for k, v := range s.bindings {
 			fnScope.Bind(k, v)
@@ -274,6 +337,24 @@ func (fn *Fn) Invoke(scope Scope, args ...Value) (Value, error) {
 	return Eval(fnScope, fn.Body)
 }
 
+// run executes the compiled body in a new frame holding the arguments.
+// The frame is enclosed by the frame the function was created in.
+func (fn *Fn) run(caller Scope, args []Value) (Value, error) {
+	env := newFrame(fn.locals, fn.Scope.(*frame), executionOf(caller))
+
+	for idx := range fn.Args {
+		if idx == len(fn.Args)-1 && fn.Variadic {
+			env.slots[idx] = &List{
+				Values: args[idx:],
+			}
+		} else {
+			env.slots[idx] = args[idx]
+		}
+	}
+
+	return fn.code(env)
+}
+
 // Compare returns true if 'other' is also a function and has the same
 // signature and body.
 func (fn *Fn) Compare(v Value) bool {
@@ -292,14 +373,14 @@ func (fn *Fn) Compare(v Value) bool {
 	return bothVariadic && noFunc && Compare(fn.Body, other.Body)
 }
 
-func (fn Fn) minArity() int {
+func (fn *Fn) minArity() int {
 	if len(fn.Args) > 0 && fn.Variadic {
 		return len(fn.Args) - 1
 	}
 	return len(fn.Args)
 }
 
-func (fn Fn) matchArity(args []Value) bool {
+func (fn *Fn) matchArity(args []Value) bool {
 	argc := len(args)
 	if fn.Variadic {
 		return argc >= len(fn.Args)-1
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/xiaq/persistent/hash"
+)
+
+// Hashable is implemented by values which hash their structure rather than
+// their printed form. Values equal by Compare must have the same hash.
+type Hashable interface {
+	Value
+	Hash() uint32
+}
+
+// seeds of the hashes of values of different types printed alike.
+const (
+	keywordSeed uint32 = 0x9e3779b9
+	symbolSeed  uint32 = 0x85ebca6b
+	charSeed    uint32 = 0xc2b2ae35
+	nilHash     uint32 = 0x27d4eb2f
+)
+
+// hashOf returns the hash of the value. Sequences which are not Hashable
+// are hashed like lists of their values, other values by their printed
+// form.
+func hashOf(v Value) uint32 {
+	switch val := v.(type) {
+	case nil, Nil:
+		return nilHash
+
+	case Hashable:
+		return val.Hash()
+
+	case Seq:
+		if isSequential(val) {
+			return hashSeq(val)
+		}
+	}
+
+	return hash.String(v.String())
+}
+
+// hashSeq returns the hash of the values in order. Sequences equal by
+// Compare share it, so a list and a vector of the same values hash the
+// same.
+func hashSeq(seq Seq) uint32 {
+	h := hash.DJBInit
+	for seq != nil {
+		vals, rest := nextChunk(seq)
+		for _, v := range vals {
+			h = hash.DJBCombine(h, hashOf(v))
+		}
+		seq = rest
+	}
+	return h
+}
+
+// hashUnordered returns the hash of the values regardless of their order.
+func hashUnordered(vals []Value) uint32 {
+	var h uint32
+	for _, v := range vals {
+		h += hashOf(v)
+	}
+	return h
+}
+
+// hashEntries returns the hash of the entries of a map regardless of their
+// order.
+func hashEntries(m mapLike) uint32 {
+	var h uint32
+	for _, e := range m.entries() {
+		entry := e.(*Vector)
+		h += hash.DJB(hashOf(entry.Index(0)), hashOf(entry.Index(1)))
+	}
+	return h
+}
+
+// hashNumber returns the hash of the number. Numbers of different types
+// equal by value hash the same.
+func hashNumber(v Value) uint32 {
+	switch n := v.(type) {
+	case Int:
+		return hash.UInt64(uint64(n))
+
+	case Number:
+		f := float64(n)
+		if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return hash.UInt64(uint64(int64(f)))
+		}
+	}
+
+	r := toRat(v)
+	if r == nil {
+		return hash.String(v.String())
+	} else if r.IsInt() && r.Num().IsInt64() {
+		return hash.UInt64(uint64(r.Num().Int64()))
+	}
+	return hash.String(r.RatString())
+}
+
+// cachedHash returns the hash stored at h, computing and storing it on the
+// first call. Zero marks a hash not computed yet.
+func cachedHash(h *uint32, compute func() uint32) uint32 {
+	if v := atomic.LoadUint32(h); v != 0 {
+		return v
+	}
+
+	v := compute()
+	if v == 0 {
+		v = 1
+	}
+	atomic.StoreUint32(h, v)
+	return v
+}
+
+// isSequential returns true for sequences equal to the sequences of the
+// same values in the same order. Strings, sets and maps are not.
+func isSequential(v Value) bool {
+	switch v.(type) {
+	case String, *Set, *SortedSet, *HashMap, *SortedMap:
+		return false
+	}
+
+	_, ok := v.(Seq)
+	return ok
+}
+
+// hashFn is the `hash` function.
+func hashFn(v Value) Int {
+	return Int(hashOf(v))
+}
+
+// memoize returns a function caching the results of f by its arguments.
+// Arguments equal by Compare share a result, so the cache is keyed by the
+// vector of the arguments. Calls failing with an error are not cached.
+func memoize(_ Scope, args []Value) (Value, error) {
+	f := args[0]
+
+	var mu sync.Mutex
+	cache := NewHashMap()
+
+	return strictFn([]string{"args"}, true, func(scope Scope, args []Value) (Value, error) {
+		key := NewVector().Conj(args...)
+
+		mu.Lock()
+		res, found := cache.lookup(key)
+		mu.Unlock()
+		if found {
+			return res, nil
+		}
+
+		res, err := invoke(scope, f, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		cache = cache.Set(key, res).(*HashMap)
+		mu.Unlock()
+		return res, nil
+	}), nil
+}
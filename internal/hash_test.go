@@ -0,0 +1,89 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/issadarkthing/spirit/internal"
+)
+
+var _ internal.Hashable = &internal.Vector{}
+
+func TestHash_Equal(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name string
+		a, b internal.Value
+	}{
+		{
+			name: "IntAndNumber",
+			a:    internal.Int(1),
+			b:    internal.Number(1),
+		},
+		{
+			name: "IntAndDecimal",
+			a:    internal.Int(10),
+			b:    mustRead(t, "10.0M"),
+		},
+		{
+			name: "ListAndVector",
+			a:    mustRead(t, "(1 [2 :a])"),
+			b:    mustRead(t, "[1 [2 :a]]"),
+		},
+		{
+			name: "MapsByEntries",
+			a:    internal.NewHashMap().Set(internal.Keyword("a"), internal.Int(1)).(*internal.HashMap).Set(internal.Keyword("b"), internal.Int(2)),
+			b:    internal.NewHashMap().Set(internal.Keyword("b"), internal.Number(2)).(*internal.HashMap).Set(internal.Keyword("a"), internal.Int(1)),
+		},
+		{
+			name: "SetsByMembers",
+			a:    internal.NewSet().Conj(internal.Int(1), internal.String("a")),
+			b:    internal.NewSet().Conj(internal.String("a"), internal.Number(1)),
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			if !internal.Compare(tt.a, tt.b) {
+				t.Fatalf("%v and %v are not equal", tt.a, tt.b)
+			}
+
+			ha, hb := tt.a.(internal.Hashable).Hash(), tt.b.(internal.Hashable).Hash()
+			if ha != hb {
+				t.Errorf("Hash() of %v = %d, of %v = %d", tt.a, ha, tt.b, hb)
+			}
+		})
+	}
+}
+
+func TestHash_Distinct(t *testing.T) {
+	t.Parallel()
+
+	vals := []internal.Hashable{
+		internal.String("a"),
+		internal.Keyword("a"),
+		internal.Symbol{Value: "a"},
+		internal.Character('a'),
+		internal.NewVector().Conj(internal.Int(1), internal.Int(2)).(*internal.Vector),
+		internal.NewVector().Conj(internal.Int(2), internal.Int(1)).(*internal.Vector),
+	}
+
+	seen := map[uint32]internal.Value{}
+	for _, v := range vals {
+		if other, found := seen[v.Hash()]; found {
+			t.Errorf("%v and %v hash the same", v, other)
+		}
+		seen[v.Hash()] = v
+	}
+}
+
+func mustRead(t *testing.T, src string) internal.Value {
+	t.Helper()
+
+	v, err := internal.NewReader(strings.NewReader(src)).One()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return v
+}
@@ -96,9 +96,13 @@ func readFile(name string) (string, error) {
 	return string(content), nil
 }
 
+func writeFile(name, content string) error {
+	return ioutil.WriteFile(name, []byte(content), 0644)
+}
+
 func createShellOutput(out, err string, exit int) *HashMap {
 	m := NewHashMap()
-	m = m.Set(Keyword("exit"), Number(exit)).(*HashMap)
+	m = m.Set(Keyword("exit"), Int(exit)).(*HashMap)
 	m = m.Set(Keyword("out"), String(out)).(*HashMap)
 	m = m.Set(Keyword("err"), String(err)).(*HashMap)
 	return m
@@ -0,0 +1,609 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// jsonChunkSize is the number of values json-seq decodes at a time.
+const jsonChunkSize = 32
+
+// jsonOptions are the options of the JSON functions, given as a map.
+type jsonOptions struct {
+	// stringKeys keeps the keys of decoded objects as strings instead of
+	// keywords.
+	stringKeys bool
+	// unwrapArray makes json-seq return the values of a top-level array
+	// instead of the array itself.
+	unwrapArray bool
+	// indent is the indentation of encoded values, empty for compact
+	// output.
+	indent string
+	// keyFn converts the keys of encoded maps before they are written.
+	keyFn Value
+}
+
+func parseJSONOptions(args []Value) (jsonOptions, error) {
+	opts := jsonOptions{unwrapArray: true}
+	if len(args) == 0 {
+		return opts, nil
+	} else if len(args) > 1 {
+		return opts, fmt.Errorf("expecting a single map of options")
+	}
+
+	m, ok := args[0].(mapLike)
+	if !ok {
+		return opts, TypeError{
+			Expected: NewHashMap(),
+			Got:      args[0],
+		}
+	}
+
+	if v, found := m.lookup(Keyword("string-keys")); found {
+		opts.stringKeys = isTruthy(v)
+	}
+	if v, found := m.lookup(Keyword("unwrap-array")); found {
+		opts.unwrapArray = isTruthy(v)
+	}
+	if v, found := m.lookup(Keyword("pretty")); found && isTruthy(v) {
+		opts.indent = "  "
+	}
+	if v, found := m.lookup(Keyword("indent")); found {
+		indent, ok := v.(String)
+		if !ok {
+			return opts, TypeError{
+				Expected: String(""),
+				Got:      v,
+			}
+		}
+		opts.indent = string(indent)
+	}
+	if v, found := m.lookup(Keyword("key-fn")); found && v != (Nil{}) {
+		opts.keyFn = v
+	}
+
+	return opts, nil
+}
+
+// parseJSON decodes the single JSON value of the string. Objects are
+// decoded to maps keyed by keywords unless the :string-keys option is set.
+func parseJSON(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+		return nil, err
+	}
+
+	src, ok := args[0].(String)
+	if !ok {
+		return nil, TypeError{
+			Expected: String(""),
+			Got:      args[0],
+		}
+	}
+
+	opts, err := parseJSONOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	dec := newJSONDecoder(strings.NewReader(string(src)))
+	v, err := decodeJSON(dec, opts)
+	if err == io.EOF {
+		return nil, fmt.Errorf("parse-json: unexpected end of JSON input")
+	} else if err != nil {
+		return nil, fmt.Errorf("parse-json: %w", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("parse-json: unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+// jsonSeq returns a lazy sequence of the JSON values read from a file or
+// an io.Reader, such as newline delimited JSON. The values of a top-level
+// array are returned one by one unless the :unwrap-array option is false.
+// A file is closed once the sequence is realised to its end.
+func jsonSeq(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+		return nil, err
+	}
+
+	opts, err := parseJSONOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	switch src := args[0].(type) {
+	case String:
+		f, err := os.Open(string(src))
+		if err != nil {
+			return nil, err
+		}
+		r = f
+
+	case Any:
+		rd, ok := src.V.Interface().(io.Reader)
+		if !ok {
+			return nil, fmt.Errorf("json-seq: %v is not a reader", src)
+		}
+		r = rd
+
+	default:
+		return nil, TypeError{
+			Expected: String(""),
+			Got:      args[0],
+		}
+	}
+
+	return newJSONStream(r, opts), nil
+}
+
+// jsonStream decodes the values of a JSON stream on demand.
+type jsonStream struct {
+	r     io.Reader
+	dec   *json.Decoder
+	opts  jsonOptions
+	inArr bool
+}
+
+func newJSONStream(r io.Reader, opts jsonOptions) *Lazy {
+	s := &jsonStream{r: r, dec: newJSONDecoder(r), opts: opts}
+
+	if opts.unwrapArray {
+		return newLazy(func() (Seq, error) {
+			if err := s.start(); err != nil {
+				return nil, s.fail(err)
+			}
+			return s.next()
+		})
+	}
+
+	return newLazy(s.next)
+}
+
+// start enters the top-level array if the stream begins with one.
+func (s *jsonStream) start() error {
+	b, err := peekByte(s.dec)
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if b == '[' {
+		if _, err := s.dec.Token(); err != nil {
+			return err
+		}
+		s.inArr = true
+	}
+	return nil
+}
+
+// next decodes the next chunk of values followed by the rest of the stream.
+func (s *jsonStream) next() (Seq, error) {
+	vals := make([]Value, 0, jsonChunkSize)
+	for len(vals) < jsonChunkSize {
+		if s.inArr && !s.dec.More() {
+			if _, err := s.dec.Token(); err != nil {
+				return nil, s.fail(noEOF(err))
+			}
+			s.inArr = false
+		}
+
+		v, err := decodeJSON(s.dec, s.opts)
+		if err == io.EOF {
+			s.close()
+			return &List{Values: vals}, nil
+		} else if err != nil {
+			return nil, s.fail(err)
+		}
+		vals = append(vals, v)
+	}
+
+	return &chunk{vals: vals, rest: newLazy(s.next)}, nil
+}
+
+func (s *jsonStream) fail(err error) error {
+	s.close()
+	return fmt.Errorf("json-seq: %w", err)
+}
+
+func (s *jsonStream) close() {
+	if c, ok := s.r.(*os.File); ok {
+		c.Close()
+	}
+}
+
+// peekByte returns the next byte of the stream which is not a space,
+// without consuming it.
+func peekByte(dec *json.Decoder) (byte, error) {
+	for {
+		buf, err := io.ReadAll(dec.Buffered())
+		if err != nil {
+			return 0, err
+		}
+
+		if s := bytes.TrimLeft(buf, " \t\r\n"); len(s) > 0 {
+			return s[0], nil
+		}
+
+		// the buffer is empty, let the decoder read more of the stream
+		if !dec.More() {
+			return 0, io.EOF
+		}
+	}
+}
+
+func newJSONDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+// decodeJSON decodes the next value of the decoder. Returns io.EOF if
+// there is none.
+func decodeJSON(dec *json.Decoder, opts jsonOptions) (Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			return decodeArray(dec, opts)
+		} else if t == '{' {
+			return decodeObject(dec, opts)
+		}
+		return nil, fmt.Errorf("unexpected %q", rune(t))
+
+	case string:
+		return String(t), nil
+
+	case json.Number:
+		return jsonNumber(t)
+
+	case bool:
+		return Bool(t), nil
+
+	case nil:
+		return Nil{}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
+
+func decodeArray(dec *json.Decoder, opts jsonOptions) (Value, error) {
+	vec := newTransientVector(emptyVector)
+	for dec.More() {
+		v, err := decodeJSON(dec, opts)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		vec.conj(v)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, noEOF(err)
+	}
+	return &Vector{Vec: vec.persistent()}, nil
+}
+
+func decodeObject(dec *json.Decoder, opts jsonOptions) (Value, error) {
+	m := newTransientMap(emptyMap)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+
+		v, err := decodeJSON(dec, opts)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+
+		if opts.stringKeys {
+			m.assoc(String(tok.(string)), v)
+		} else {
+			m.assoc(Keyword(tok.(string)), v)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, noEOF(err)
+	}
+	return &HashMap{Data: m.persistent()}, nil
+}
+
+// noEOF reports the end of the input within a value as an error.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// jsonNumber returns an integer for numbers without a fraction or an
+// exponent, a float otherwise.
+func jsonNumber(n json.Number) (Value, error) {
+	s := string(n)
+	if !strings.ContainsAny(s, ".eE") {
+		if i, ok := new(big.Int).SetString(s, 10); ok {
+			return bigValue(i), nil
+		}
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return Number(f), nil
+}
+
+// toJSON encodes the value as JSON. The options are :pretty or :indent for
+// indented output and :key-fn to convert the keys of maps.
+func toJSON(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+		return nil, err
+	}
+
+	b, err := encodeJSON(scope, args[0], args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return String(b), nil
+}
+
+// writeJSON writes the value encoded as JSON to a file.
+func writeJSON(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2, 3}, args); err != nil {
+		return nil, err
+	}
+
+	path, ok := args[0].(String)
+	if !ok {
+		return nil, TypeError{
+			Expected: String(""),
+			Got:      args[0],
+		}
+	}
+
+	b, err := encodeJSON(scope, args[1], args[2:])
+	if err != nil {
+		return nil, err
+	}
+	return Nil{}, os.WriteFile(string(path), b, 0644)
+}
+
+func encodeJSON(scope Scope, v Value, optArgs []Value) ([]byte, error) {
+	opts, err := parseJSONOptions(optArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := &jsonEncoder{scope: scope, keyFn: opts.keyFn}
+	if err := enc.encode(v); err != nil {
+		return nil, err
+	}
+
+	if opts.indent == "" {
+		return enc.buf.Bytes(), nil
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, enc.buf.Bytes(), "", opts.indent); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// jsonEncoder writes values as compact JSON.
+type jsonEncoder struct {
+	scope Scope
+	keyFn Value
+	buf   bytes.Buffer
+}
+
+func (e *jsonEncoder) encode(v Value) error {
+	switch val := v.(type) {
+	case nil, Nil:
+		e.buf.WriteString("null")
+
+	case Bool:
+		e.buf.WriteString(strconv.FormatBool(bool(val)))
+
+	case Int, BigInt:
+		e.buf.WriteString(val.String())
+
+	case Number:
+		return e.encodeFloat(float64(val))
+
+	case Ratio:
+		f, _ := val.Rat.Float64()
+		return e.encodeFloat(f)
+
+	case BigDecimal:
+		e.buf.WriteString(strings.TrimSuffix(val.String(), "M"))
+
+	case String:
+		writeJSONString(&e.buf, string(val))
+
+	case Keyword:
+		writeJSONString(&e.buf, string(val))
+
+	case Symbol:
+		writeJSONString(&e.buf, val.Value)
+
+	case Character:
+		writeJSONString(&e.buf, string(rune(val)))
+
+	case Inst:
+		writeJSONString(&e.buf, val.Time.UTC().Format(time.RFC3339Nano))
+
+	case UUID:
+		writeJSONString(&e.buf, val.text())
+
+	case Object:
+		return e.encodeMap(val.Members)
+
+	case mapLike:
+		return e.encodeMap(val)
+
+	case Seq:
+		return e.encodeSeq(val)
+
+	default:
+		return fmt.Errorf("to-json: can't encode %s as JSON", TypeOf(v))
+	}
+
+	return nil
+}
+
+func (e *jsonEncoder) encodeFloat(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("to-json: can't encode %v as JSON", f)
+	}
+
+	b, _ := json.Marshal(f)
+	e.buf.Write(b)
+	return nil
+}
+
+func (e *jsonEncoder) encodeSeq(seq Seq) error {
+	e.buf.WriteByte('[')
+	first := true
+	for seq != nil {
+		vals, rest := nextChunk(seq)
+		for _, v := range vals {
+			if !first {
+				e.buf.WriteByte(',')
+			}
+			first = false
+
+			if err := e.encode(v); err != nil {
+				return err
+			}
+		}
+		seq = rest
+	}
+	e.buf.WriteByte(']')
+	return nil
+}
+
+// encodeMap writes the entries of the map. The entries of hash maps are
+// sorted by key so the output does not depend on their hashes.
+func (e *jsonEncoder) encodeMap(m mapLike) error {
+	type entry struct {
+		key string
+		val Value
+	}
+
+	entries := make([]entry, 0, m.Size())
+	for _, kv := range m.entries() {
+		pair := kv.(*Vector)
+		key, err := e.key(pair.Index(0))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key, pair.Index(1)})
+	}
+
+	if _, sorted := m.(*SortedMap); !sorted {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+	}
+
+	e.buf.WriteByte('{')
+	for i, ent := range entries {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+
+		writeJSONString(&e.buf, ent.key)
+		e.buf.WriteByte(':')
+		if err := e.encode(ent.val); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// key returns the name of the key, after converting it with the key
+// function if any.
+func (e *jsonEncoder) key(k Value) (string, error) {
+	if e.keyFn != nil {
+		var err error
+		if k, err = invoke(e.scope, e.keyFn, k); err != nil {
+			return "", err
+		}
+	}
+
+	switch key := k.(type) {
+	case String:
+		return string(key), nil
+
+	case Keyword:
+		return string(key), nil
+
+	case Symbol:
+		return key.Value, nil
+
+	case Character:
+		return string(rune(key)), nil
+
+	case Bool, Int, BigInt, Number, Ratio, BigDecimal:
+		return key.String(), nil
+	}
+
+	return "", fmt.Errorf("to-json: can't use %s as a key", TypeOf(k))
+}
+
+// writeJSONString writes the string quoted and escaped as JSON. Unlike
+// encoding/json, HTML characters are left as they are.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+
+		case r == '\n':
+			buf.WriteString(`\n`)
+
+		case r == '\r':
+			buf.WriteString(`\r`)
+
+		case r == '\t':
+			buf.WriteString(`\t`)
+
+		case r < 0x20 || r == '\u2028' || r == '\u2029':
+			buf.WriteString(`\u`)
+			for shift := 12; shift >= 0; shift -= 4 {
+				buf.WriteByte(hex[(r>>shift)&0xf])
+			}
+
+		case r == utf8.RuneError:
+			buf.WriteString(`\ufffd`)
+
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,81 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/issadarkthing/spirit/internal"
+)
+
+func TestJSONSeq(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name    string
+		input   string
+		opts    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Empty",
+			input: "",
+			want:  `[]`,
+		},
+		{
+			name:  "NDJSON",
+			input: "{\"a\": 1}\n{\"a\": [2]}\n\"b\"\n",
+			want:  `[{:a 1} {:a [2]} "b"]`,
+		},
+		{
+			name:  "ArrayUnwrapped",
+			input: `  [1, {"b": null}, 2.5]`,
+			want:  `[1 {:b nil} 2.5]`,
+		},
+		{
+			name:  "ArrayKept",
+			input: `[1, 2] [3]`,
+			opts:  `{:unwrap-array false}`,
+			want:  `[[1 2] [3]]`,
+		},
+		{
+			name:  "StringKeys",
+			input: `{"a": {"b": 1}}`,
+			opts:  `{:string-keys true}`,
+			want:  `[{"a" {"b" 1}}]`,
+		},
+		{
+			name:  "LargeArray",
+			input: "[" + strings.Repeat("1,", 999) + "1]",
+			want:  "[" + strings.Repeat("1 ", 1000) + "]",
+		},
+		{
+			name:    "Truncated",
+			input:   `[1, 2`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid",
+			input:   `{"a" 1}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := internal.NewSpirit()
+			_ = scope.BindGo("src", strings.NewReader(tt.input))
+
+			got, err := scope.ReadEvalStr("(into* [] (json-seq src " + tt.opts + "))")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("json-seq error = %v, wantErr %v", err, tt.wantErr)
+			} else if tt.wantErr {
+				return
+			}
+
+			if want := mustRead(t, tt.want); !internal.Compare(got, want) {
+				t.Errorf("json-seq = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,601 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// chunkSize is the number of values the lazy sequence functions realise at
+// once from an input which is realised in chunks.
+const chunkSize = 32
+
+// Lazy is a sequence realised by calling a thunk the first time one of its
+// values is used. The sequence returned by the thunk is kept, so the thunk
+// is called once and the sequence can be traversed multiple times. Thunks
+// calling functions do so in a detached scope, as the goroutine realising
+// a sequence need not be the one which created it.
+//
+// Seq methods cannot return errors, so a thunk failing makes them panic
+// with a seqPanic which is recovered by the evaluation the sequence is
+// used in. See recoverSeq.
+type Lazy struct {
+	once     sync.Once
+	thunk    func() (Seq, error)
+	seq      Seq
+	err      error
+	realized int32
+}
+
+func newLazy(thunk func() (Seq, error)) *Lazy {
+	return &Lazy{thunk: thunk}
+}
+
+// Realized returns true if the thunk has been called.
+func (l *Lazy) Realized() bool {
+	return atomic.LoadInt32(&l.realized) == 1
+}
+
+// Eval returns the sequence itself.
+func (l *Lazy) Eval(_ Scope) (Value, error) {
+	return l, nil
+}
+
+// String realises the whole sequence and returns it formatted as a list.
+func (l *Lazy) String() string {
+	return seqString(l)
+}
+
+// First returns the first value of the sequence or nil if it is empty.
+func (l *Lazy) First() Value {
+	if seq := l.realize(); seq != nil {
+		return seq.First()
+	}
+	return nil
+}
+
+// Next returns the values following the first one or nil if there are
+// none.
+func (l *Lazy) Next() Seq {
+	if seq := l.realize(); seq != nil {
+		return seq.Next()
+	}
+	return nil
+}
+
+// Cons returns a sequence of the value followed by this one, without
+// realising it.
+func (l *Lazy) Cons(v Value) Seq {
+	return &chunk{vals: []Value{v}, rest: l}
+}
+
+// Conj returns a lazy sequence of the values of this one followed by the
+// given values.
+func (l *Lazy) Conj(vals ...Value) Seq {
+	return lazyCat(l, &List{Values: Values{&List{Values: vals}}})
+}
+
+// Size realises the whole sequence and returns the number of values.
+func (l *Lazy) Size() int {
+	return sizeOf(l)
+}
+
+// Compare returns true if the other value is a sequence of the same values.
+func (l *Lazy) Compare(other Value) bool {
+	return compareSeq(l, other)
+}
+
+// realize calls the thunk on the first call and returns the sequence it
+// returned, nil if it is empty.
+func (l *Lazy) realize() Seq {
+	l.once.Do(func() {
+		defer atomic.StoreInt32(&l.realized, 1)
+		defer func() {
+			if r := recover(); r != nil {
+				l.seq, l.err = nil, panicError(r)
+			}
+		}()
+
+		seq, err := l.thunk()
+		l.thunk = nil
+		if err != nil {
+			l.err = err
+			return
+		}
+
+		// nested lazy sequences are unwrapped here instead of by each call
+		// to First and Next
+		for {
+			inner, ok := seq.(*Lazy)
+			if !ok {
+				break
+			}
+			seq = inner.realize()
+		}
+
+		if seq != nil && seq.First() != nil {
+			l.seq = seq
+		}
+	})
+
+	if l.err != nil {
+		panic(seqPanic{err: l.err})
+	}
+	return l.seq
+}
+
+// chunk is a run of realised values of a lazy sequence followed by the
+// rest of the sequence.
+type chunk struct {
+	vals []Value
+	rest Seq
+}
+
+func (c *chunk) Eval(_ Scope) (Value, error) {
+	return c, nil
+}
+
+func (c *chunk) String() string {
+	return seqString(c)
+}
+
+func (c *chunk) First() Value {
+	return c.vals[0]
+}
+
+// Next returns the values following the first one, realising the rest of
+// the sequence if there are none left in the chunk.
+func (c *chunk) Next() Seq {
+	if len(c.vals) > 1 {
+		return &chunk{vals: c.vals[1:], rest: c.rest}
+	}
+
+	if c.rest == nil || c.rest.First() == nil {
+		return nil
+	}
+	return c.rest
+}
+
+func (c *chunk) Cons(v Value) Seq {
+	return &chunk{vals: []Value{v}, rest: c}
+}
+
+func (c *chunk) Conj(vals ...Value) Seq {
+	return lazyCat(c, &List{Values: Values{&List{Values: vals}}})
+}
+
+func (c *chunk) Size() int {
+	return sizeOf(c)
+}
+
+func (c *chunk) Compare(other Value) bool {
+	return compareSeq(c, other)
+}
+
+// nextChunk returns the values at the start of the sequence which are
+// realised together and the sequence following them. A sequence which is
+// not realised in chunks returns its first value only. Returns no values
+// at the end of the sequence.
+func nextChunk(seq Seq) ([]Value, Seq) {
+	if e, ok := seq.(*Eduction); ok {
+		seq = e.seq()
+	}
+
+	if l, ok := seq.(*Lazy); ok {
+		seq = l.realize()
+	}
+
+	switch s := seq.(type) {
+	case nil:
+		return nil, nil
+
+	case *chunk:
+		return s.vals, s.rest
+
+	case *List:
+		if len(s.Values) > chunkSize {
+			return s.Values[:chunkSize:chunkSize], &List{Values: s.Values[chunkSize:]}
+		}
+		return s.Values, nil
+
+	case *Vector:
+		size := s.Vec.Len()
+		if size > chunkSize {
+			size = chunkSize
+		}
+
+		vals := make([]Value, size)
+		for i := range vals {
+			v, _ := s.Vec.Index(i)
+			vals[i] = v.(Value)
+		}
+
+		if size == s.Vec.Len() {
+			return vals, nil
+		}
+		return vals, &Vector{Vec: s.Vec.SubVector(size, s.Vec.Len())}
+
+	case LazySeq:
+		var vals []Value
+		for ; len(vals) < chunkSize && s.Min < s.Max; s.Min += s.Step {
+			vals = append(vals, ValueOf(s.Min))
+		}
+
+		if s.Min >= s.Max {
+			return vals, nil
+		}
+		return vals, s
+	}
+
+	first := seq.First()
+	if first == nil {
+		return nil, nil
+	}
+	return []Value{first}, seq.Next()
+}
+
+// isLazy returns true if the value is a sequence realised as it is used.
+func isLazy(v Value) bool {
+	switch v.(type) {
+	case *Lazy, *chunk, LazySeq, *Eduction:
+		return true
+	}
+	return false
+}
+
+// lazySeq returns a lazy sequence of the sequence returned by calling f
+// with no arguments.
+func lazySeq(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	f := args[0]
+	return newLazy(func() (Seq, error) {
+		v, err := invoke(detach(scope), f)
+		if err != nil {
+			return nil, err
+		}
+		return toSeq(v)
+	}), nil
+}
+
+// lazyMap returns a lazy sequence of the results of applying the first
+// argument to the values of the sequences following it.
+func lazyMap(scope Scope, args []Value) (Value, error) {
+	f, colls, err := seqArgs("lazy-map*", args)
+	if err != nil {
+		return nil, err
+	}
+	return mapSeqs(scope, f, colls), nil
+}
+
+func mapSeqs(scope Scope, f Value, colls []Seq) *Lazy {
+	return newLazy(func() (Seq, error) {
+		realizing := detach(scope)
+		if len(colls) == 1 {
+			vals, rest := nextChunk(colls[0])
+			if len(vals) == 0 {
+				return nil, nil
+			}
+
+			mapped := make([]Value, len(vals))
+			for i, v := range vals {
+				var err error
+				if mapped[i], err = invoke(realizing, f, v); err != nil {
+					return nil, err
+				}
+			}
+
+			return &chunk{vals: mapped, rest: mapSeqs(scope, f, []Seq{rest})}, nil
+		}
+
+		args := make([]Value, len(colls))
+		rests := make([]Seq, len(colls))
+		for i, coll := range colls {
+			if coll == nil || coll.First() == nil {
+				return nil, nil
+			}
+			args[i], rests[i] = coll.First(), coll.Next()
+		}
+
+		v, err := invoke(realizing, f, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &chunk{vals: []Value{v}, rest: mapSeqs(scope, f, rests)}, nil
+	})
+}
+
+// lazyFilter returns a lazy sequence of the values of the sequence for
+// which the predicate returns true.
+func lazyFilter(scope Scope, args []Value) (Value, error) {
+	pred, colls, err := seqArgs("lazy-filter*", args)
+	if err != nil {
+		return nil, err
+	}
+	return filterSeq(scope, pred, colls[0]), nil
+}
+
+func filterSeq(scope Scope, pred Value, coll Seq) *Lazy {
+	return newLazy(func() (Seq, error) {
+		realizing := detach(scope)
+		for {
+			vals, rest := nextChunk(coll)
+			if len(vals) == 0 {
+				return nil, nil
+			}
+
+			var kept []Value
+			for _, v := range vals {
+				ok, err := invoke(realizing, pred, v)
+				if err != nil {
+					return nil, err
+				}
+
+				if isTruthy(ok) {
+					kept = append(kept, v)
+				}
+			}
+
+			if len(kept) > 0 {
+				return &chunk{vals: kept, rest: filterSeq(scope, pred, rest)}, nil
+			}
+			coll = rest
+		}
+	})
+}
+
+// lazyTakeWhile returns a lazy sequence of the values at the start of the
+// sequence for which the predicate returns true.
+func lazyTakeWhile(scope Scope, args []Value) (Value, error) {
+	pred, colls, err := seqArgs("lazy-take-while*", args)
+	if err != nil {
+		return nil, err
+	}
+	return takeWhileSeq(scope, pred, colls[0]), nil
+}
+
+func takeWhileSeq(scope Scope, pred Value, coll Seq) *Lazy {
+	return newLazy(func() (Seq, error) {
+		realizing := detach(scope)
+		vals, rest := nextChunk(coll)
+		for i, v := range vals {
+			ok, err := invoke(realizing, pred, v)
+			if err != nil {
+				return nil, err
+			}
+
+			if !isTruthy(ok) {
+				if i == 0 {
+					return nil, nil
+				}
+				return &chunk{vals: vals[:i:i]}, nil
+			}
+		}
+
+		if len(vals) == 0 {
+			return nil, nil
+		}
+		return &chunk{vals: vals, rest: takeWhileSeq(scope, pred, rest)}, nil
+	})
+}
+
+// lazyDropWhile returns a lazy sequence of the values of the sequence
+// starting from the first one for which the predicate returns false.
+func lazyDropWhile(scope Scope, args []Value) (Value, error) {
+	pred, colls, err := seqArgs("lazy-drop-while*", args)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := colls[0]
+	return newLazy(func() (Seq, error) {
+		realizing := detach(scope)
+		for {
+			vals, rest := nextChunk(coll)
+			if len(vals) == 0 {
+				return nil, nil
+			}
+
+			for i, v := range vals {
+				ok, err := invoke(realizing, pred, v)
+				if err != nil {
+					return nil, err
+				}
+
+				if !isTruthy(ok) {
+					return &chunk{vals: vals[i:], rest: rest}, nil
+				}
+			}
+			coll = rest
+		}
+	}), nil
+}
+
+// lazyConcat returns a lazy sequence of the values of the sequences given.
+func lazyConcat(_ Scope, args []Value) (Value, error) {
+	return lazyCat(nil, &List{Values: args}), nil
+}
+
+// lazyFlatten returns a lazy sequence of the values of the sequences in
+// the sequence given.
+func lazyFlatten(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	colls, err := toSeq(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return lazyCat(nil, colls), nil
+}
+
+// lazyCat returns a lazy sequence of the values of coll followed by the
+// values of each sequence in colls.
+func lazyCat(coll, colls Seq) *Lazy {
+	return newLazy(func() (Seq, error) {
+		for {
+			vals, rest := nextChunk(coll)
+			if len(vals) > 0 {
+				return &chunk{vals: vals, rest: lazyCat(rest, colls)}, nil
+			}
+
+			if colls == nil || colls.First() == nil {
+				return nil, nil
+			}
+
+			var err error
+			if coll, err = toSeq(colls.First()); err != nil {
+				return nil, err
+			}
+			colls = colls.Next()
+		}
+	})
+}
+
+// seqArgs returns the function and the sequences passed to a lazy
+// sequence function.
+func seqArgs(name string, args []Value) (Value, []Seq, error) {
+	if len(args) < 2 {
+		return nil, nil, ArgumentError{
+			Got: len(args),
+			Fn:  name,
+		}
+	}
+
+	colls := make([]Seq, len(args)-1)
+	for i, arg := range args[1:] {
+		var err error
+		if colls[i], err = toSeq(arg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return args[0], colls, nil
+}
+
+// toSeq returns the value as a sequence, nil for nil.
+func toSeq(v Value) (Seq, error) {
+	switch seq := v.(type) {
+	case nil, Nil:
+		return nil, nil
+
+	case Seq:
+		return seq, nil
+	}
+
+	return nil, ImplementError{
+		Name: seqStr,
+		Val:  v,
+	}
+}
+
+// invoke calls the function with the values as arguments. Unlike Invoke,
+// the values are not evaluated again if the function is strict.
+func invoke(scope Scope, f Value, args ...Value) (Value, error) {
+	if isStrict(f) {
+		return invokeStrict(scope, f, args)
+	}
+
+	invokable, ok := f.(Invokable)
+	if !ok {
+		return nil, ImplementError{
+			Name: invokableStr,
+			Val:  f,
+		}
+	}
+	return invokable.Invoke(scope, args...)
+}
+
+// seqString returns the values of the sequence formatted as a list, or the
+// error it failed to realise with.
+func seqString(seq Seq) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			p, ok := r.(seqPanic)
+			if !ok {
+				panic(r)
+			}
+			s = fmt.Sprintf("<Lazy(error: %v)>", p.err)
+		}
+	}()
+
+	return realize(seq).String()
+}
+
+func sizeOf(seq Seq) int {
+	size := 0
+	for seq != nil {
+		vals, rest := nextChunk(seq)
+		size += len(vals)
+		seq = rest
+	}
+	return size
+}
+
+// compareSeq returns true if the other value is a sequence of the same
+// values as seq.
+func compareSeq(seq Seq, other Value) bool {
+	otherSeq, ok := other.(Seq)
+	if !ok || !isSequential(otherSeq) {
+		return false
+	}
+
+	for {
+		v1, v2 := seq.First(), otherSeq.First()
+		if v1 == nil || v2 == nil {
+			return v1 == nil && v2 == nil
+		}
+
+		if !Compare(v1, v2) {
+			return false
+		}
+
+		if seq, otherSeq = seq.Next(), otherSeq.Next(); seq == nil || otherSeq == nil {
+			return (seq == nil || seq.First() == nil) &&
+				(otherSeq == nil || otherSeq.First() == nil)
+		}
+	}
+}
+
+// seqPanic carries the error a lazy sequence failed to realise with out of
+// the Seq methods.
+type seqPanic struct {
+	err error
+}
+
+// recoverSeq sets err to the error of a lazy sequence which failed to
+// realise while the function deferring it ran. Other panics are left to
+// propagate.
+func recoverSeq(err *error) {
+	if r := recover(); r != nil {
+		p, ok := r.(seqPanic)
+		if !ok {
+			panic(r)
+		}
+		*err = p.err
+	}
+}
+
+// invokeForms invokes the target with the forms as arguments.
+func invokeForms(scope Scope, target Invokable, args []Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+	return target.Invoke(scope, args...)
+}
+
+// runCode runs the compiled code.
+func runCode(env *frame, run code) (_ Value, err error) {
+	defer recoverSeq(&err)
+	return run(env)
+}
+
+// panicError returns the error a recovered panic stands for.
+func panicError(r interface{}) error {
+	if p, ok := r.(seqPanic); ok {
+		return p.err
+	}
+	return fmt.Errorf("panic: %v", r)
+}
@@ -4,89 +4,87 @@ import (
 	"math"
 )
 
-// Add adds given floating point numbers and returns the sum.
-func add(args ...Number) Number {
-	var result Number
-	for _, v := range args {
-		result += v
-	}
-	return result
+// Add adds the given numbers and returns the sum.
+func add(args ...Value) (Value, error) {
+	return addOp.fold(Int(0), args)
 }
 
-// Sub subtracts args from 'x' and returns the final result.
-func sub(x Number, args ...Number) Number {
-	result := x
+// Sub subtracts args from 'x' and returns the final result, or the
+// negation of 'x' without args.
+func sub(x Value, args ...Value) (Value, error) {
 	if len(args) == 0 {
-		return -1 * result
-	}
-
-	for _, v := range args {
-		result -= v
+		return subOp.apply(Int(0), x)
 	}
-	return result
+	return subOp.fold(x, args)
 }
 
 // Multiply multiplies the given args to 1 and returns the result.
-func multiply(first Number, args ...Number) Number {
-	result := first
-	for _, v := range args {
-		result *= v
-	}
-	return result
+func multiply(first Value, args ...Value) (Value, error) {
+	return mulOp.fold(first, args)
 }
 
-// Divide returns the product of given numbers.
-func divide(first Number, args ...Number) Number {
-
-	result := first
-
+// Divide divides 'first' by args in turn, or 1 by 'first' without args.
+// Integers which do not divide exactly return a Ratio.
+func divide(first Value, args ...Value) (Value, error) {
 	if len(args) == 0 {
-		return 1 / first
+		return divOp.apply(Int(1), first)
 	}
+	return divOp.fold(first, args)
+}
 
-	for _, v := range args {
-		result /= v
-	}
-	return result
+// Quot returns the quotient of dividing num by div, rounded towards zero.
+func quot(num, div Value) (Value, error) {
+	return quotOp.apply(num, div)
+}
+
+// Rem returns the remainder of dividing num by div, which has the sign of
+// num.
+func rem(num, div Value) (Value, error) {
+	return remOp.apply(num, div)
+}
 
+// Mod returns num modulo div, which has the sign of div.
+func mod(num, div Value) (Value, error) {
+	return modOp.apply(num, div)
 }
 
 // Lt returns true if the given args are monotonically increasing.
-func lt(base Number, args ...Number) bool {
-	inc := true
-	for _, arg := range args {
-		inc = inc && (arg > base)
-	}
-	return inc
+func lt(base Value, args ...Value) (bool, error) {
+	return monotonic(base, args, func(c int) bool { return c < 0 })
 }
 
 // LtE returns true if the given args are monotonically increasing or
 // are all equal.
-func ltE(base Number, args ...Number) bool {
-	inc := true
-	for _, arg := range args {
-		inc = inc && (arg >= base)
-	}
-	return inc
+func ltE(base Value, args ...Value) (bool, error) {
+	return monotonic(base, args, func(c int) bool { return c <= 0 })
 }
 
 // Gt returns true if the given args are monotonically decreasing.
-func gt(base Number, args ...Number) bool {
-	inc := true
-	for _, arg := range args {
-		inc = inc && (arg < base)
-	}
-	return inc
+func gt(base Value, args ...Value) (bool, error) {
+	return monotonic(base, args, func(c int) bool { return c > 0 })
 }
 
 // GtE returns true if the given args are monotonically decreasing or
 // all equal.
-func gtE(base Number, args ...Number) bool {
-	inc := true
+func gtE(base Value, args ...Value) (bool, error) {
+	return monotonic(base, args, func(c int) bool { return c >= 0 })
+}
+
+// monotonic returns true if each pair of consecutive args compares as
+// ordered. NaN is not ordered with any number.
+func monotonic(base Value, args []Value, ordered func(c int) bool) (bool, error) {
+	result := true
+	prev := base
 	for _, arg := range args {
-		inc = inc && (arg <= base)
+		c, ok, err := compareNums(prev, arg)
+		if err != nil {
+			return false, err
+		}
+
+		result = result && ok && ordered(c)
+		prev = arg
 	}
-	return inc
+	return result, nil
 }
 
 func isPrime(value Number) bool {
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// moduleExt is the extension of the files namespaces are read from.
+const moduleExt = ".st"
+
+// AddPath appends the directories to the search path. Relative imports
+// which are not found next to the importing file are looked up in the
+// search path in order.
+func (s *Spirit) AddPath(dirs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		s.paths = append(s.paths, dir)
+	}
+}
+
+// findModule returns the canonical path of the file to import. A relative
+// path is looked up in the directory of the file being read, or in the
+// working directory if there is none, and then in the search path.
+func (s *Spirit) findModule(file string) (string, error) {
+	if filepath.IsAbs(file) {
+		return canonicalPath(file)
+	}
+
+	s.mu.RLock()
+	dirs := make([]string, 0, len(s.paths)+1)
+	if n := len(s.reading); n > 0 {
+		dirs = append(dirs, filepath.Dir(s.reading[n-1]))
+	} else {
+		dirs = append(dirs, "")
+	}
+	dirs = append(dirs, s.paths...)
+	s.mu.RUnlock()
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, file)
+		if _, err := os.Stat(path); err == nil {
+			return canonicalPath(path)
+		}
+	}
+
+	return "", fmt.Errorf("module '%s' not found", file)
+}
+
+// canonicalPath returns the absolute path of the file with symbolic links
+// resolved, so every file is imported once however it is referred to.
+func canonicalPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return abs, nil
+}
+
+// modulePath returns the path of the file the namespace is read from. The
+// file of the namespace foo.bar is foo/bar.st.
+func modulePath(ns string) string {
+	return strings.ReplaceAll(ns, ".", string(filepath.Separator)) + moduleExt
+}
+
+// pushReading marks the file as being read, relative imports are resolved
+// against its directory until popReading is called.
+func (s *Spirit) pushReading(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reading = append(s.reading, path)
+}
+
+func (s *Spirit) popReading() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reading = s.reading[:len(s.reading)-1]
+}
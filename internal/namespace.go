@@ -0,0 +1,465 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Namespace is a named group of definitions. The definitions themselves
+// are kept in the Bindings of the Spirit instance, the namespace holds the
+// aliases of the namespaces it requires, the names it refers from other
+// namespaces and which of its definitions are private.
+type Namespace struct {
+	Name    string
+	aliases map[string]string
+	refers  map[string]nsSymbol
+	private map[string]bool
+}
+
+func newNamespace(name string) *Namespace {
+	return &Namespace{
+		Name:    name,
+		aliases: map[string]string{},
+		refers:  map[string]nsSymbol{},
+		private: map[string]bool{},
+	}
+}
+
+// Eval returns the namespace itself.
+func (ns *Namespace) Eval(_ Scope) (Value, error) {
+	return ns, nil
+}
+
+func (ns *Namespace) String() string {
+	return fmt.Sprintf("<Namespace(%s)>", ns.Name)
+}
+
+// namespace returns the namespace of the name, creating it if it does not
+// exist. The caller must hold the write lock.
+func (s *Spirit) namespace(name string) *Namespace {
+	ns, found := s.namespaces[name]
+	if !found {
+		ns = newNamespace(name)
+		s.namespaces[name] = ns
+	}
+	return ns
+}
+
+// resolveIn resolves the symbol as seen from the namespace. An unqualified
+// symbol is looked up in the definitions of the namespace, then in the
+// names it refers and last in core. A qualified symbol is looked up in the
+// namespace it names, or in the namespace aliased by that name, and must
+// not be private to another namespace. The caller must hold the lock.
+func (s *Spirit) resolveIn(ns, symbol string) (Value, error) {
+	nsSym, err := s.qualify(ns, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	current := s.namespaces[ns]
+	if !isQualified(symbol) {
+		syms := []nsSymbol{*nsSym}
+		if current != nil {
+			if ref, found := current.refers[nsSym.Name]; found {
+				syms = append(syms, ref)
+			}
+		}
+
+		if core := nsSym.WithNS("core"); !s.isPrivate(core) {
+			syms = append(syms, core)
+		}
+		return s.resolveAny(symbol, syms...)
+	}
+
+	if current != nil {
+		if target, found := current.aliases[nsSym.NS]; found {
+			nsSym.NS = target
+		}
+	}
+
+	if nsSym.NS != ns && s.isPrivate(*nsSym) {
+		return nil, ResolveError{Sym: Symbol{Value: symbol}, Private: true}
+	}
+
+	return s.resolveAny(symbol, *nsSym)
+}
+
+// isPrivate returns true if the symbol is defined private to its
+// namespace. The caller must hold the lock.
+func (s *Spirit) isPrivate(sym nsSymbol) bool {
+	ns, found := s.namespaces[sym.NS]
+	return found && ns.private[sym.Name]
+}
+
+func isQualified(symbol string) bool {
+	return symbol != string(nsSeparator) &&
+		strings.ContainsRune(symbol, nsSeparator)
+}
+
+// require loads the namespace of the spec unless it exists and makes its
+// definitions available in the current namespace. The spec is either the
+// symbol of the namespace or a vector of the symbol and options:
+//
+//	[foo.bar :as bar :refer [x y]]
+//	[foo.bar :refer :all]
+func (s *Spirit) require(spec Value) error {
+	name, opts := spec, []Value(nil)
+	if vec, ok := spec.(*Vector); ok && vec.Size() > 0 {
+		name, opts = vec.Index(0), vec.GetValues()[1:]
+	}
+
+	sym, ok := name.(Symbol)
+	if !ok {
+		return TypeError{Expected: Symbol{}, Got: name}
+	}
+
+	if len(opts)%2 != 0 {
+		return fmt.Errorf("expecting pairs of options in require spec %v", spec)
+	}
+
+	if err := s.loadNS(sym.Value); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.namespace(s.currentNS)
+	for i := 0; i < len(opts); i += 2 {
+		switch opts[i] {
+		case Keyword("as"):
+			alias, ok := opts[i+1].(Symbol)
+			if !ok {
+				return TypeError{Expected: Symbol{}, Got: opts[i+1]}
+			}
+			current.aliases[alias.Value] = sym.Value
+
+		case Keyword("refer"):
+			if err := s.refer(current, sym.Value, opts[i+1]); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unknown require option %v", opts[i])
+		}
+	}
+
+	atomic.AddUint64(&s.version, 1)
+	return nil
+}
+
+// refer maps the names to the definitions of the other namespace. Names
+// is a vector of symbols or :all for every public definition. The caller
+// must hold the write lock.
+func (s *Spirit) refer(ns *Namespace, from string, names Value) error {
+	if names == Keyword("all") {
+		for sym := range s.Bindings {
+			if sym.NS == from && !s.isPrivate(sym) {
+				ns.refers[sym.Name] = sym
+			}
+		}
+		return nil
+	}
+
+	vec, ok := names.(*Vector)
+	if !ok {
+		return TypeError{Expected: &Vector{}, Got: names}
+	}
+
+	for _, name := range vec.GetValues() {
+		sym, ok := name.(Symbol)
+		if !ok {
+			return TypeError{Expected: Symbol{}, Got: name}
+		}
+
+		target := nsSymbol{NS: from, Name: sym.Value}
+		qualified := Symbol{Value: from + string(nsSeparator) + sym.Value}
+		if _, found := s.Bindings[target]; !found {
+			return ResolveError{Sym: qualified}
+		}
+
+		if s.isPrivate(target) {
+			return ResolveError{Sym: qualified, Private: true}
+		}
+
+		ns.refers[sym.Value] = target
+	}
+
+	return nil
+}
+
+// loadNS reads the file of the namespace unless the namespace exists. The
+// file of the namespace foo.bar is foo/bar.st, looked up like the files of
+// ReadFile(). The current namespace is restored once the file is read, even
+// if reading it fails.
+func (s *Spirit) loadNS(name string) error {
+	if s.findNS(name) != nil {
+		return nil
+	}
+
+	if !s.Allowed(CapFileRead) {
+		return PermissionError{Name: "require", Capability: CapFileRead}
+	}
+
+	current := Symbol{Value: s.CurrentNS()}
+	defer func() { _ = s.SwitchNS(current) }()
+
+	file := modulePath(name)
+	if _, err := s.ReadFile(file); err != nil {
+		s.dropNS(name)
+		return err
+	}
+
+	if s.findNS(name) == nil {
+		return ImportError{fmt.Errorf("namespace '%s' not found in %s", name, file)}
+	}
+	return nil
+}
+
+// dropNS removes the namespace and its definitions, such as what is left
+// of a namespace whose file failed to load.
+func (s *Spirit) dropNS(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.namespaces[name]; !found {
+		return
+	}
+
+	delete(s.namespaces, name)
+	for sym := range s.Bindings {
+		if sym.NS == name {
+			delete(s.Bindings, sym)
+		}
+	}
+	atomic.AddUint64(&s.version, 1)
+}
+
+func (s *Spirit) findNS(name string) *Namespace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.namespaces[name]
+}
+
+// theNS returns the namespace of the value, which is either a namespace
+// or the symbol of one.
+func (s *Spirit) theNS(v Value) (*Namespace, error) {
+	switch ns := v.(type) {
+	case *Namespace:
+		return ns, nil
+
+	case Symbol:
+		if found := s.findNS(ns.Value); found != nil {
+			return found, nil
+		}
+		return nil, fmt.Errorf("no namespace: '%s' found", ns.Value)
+	}
+
+	return nil, TypeError{Expected: Symbol{}, Got: v}
+}
+
+func spiritOf(scope Scope) (*Spirit, error) {
+	spirit, ok := RootScope(scope).(*Spirit)
+	if !ok {
+		return nil, fmt.Errorf("expecting Spirit instance")
+	}
+	return spirit, nil
+}
+
+// nsForm implements (ns name (:require spec*)*). It switches to the
+// namespace, creating it if needed, and requires the specs. The name may
+// also be quoted as in (ns 'name).
+func nsForm(scope Scope, args []Value) (Value, error) {
+	if len(args) < 1 {
+		return nil, ArgumentError{
+			Got: len(args),
+			Fn:  "ns",
+		}
+	}
+
+	name := args[0]
+	if quoted, ok := name.(*List); ok {
+		v, err := quoted.Eval(scope)
+		if err != nil {
+			return nil, err
+		}
+		name = v
+	}
+
+	sym, ok := name.(Symbol)
+	if !ok {
+		return nil, TypeError{Expected: Symbol{}, Got: name}
+	}
+
+	spirit, err := spiritOf(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := spirit.SwitchNS(sym); err != nil {
+		return nil, err
+	}
+
+	for _, clause := range args[1:] {
+		list, ok := clause.(*List)
+		if !ok || list.Size() == 0 || list.First() != Keyword("require") {
+			return nil, fmt.Errorf("invalid ns clause %v, expecting (:require spec*)", clause)
+		}
+
+		for _, spec := range list.Values[1:] {
+			if err := spirit.require(spec); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sym, nil
+}
+
+// require makes the namespaces of the specs available in the current
+// namespace, reading their files if needed. See Spirit.require().
+func require(scope Scope, specs ...Value) error {
+	spirit, err := spiritOf(scope)
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		if err := spirit.require(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nsPrivate makes the definitions of the symbols private to the current
+// namespace. Private definitions cannot be resolved or referred from other
+// namespaces.
+func nsPrivate(scope Scope, syms ...Symbol) error {
+	spirit, err := spiritOf(scope)
+	if err != nil {
+		return err
+	}
+
+	spirit.mu.Lock()
+	defer spirit.mu.Unlock()
+
+	ns := spirit.namespace(spirit.currentNS)
+	for _, sym := range syms {
+		ns.private[sym.Value] = true
+	}
+
+	atomic.AddUint64(&spirit.version, 1)
+	return nil
+}
+
+// nsPublics returns the public definitions of the namespace mapped by
+// their symbols.
+func nsPublics(scope Scope, v Value) (*HashMap, error) {
+	return nsMappings(scope, v, false)
+}
+
+// nsMap returns every definition of the namespace and the names it refers
+// mapped by their symbols.
+func nsMap(scope Scope, v Value) (*HashMap, error) {
+	return nsMappings(scope, v, true)
+}
+
+func nsMappings(scope Scope, v Value, all bool) (*HashMap, error) {
+	spirit, err := spiritOf(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := spirit.theNS(v)
+	if err != nil {
+		return nil, err
+	}
+
+	spirit.mu.RLock()
+	defer spirit.mu.RUnlock()
+
+	var hm Value = NewHashMap()
+	for sym, val := range spirit.Bindings {
+		if sym.NS == ns.Name && (all || !ns.private[sym.Name]) {
+			hm = hm.(*HashMap).Set(Symbol{Value: sym.Name}, val)
+		}
+	}
+
+	if all {
+		for name, ref := range ns.refers {
+			if val, found := spirit.Bindings[ref]; found {
+				hm = hm.(*HashMap).Set(Symbol{Value: name}, val)
+			}
+		}
+	}
+
+	return hm.(*HashMap), nil
+}
+
+// nsUnmap removes the definition of the symbol, or the name it refers,
+// from the namespace.
+func nsUnmap(scope Scope, v Value, sym Symbol) error {
+	spirit, err := spiritOf(scope)
+	if err != nil {
+		return err
+	}
+
+	ns, err := spirit.theNS(v)
+	if err != nil {
+		return err
+	}
+
+	spirit.mu.Lock()
+	defer spirit.mu.Unlock()
+
+	delete(spirit.Bindings, nsSymbol{NS: ns.Name, Name: sym.Value})
+	delete(ns.refers, sym.Value)
+	delete(ns.private, sym.Value)
+
+	atomic.AddUint64(&spirit.version, 1)
+	return nil
+}
+
+// allNS returns every namespace ordered by name.
+func allNS(scope Scope) (*List, error) {
+	spirit, err := spiritOf(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	spirit.mu.RLock()
+	defer spirit.mu.RUnlock()
+
+	names := make([]string, 0, len(spirit.namespaces))
+	for name := range spirit.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := &List{}
+	for _, name := range names {
+		list.Values = append(list.Values, spirit.namespaces[name])
+	}
+	return list, nil
+}
+
+// findNS returns the namespace of the symbol, or nil if there is none.
+func findNS(scope Scope, sym Symbol) (Value, error) {
+	spirit, err := spiritOf(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if ns := spirit.findNS(sym.Value); ns != nil {
+		return ns, nil
+	}
+	return Nil{}, nil
+}
+
+func nsName(ns *Namespace) Symbol {
+	return Symbol{Value: ns.Name}
+}
@@ -0,0 +1,817 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Int represents 64-bit integers. Integer arithmetic which overflows an
+// Int returns a BigInt instead.
+type Int int64
+
+// Eval returns the integer itself.
+func (i Int) Eval(_ Scope) (Value, error) { return i, nil }
+
+func (i Int) String() string { return strconv.FormatInt(int64(i), 10) }
+
+// Compare returns true if the other value is a number of the same value.
+func (i Int) Compare(other Value) bool { return numEqual(i, other) }
+
+func (i Int) Hash() uint32 { return hashNumber(i) }
+
+// BigInt represents integers which do not fit in an Int. Results which fit
+// are returned as an Int.
+type BigInt struct{ Int *big.Int }
+
+// Eval returns the integer itself.
+func (b BigInt) Eval(_ Scope) (Value, error) { return b, nil }
+
+func (b BigInt) String() string { return b.Int.String() }
+
+// Compare returns true if the other value is a number of the same value.
+func (b BigInt) Compare(other Value) bool { return numEqual(b, other) }
+
+func (b BigInt) Hash() uint32 { return hashNumber(b) }
+
+// Ratio represents an exact fraction in lowest terms. Ratios which are whole
+// numbers are returned as integers.
+type Ratio struct{ Rat *big.Rat }
+
+// Eval returns the ratio itself.
+func (r Ratio) Eval(_ Scope) (Value, error) { return r, nil }
+
+func (r Ratio) String() string { return r.Rat.String() }
+
+// Compare returns true if the other value is a number of the same value.
+func (r Ratio) Compare(other Value) bool { return numEqual(r, other) }
+
+func (r Ratio) Hash() uint32 { return hashNumber(r) }
+
+// BigDecimal represents an exact decimal number, the unscaled value divided
+// by ten to the power of the scale. Literals are written with an M suffix,
+// as in 1.50M.
+type BigDecimal struct {
+	Unscaled *big.Int
+	Scale    int32
+}
+
+// Eval returns the decimal itself.
+func (d BigDecimal) Eval(_ Scope) (Value, error) { return d, nil }
+
+func (d BigDecimal) String() string {
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	if d.Scale > 0 {
+		if pad := int(d.Scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		at := len(digits) - int(d.Scale)
+		digits = digits[:at] + "." + digits[at:]
+	}
+
+	if d.Unscaled.Sign() < 0 {
+		digits = "-" + digits
+	}
+	return digits + "M"
+}
+
+// Compare returns true if the other value is a number of the same value.
+func (d BigDecimal) Compare(other Value) bool { return numEqual(d, other) }
+
+func (d BigDecimal) Hash() uint32 { return hashNumber(d) }
+
+// rat returns the exact value of the decimal.
+func (d BigDecimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Unscaled, pow10(d.Scale))
+}
+
+// rescale returns the decimal with the unscaled value multiplied to the
+// scale given, which must not be smaller than the scale of the decimal.
+func (d BigDecimal) rescale(scale int32) *big.Int {
+	return new(big.Int).Mul(d.Unscaled, pow10(scale-d.Scale))
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// numKind orders the kinds of numbers. Arithmetic converts its arguments to
+// the highest kind among them.
+type numKind int
+
+const (
+	kindInt numKind = iota
+	kindBig
+	kindRatio
+	kindDecimal
+	kindFloat
+)
+
+func kindOf(v Value) (numKind, bool) {
+	switch v.(type) {
+	case Int:
+		return kindInt, true
+	case BigInt:
+		return kindBig, true
+	case Ratio:
+		return kindRatio, true
+	case BigDecimal:
+		return kindDecimal, true
+	case Number:
+		return kindFloat, true
+	}
+	return 0, false
+}
+
+func isNumber(v Value) bool {
+	_, ok := kindOf(v)
+	return ok
+}
+
+func isInteger(v Value) bool {
+	k, ok := kindOf(v)
+	return ok && k <= kindBig
+}
+
+// bigValue returns the integer as an Int if it fits.
+func bigValue(i *big.Int) Value {
+	if i.IsInt64() {
+		return Int(i.Int64())
+	}
+	return BigInt{Int: i}
+}
+
+// ratValue returns the fraction as an integer if it is a whole number.
+func ratValue(r *big.Rat) Value {
+	if r.IsInt() {
+		return bigValue(new(big.Int).Set(r.Num()))
+	}
+	return Ratio{Rat: r}
+}
+
+// toBig returns an integer as a big.Int.
+func toBig(v Value) *big.Int {
+	if b, ok := v.(BigInt); ok {
+		return b.Int
+	}
+	return big.NewInt(int64(v.(Int)))
+}
+
+// toRat returns the exact value of a number, nil for infinities and NaN.
+func toRat(v Value) *big.Rat {
+	switch n := v.(type) {
+	case Int:
+		return new(big.Rat).SetInt64(int64(n))
+	case BigInt:
+		return new(big.Rat).SetInt(n.Int)
+	case Ratio:
+		return n.Rat
+	case BigDecimal:
+		return n.rat()
+	case Number:
+		return new(big.Rat).SetFloat64(float64(n))
+	}
+	return nil
+}
+
+// toFloat returns the number as the closest float64.
+func toFloat(v Value) float64 {
+	if n, ok := v.(Number); ok {
+		return float64(n)
+	}
+
+	f, _ := toRat(v).Float64()
+	return f
+}
+
+// toDecimal returns an exact number as a BigDecimal. Fails for ratios
+// without a terminating decimal expansion.
+func toDecimal(v Value) (BigDecimal, error) {
+	switch n := v.(type) {
+	case BigDecimal:
+		return n, nil
+	case Int, BigInt:
+		return BigDecimal{Unscaled: toBig(n)}, nil
+	}
+	return ratDecimal(toRat(v), 0)
+}
+
+// ratDecimal returns the fraction as a BigDecimal of the smallest scale it
+// can be written with, and at least the scale given.
+func ratDecimal(r *big.Rat, scale int32) (BigDecimal, error) {
+	// the expansion terminates if the denominator has no prime factors
+	// other than 2 and 5
+	den := new(big.Int).Set(r.Denom())
+	twos, fives := factorOut(den, 2), factorOut(den, 5)
+	if den.Cmp(big.NewInt(1)) != 0 {
+		return BigDecimal{}, ArithmeticError{
+			Reason: fmt.Sprintf("%s has no exact decimal expansion", r.RatString()),
+		}
+	}
+
+	if twos > scale {
+		scale = twos
+	}
+	if fives > scale {
+		scale = fives
+	}
+
+	unscaled := new(big.Int).Mul(r.Num(), pow10(scale))
+	return BigDecimal{Unscaled: unscaled.Quo(unscaled, r.Denom()), Scale: scale}, nil
+}
+
+// factorOut divides n by f for as long as it is divisible and returns the
+// number of times it did.
+func factorOut(n *big.Int, f int64) int32 {
+	q, m, d := new(big.Int), new(big.Int), big.NewInt(f)
+	for count := int32(0); ; count++ {
+		if q.QuoRem(n, d, m); m.Sign() != 0 {
+			return count
+		}
+		n.Set(q)
+	}
+}
+
+// numEqual returns true if both values are numbers of the same value,
+// whatever their kinds.
+func numEqual(a, b Value) bool {
+	c, ok, err := compareNums(a, b)
+	return err == nil && ok && c == 0
+}
+
+// compareNums returns -1, 0 or 1 as a is less than, equal to or greater
+// than b. Returns false if they are unordered, when one is NaN. Floats
+// are compared to exact numbers by their exact value.
+func compareNums(a, b Value) (int, bool, error) {
+	ka, err := numArg(a)
+	if err != nil {
+		return 0, false, err
+	}
+
+	kb, err := numArg(b)
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case ka == kindInt && kb == kindInt:
+		x, y := a.(Int), b.(Int)
+		if x < y {
+			return -1, true, nil
+		} else if x > y {
+			return 1, true, nil
+		}
+		return 0, true, nil
+
+	case ka == kindFloat || kb == kindFloat:
+		x, y := toFloat(a), toFloat(b)
+		if math.IsNaN(x) || math.IsNaN(y) {
+			return 0, false, nil
+		}
+
+		if math.IsInf(x, 0) || math.IsInf(y, 0) {
+			if x < y {
+				return -1, true, nil
+			} else if x > y {
+				return 1, true, nil
+			}
+			return 0, true, nil
+		}
+	}
+
+	return toRat(a).Cmp(toRat(b)), true, nil
+}
+
+// numArg returns the kind of the number, or a TypeError if the value is not
+// a number.
+func numArg(v Value) (numKind, error) {
+	k, ok := kindOf(v)
+	if !ok {
+		return 0, TypeError{
+			Expected: Number(0),
+			Got:      v,
+		}
+	}
+	return k, nil
+}
+
+// numOp is an arithmetic operation defined for each kind of number. Its
+// arguments are converted to the highest kind among them. An operation
+// without a function for a kind is done on the next kind up, ints
+// returning false overflowed and are done on big integers, and decimals
+// are computed from the result on ratios.
+type numOp struct {
+	ints   func(a, b int64) (int64, bool)
+	bigs   func(a, b *big.Int) *big.Int
+	rats   func(a, b *big.Rat) *big.Rat
+	decs   func(a, b BigDecimal) (BigDecimal, error)
+	floats func(a, b float64) float64
+
+	// zeroDivisor is set for operations failing on an exact zero divisor.
+	zeroDivisor bool
+	// floatZero is set if they also fail on a float zero divisor.
+	floatZero bool
+}
+
+func (op numOp) apply(a, b Value) (Value, error) {
+	ka, err := numArg(a)
+	if err != nil {
+		return nil, err
+	}
+
+	kb, err := numArg(b)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := ka
+	if kb > kind {
+		kind = kb
+	}
+
+	if op.zeroDivisor && (kind != kindFloat || op.floatZero) && toFloat(b) == 0 {
+		return nil, ArithmeticError{Reason: "divide by zero"}
+	}
+
+	switch kind {
+	case kindInt:
+		if op.ints != nil {
+			if r, ok := op.ints(int64(a.(Int)), int64(b.(Int))); ok {
+				return Int(r), nil
+			}
+		}
+		fallthrough
+
+	case kindBig:
+		if op.bigs != nil {
+			return bigValue(op.bigs(toBig(a), toBig(b))), nil
+		}
+		fallthrough
+
+	case kindRatio:
+		return ratValue(op.rats(toRat(a), toRat(b))), nil
+
+	case kindDecimal:
+		if op.decs == nil {
+			return ratDecimal(op.rats(toRat(a), toRat(b)), 0)
+		}
+
+		x, err := toDecimal(a)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := toDecimal(b)
+		if err != nil {
+			return nil, err
+		}
+		return op.decs(x, y)
+	}
+
+	return Number(op.floats(toFloat(a), toFloat(b))), nil
+}
+
+// fold applies the operation to the values from left to right.
+func (op numOp) fold(acc Value, vals []Value) (Value, error) {
+	if len(vals) == 0 {
+		if _, err := numArg(acc); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range vals {
+		var err error
+		if acc, err = op.apply(acc, v); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+var (
+	addOp = numOp{
+		ints: func(a, b int64) (int64, bool) {
+			r := a + b
+			return r, (a >= 0) != (b >= 0) || (r >= 0) == (a >= 0)
+		},
+		bigs: func(a, b *big.Int) *big.Int { return new(big.Int).Add(a, b) },
+		rats: func(a, b *big.Rat) *big.Rat { return new(big.Rat).Add(a, b) },
+		decs: func(a, b BigDecimal) (BigDecimal, error) {
+			scale := maxScale(a, b)
+			return BigDecimal{
+				Unscaled: new(big.Int).Add(a.rescale(scale), b.rescale(scale)),
+				Scale:    scale,
+			}, nil
+		},
+		floats: func(a, b float64) float64 { return a + b },
+	}
+
+	subOp = numOp{
+		ints: func(a, b int64) (int64, bool) {
+			r := a - b
+			return r, (a >= 0) == (b >= 0) || (r >= 0) == (a >= 0)
+		},
+		bigs: func(a, b *big.Int) *big.Int { return new(big.Int).Sub(a, b) },
+		rats: func(a, b *big.Rat) *big.Rat { return new(big.Rat).Sub(a, b) },
+		decs: func(a, b BigDecimal) (BigDecimal, error) {
+			scale := maxScale(a, b)
+			return BigDecimal{
+				Unscaled: new(big.Int).Sub(a.rescale(scale), b.rescale(scale)),
+				Scale:    scale,
+			}, nil
+		},
+		floats: func(a, b float64) float64 { return a - b },
+	}
+
+	mulOp = numOp{
+		ints: func(a, b int64) (int64, bool) {
+			if a == 0 || b == 0 {
+				return 0, true
+			}
+
+			r := a * b
+			overflow := r/b != a ||
+				(a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)
+			return r, !overflow
+		},
+		bigs: func(a, b *big.Int) *big.Int { return new(big.Int).Mul(a, b) },
+		rats: func(a, b *big.Rat) *big.Rat { return new(big.Rat).Mul(a, b) },
+		decs: func(a, b BigDecimal) (BigDecimal, error) {
+			return BigDecimal{
+				Unscaled: new(big.Int).Mul(a.Unscaled, b.Unscaled),
+				Scale:    a.Scale + b.Scale,
+			}, nil
+		},
+		floats: func(a, b float64) float64 { return a * b },
+	}
+
+	// divOp divides integers to a ratio, and floats by zero to an infinity.
+	divOp = numOp{
+		rats: func(a, b *big.Rat) *big.Rat { return new(big.Rat).Quo(a, b) },
+		decs: func(a, b BigDecimal) (BigDecimal, error) {
+			return ratDecimal(new(big.Rat).Quo(a.rat(), b.rat()), a.Scale-b.Scale)
+		},
+		floats:      func(a, b float64) float64 { return a / b },
+		zeroDivisor: true,
+	}
+
+	quotOp = numOp{
+		ints: func(a, b int64) (int64, bool) {
+			return a / b, !(a == math.MinInt64 && b == -1)
+		},
+		bigs: func(a, b *big.Int) *big.Int { return new(big.Int).Quo(a, b) },
+		rats: func(a, b *big.Rat) *big.Rat {
+			return new(big.Rat).SetInt(truncRat(new(big.Rat).Quo(a, b)))
+		},
+		floats:      func(a, b float64) float64 { return math.Trunc(a / b) },
+		zeroDivisor: true,
+		floatZero:   true,
+	}
+
+	remOp = numOp{
+		ints: func(a, b int64) (int64, bool) { return a % b, true },
+		bigs: func(a, b *big.Int) *big.Int { return new(big.Int).Rem(a, b) },
+		rats: func(a, b *big.Rat) *big.Rat {
+			q := new(big.Rat).SetInt(truncRat(new(big.Rat).Quo(a, b)))
+			return q.Sub(a, q.Mul(q, b))
+		},
+		floats:      math.Mod,
+		zeroDivisor: true,
+		floatZero:   true,
+	}
+
+	// modOp rounds the quotient down, so the result has the sign of the
+	// divisor.
+	modOp = numOp{
+		ints: func(a, b int64) (int64, bool) {
+			m := a % b
+			if m != 0 && (m < 0) != (b < 0) {
+				m += b
+			}
+			return m, true
+		},
+		bigs: func(a, b *big.Int) *big.Int {
+			m := new(big.Int).Rem(a, b)
+			if m.Sign() != 0 && m.Sign() != b.Sign() {
+				m.Add(m, b)
+			}
+			return m
+		},
+		rats: func(a, b *big.Rat) *big.Rat {
+			q := new(big.Rat).Quo(a, b)
+			floor := truncRat(q)
+			if q.Sign() < 0 && !q.IsInt() {
+				floor.Sub(floor, big.NewInt(1))
+			}
+
+			m := new(big.Rat).SetInt(floor)
+			return m.Sub(a, m.Mul(m, b))
+		},
+		floats: func(a, b float64) float64 {
+			m := math.Mod(a, b)
+			if m != 0 && (m < 0) != (b < 0) {
+				m += b
+			}
+			return m
+		},
+		zeroDivisor: true,
+		floatZero:   true,
+	}
+)
+
+func maxScale(a, b BigDecimal) int32 {
+	if a.Scale > b.Scale {
+		return a.Scale
+	}
+	return b.Scale
+}
+
+// truncRat returns the fraction rounded towards zero.
+func truncRat(r *big.Rat) *big.Int {
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+// bitOp applies a bitwise operation to integers.
+func bitOp(name string, ints func(a, b int64) int64,
+	bigs func(z, a, b *big.Int) *big.Int) func(Value, Value, ...Value) (Value, error) {
+	return func(x, y Value, more ...Value) (Value, error) {
+		acc := x
+		for _, v := range append([]Value{y}, more...) {
+			for _, arg := range []Value{acc, v} {
+				if !isInteger(arg) {
+					return nil, fmt.Errorf("%s: %w", name, TypeError{
+						Expected: Int(0),
+						Got:      arg,
+					})
+				}
+			}
+
+			a, aInt := acc.(Int)
+			b, bInt := v.(Int)
+			if aInt && bInt {
+				acc = Int(ints(int64(a), int64(b)))
+			} else {
+				acc = bigValue(bigs(new(big.Int), toBig(acc), toBig(v)))
+			}
+		}
+		return acc, nil
+	}
+}
+
+var (
+	bitAnd = bitOp("bit-and",
+		func(a, b int64) int64 { return a & b }, (*big.Int).And)
+	bitOr = bitOp("bit-or",
+		func(a, b int64) int64 { return a | b }, (*big.Int).Or)
+	bitXor = bitOp("bit-xor",
+		func(a, b int64) int64 { return a ^ b }, (*big.Int).Xor)
+)
+
+// bitShiftLeft shifts the integer left by n bits, to a BigInt if the result
+// does not fit in an Int.
+func bitShiftLeft(x Value, n int) (Value, error) {
+	if !isInteger(x) {
+		return nil, TypeError{Expected: Int(0), Got: x}
+	} else if n < 0 {
+		return nil, fmt.Errorf("bit-shift-left: negative shift count %d", n)
+	}
+
+	if i, ok := x.(Int); ok && n < 63 {
+		if r := i << uint(n); r>>uint(n) == i {
+			return r, nil
+		}
+	}
+	return bigValue(new(big.Int).Lsh(toBig(x), uint(n))), nil
+}
+
+// bitShiftRight shifts the integer right by n bits, keeping its sign.
+func bitShiftRight(x Value, n int) (Value, error) {
+	if !isInteger(x) {
+		return nil, TypeError{Expected: Int(0), Got: x}
+	} else if n < 0 {
+		return nil, fmt.Errorf("bit-shift-right: negative shift count %d", n)
+	}
+
+	if i, ok := x.(Int); ok {
+		if n > 63 {
+			n = 63
+		}
+		return i >> uint(n), nil
+	}
+	return bigValue(new(big.Int).Rsh(toBig(x), uint(n))), nil
+}
+
+// parseNumber parses an integer, ratio, float or BigDecimal written in
+// decimal.
+func parseNumber(s string) (Value, error) {
+	illegal := fmt.Errorf("illegal number format '%s'", s)
+
+	switch {
+	case strings.HasSuffix(s, "M"):
+		return parseDecimal(s[:len(s)-1], illegal)
+
+	case strings.HasSuffix(s, "N"):
+		i, ok := new(big.Int).SetString(s[:len(s)-1], 10)
+		if !ok {
+			return nil, illegal
+		}
+		return bigValue(i), nil
+
+	case strings.ContainsRune(s, '/'):
+		parts := strings.SplitN(s, "/", 2)
+		num, ok1 := new(big.Int).SetString(parts[0], 10)
+		den, ok2 := new(big.Int).SetString(parts[1], 10)
+		if !ok1 || !ok2 || strings.HasPrefix(parts[1], "-") || strings.HasPrefix(parts[1], "+") {
+			return nil, illegal
+		} else if den.Sign() == 0 {
+			return nil, ArithmeticError{Reason: "divide by zero"}
+		}
+		return ratValue(new(big.Rat).SetFrac(num, den)), nil
+
+	case strings.ContainsAny(s, ".eE") || strings.HasSuffix(s, "Inf") || s == "NaN":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, illegal
+		}
+		return Number(f), nil
+	}
+
+	return parseInt(s, 10, illegal)
+}
+
+// parseInt parses an integer in the base given, 0 for the base implied by
+// its prefix, to a BigInt if it does not fit in an Int.
+func parseInt(s string, base int, illegal error) (Value, error) {
+	if i, err := strconv.ParseInt(s, base, 64); err == nil {
+		return Int(i), nil
+	}
+
+	i, ok := new(big.Int).SetString(strings.TrimPrefix(s, "+"), base)
+	if !ok {
+		return nil, illegal
+	}
+	return bigValue(i), nil
+}
+
+func parseDecimal(s string, illegal error) (Value, error) {
+	var exp int64
+	if at := strings.IndexAny(s, "eE"); at >= 0 {
+		var err error
+		if exp, err = strconv.ParseInt(s[at+1:], 10, 32); err != nil {
+			return nil, illegal
+		}
+		s = s[:at]
+	}
+
+	digits, scale := s, int64(0)
+	if at := strings.IndexRune(s, '.'); at >= 0 {
+		digits, scale = s[:at]+s[at+1:], int64(len(s)-at-1)
+	}
+
+	if strings.ContainsAny(digits, ".eE") {
+		return nil, illegal
+	}
+
+	unscaled, ok := new(big.Int).SetString(strings.TrimPrefix(digits, "+"), 10)
+	if !ok {
+		return nil, illegal
+	}
+
+	scale -= exp
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(int32(-scale)))
+		scale = 0
+	} else if scale > math.MaxInt32 {
+		return nil, illegal
+	}
+	return BigDecimal{Unscaled: unscaled, Scale: int32(scale)}, nil
+}
+
+// toNumber returns numbers as they are and parses strings as a number
+// literal.
+func toNumber(v Value) (Value, error) {
+	if isNumber(v) {
+		return v, nil
+	}
+
+	if s, ok := v.(String); ok {
+		return parseNumber(strings.TrimSpace(string(s)))
+	}
+
+	return nil, TypeError{
+		Expected: Number(0),
+		Got:      v,
+	}
+}
+
+// toIndex returns the number as an index, false if it is not a whole
+// number.
+func toIndex(v Value) (int, bool) {
+	switch n := v.(type) {
+	case Int:
+		return int(n), true
+
+	case Number:
+		if n == Number(math.Trunc(float64(n))) {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+// toInt returns the number rounded towards zero as an integer.
+func toInt(v Value) (Value, error) {
+	switch n := v.(type) {
+	case Int, BigInt:
+		return n, nil
+
+	case Ratio, BigDecimal:
+		return bigValue(truncRat(toRat(n))), nil
+
+	case Number:
+		if math.IsNaN(float64(n)) || math.IsInf(float64(n), 0) {
+			return nil, ArithmeticError{Reason: fmt.Sprintf("%v is not a whole number", n)}
+		}
+
+		i, _ := big.NewFloat(math.Trunc(float64(n))).Int(nil)
+		return bigValue(i), nil
+	}
+
+	return nil, TypeError{
+		Expected: Number(0),
+		Got:      v,
+	}
+}
+
+// toDouble returns the number as the closest float.
+func toDouble(v Value) (Value, error) {
+	if _, err := numArg(v); err != nil {
+		return nil, err
+	}
+	return Number(toFloat(v)), nil
+}
+
+// toBigDecimal returns the number as a BigDecimal. Floats are converted
+// from the shortest decimal which reads back as the same float, so 0.1
+// becomes 0.1M.
+func toBigDecimal(v Value) (Value, error) {
+	n, ok := v.(Number)
+	if !ok {
+		if _, err := numArg(v); err != nil {
+			return nil, err
+		}
+		return toDecimal(v)
+	}
+
+	s := strconv.FormatFloat(float64(n), 'f', -1, 64)
+	return parseDecimal(s, ArithmeticError{Reason: fmt.Sprintf("%v has no decimal value", n)})
+}
+
+// numerator returns the numerator of a ratio in lowest terms, an integer
+// is its own numerator.
+func numerator(v Value) (Value, error) {
+	if !isInteger(v) {
+		r, ok := v.(Ratio)
+		if !ok {
+			return nil, TypeError{Expected: Ratio{}, Got: v}
+		}
+		return bigValue(new(big.Int).Set(r.Rat.Num())), nil
+	}
+	return v, nil
+}
+
+// denominator returns the denominator of a ratio in lowest terms, 1 for
+// integers.
+func denominator(v Value) (Value, error) {
+	if !isInteger(v) {
+		r, ok := v.(Ratio)
+		if !ok {
+			return nil, TypeError{Expected: Ratio{}, Got: v}
+		}
+		return bigValue(new(big.Int).Set(r.Rat.Denom())), nil
+	}
+	return Int(1), nil
+}
+
+func isRatio(v Value) bool {
+	_, ok := v.(Ratio)
+	return ok
+}
+
+func isDecimal(v Value) bool {
+	_, ok := v.(BigDecimal)
+	return ok
+}
+
+func isFloat(v Value) bool {
+	_, ok := v.(Number)
+	return ok
+}
+
+// isRational returns true for exact numbers.
+func isRational(v Value) bool {
+	k, ok := kindOf(v)
+	return ok && k != kindFloat
+}
@@ -0,0 +1,397 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+
+	"github.com/xiaq/persistent/hashmap"
+)
+
+// pmap is a persistent hash array mapped trie keyed by values, hashed with
+// hashOf and compared with Compare. It implements hashmap.Map and, unlike
+// the implementation it replaces, can be built in place by a transientMap.
+type pmap struct {
+	count int
+	root  mapNode
+}
+
+var emptyMap = &pmap{}
+
+// mapNode is a node of the trie. Nodes owned by edit are changed in place,
+// other nodes are copied. A nil edit always copies.
+type mapNode interface {
+	assoc(edit *owner, shift, hash uint32, k, v interface{}) (mapNode, bool)
+	// without returns nil once the node has no entry left.
+	without(edit *owner, shift, hash uint32, k interface{}) (mapNode, bool)
+	find(shift, hash uint32, k interface{}) (interface{}, bool)
+	children() []mapEntry
+}
+
+// mapEntry is either an entry of a key and a value or a child node.
+type mapEntry struct {
+	key, val interface{}
+	child    mapNode
+}
+
+func (m *pmap) Len() int { return m.count }
+
+func (m *pmap) Index(k interface{}) (interface{}, bool) {
+	if m.root == nil {
+		return nil, false
+	}
+	return m.root.find(0, hasher(k), k)
+}
+
+func (m *pmap) Assoc(k, v interface{}) hashmap.Map {
+	root, added := assocRoot(nil, m.root, k, v)
+	if added {
+		return &pmap{m.count + 1, root}
+	}
+	return &pmap{m.count, root}
+}
+
+func (m *pmap) Dissoc(k interface{}) hashmap.Map {
+	if m.root == nil {
+		return m
+	}
+
+	root, removed := m.root.without(nil, 0, hasher(k), k)
+	if !removed {
+		return m
+	}
+	return &pmap{m.count - 1, root}
+}
+
+func assocRoot(edit *owner, root mapNode, k, v interface{}) (mapNode, bool) {
+	if root == nil {
+		root = &bitmapNode{edit: edit}
+	}
+	return root.assoc(edit, 0, hasher(k), k, v)
+}
+
+func (m *pmap) Iterator() hashmap.Iterator {
+	it := &mapIterator{}
+	if m.root != nil {
+		it.stack = append(it.stack, m.root.children())
+		it.settle()
+	}
+	return it
+}
+
+func (m *pmap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for it, first := m.Iterator(), true; it.HasElem(); it.Next() {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		k, v := it.Elem()
+		kb, err := json.Marshal(fmt.Sprint(k))
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// bitmapNode holds the entries of up to 32 chunks of the hashes, in the
+// order of the chunks. The bitmap marks the chunks present.
+type bitmapNode struct {
+	edit    *owner
+	bitmap  uint32
+	entries []mapEntry
+}
+
+func chunkBit(shift, hash uint32) uint32 { return 1 << ((hash >> shift) & trieMask) }
+
+func (n *bitmapNode) index(bit uint32) int {
+	return bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *bitmapNode) editable(edit *owner) *bitmapNode {
+	if edit != nil && n.edit == edit {
+		return n
+	}
+	return &bitmapNode{
+		edit:    edit,
+		bitmap:  n.bitmap,
+		entries: append([]mapEntry(nil), n.entries...),
+	}
+}
+
+func (n *bitmapNode) assoc(edit *owner, shift, hash uint32, k, v interface{}) (mapNode, bool) {
+	bit := chunkBit(shift, hash)
+	i := n.index(bit)
+
+	if n.bitmap&bit == 0 {
+		m := n.editable(edit)
+		m.entries = append(m.entries, mapEntry{})
+		copy(m.entries[i+1:], m.entries[i:])
+		m.entries[i] = mapEntry{key: k, val: v}
+		m.bitmap |= bit
+		return m, true
+	}
+
+	e := n.entries[i]
+	if e.child != nil {
+		child, added := e.child.assoc(edit, shift+trieBits, hash, k, v)
+		if child == e.child {
+			return n, added
+		}
+
+		m := n.editable(edit)
+		m.entries[i].child = child
+		return m, added
+	}
+
+	m := n.editable(edit)
+	if compare(e.key, k) {
+		m.entries[i] = mapEntry{key: k, val: v}
+		return m, false
+	}
+
+	m.entries[i] = mapEntry{
+		child: newMapNode(edit, shift+trieBits, e.key, e.val, hash, k, v),
+	}
+	return m, true
+}
+
+// newMapNode returns a node of the two entries.
+func newMapNode(edit *owner, shift uint32, k1, v1 interface{}, h2 uint32, k2, v2 interface{}) mapNode {
+	h1 := hasher(k1)
+	if h1 == h2 {
+		return &collisionNode{
+			edit:    edit,
+			hash:    h1,
+			entries: []mapEntry{{key: k1, val: v1}, {key: k2, val: v2}},
+		}
+	}
+
+	var n mapNode = &bitmapNode{edit: edit}
+	n, _ = n.assoc(edit, shift, h1, k1, v1)
+	n, _ = n.assoc(edit, shift, h2, k2, v2)
+	return n
+}
+
+func (n *bitmapNode) without(edit *owner, shift, hash uint32, k interface{}) (mapNode, bool) {
+	bit := chunkBit(shift, hash)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+
+	i := n.index(bit)
+	e := n.entries[i]
+	if e.child != nil {
+		child, removed := e.child.without(edit, shift+trieBits, hash, k)
+		if !removed {
+			return n, false
+		} else if child != nil {
+			m := n.editable(edit)
+			m.entries[i].child = child
+			return m, true
+		}
+	} else if !compare(e.key, k) {
+		return n, false
+	}
+
+	if n.bitmap == bit {
+		return nil, true
+	}
+
+	m := n.editable(edit)
+	m.entries = append(m.entries[:i], m.entries[i+1:]...)
+	m.bitmap ^= bit
+	return m, true
+}
+
+func (n *bitmapNode) find(shift, hash uint32, k interface{}) (interface{}, bool) {
+	bit := chunkBit(shift, hash)
+	if n.bitmap&bit == 0 {
+		return nil, false
+	}
+
+	e := n.entries[n.index(bit)]
+	if e.child != nil {
+		return e.child.find(shift+trieBits, hash, k)
+	} else if compare(e.key, k) {
+		return e.val, true
+	}
+	return nil, false
+}
+
+func (n *bitmapNode) children() []mapEntry { return n.entries }
+
+// collisionNode holds the entries of keys of the same hash.
+type collisionNode struct {
+	edit    *owner
+	hash    uint32
+	entries []mapEntry
+}
+
+func (n *collisionNode) editable(edit *owner) *collisionNode {
+	if edit != nil && n.edit == edit {
+		return n
+	}
+	return &collisionNode{
+		edit:    edit,
+		hash:    n.hash,
+		entries: append([]mapEntry(nil), n.entries...),
+	}
+}
+
+func (n *collisionNode) indexOf(k interface{}) int {
+	for i, e := range n.entries {
+		if compare(e.key, k) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *collisionNode) assoc(edit *owner, shift, hash uint32, k, v interface{}) (mapNode, bool) {
+	if hash != n.hash {
+		// nest the node to tell the hashes apart
+		wrap := &bitmapNode{
+			edit:    edit,
+			bitmap:  chunkBit(shift, n.hash),
+			entries: []mapEntry{{child: n}},
+		}
+		return wrap.assoc(edit, shift, hash, k, v)
+	}
+
+	m := n.editable(edit)
+	if i := n.indexOf(k); i >= 0 {
+		m.entries[i] = mapEntry{key: k, val: v}
+		return m, false
+	}
+
+	m.entries = append(m.entries, mapEntry{key: k, val: v})
+	return m, true
+}
+
+func (n *collisionNode) without(edit *owner, shift, hash uint32, k interface{}) (mapNode, bool) {
+	i := n.indexOf(k)
+	if i < 0 {
+		return n, false
+	} else if len(n.entries) == 1 {
+		return nil, true
+	}
+
+	m := n.editable(edit)
+	m.entries = append(m.entries[:i], m.entries[i+1:]...)
+	return m, true
+}
+
+func (n *collisionNode) find(_, _ uint32, k interface{}) (interface{}, bool) {
+	if i := n.indexOf(k); i >= 0 {
+		return n.entries[i].val, true
+	}
+	return nil, false
+}
+
+func (n *collisionNode) children() []mapEntry { return n.entries }
+
+// mapIterator walks the trie depth first. The stack holds the entries left
+// on each level, the first entry on top is the current one.
+type mapIterator struct {
+	stack [][]mapEntry
+}
+
+// settle descends into child nodes until the current entry is a key.
+func (it *mapIterator) settle() {
+	for len(it.stack) > 0 {
+		top := len(it.stack) - 1
+		entries := it.stack[top]
+
+		if len(entries) == 0 {
+			it.stack = it.stack[:top]
+			continue
+		} else if entries[0].child == nil {
+			return
+		}
+
+		it.stack[top] = entries[1:]
+		it.stack = append(it.stack, entries[0].child.children())
+	}
+}
+
+func (it *mapIterator) Elem() (interface{}, interface{}) {
+	e := it.stack[len(it.stack)-1][0]
+	return e.key, e.val
+}
+
+func (it *mapIterator) HasElem() bool { return len(it.stack) > 0 }
+
+func (it *mapIterator) Next() {
+	top := len(it.stack) - 1
+	it.stack[top] = it.stack[top][1:]
+	it.settle()
+}
+
+// transientMap builds a map in place. It shares the trie of the map it was
+// made from and copies a node the first time it changes it. It must not be
+// used after persistent.
+type transientMap struct {
+	edit  *owner
+	count int
+	root  mapNode
+}
+
+// newTransientMap returns a transient of the map. Maps of other
+// implementations are copied.
+func newTransientMap(data hashmap.Map) *transientMap {
+	m, ok := data.(*pmap)
+	if !ok {
+		t := newTransientMap(emptyMap)
+		for it := data.Iterator(); it.HasElem(); it.Next() {
+			t.assoc(it.Elem())
+		}
+		return t
+	}
+
+	return &transientMap{edit: &owner{}, count: m.count, root: m.root}
+}
+
+func (t *transientMap) index(k interface{}) (interface{}, bool) {
+	if t.root == nil {
+		return nil, false
+	}
+	return t.root.find(0, hasher(k), k)
+}
+
+func (t *transientMap) assoc(k, v interface{}) {
+	root, added := assocRoot(t.edit, t.root, k, v)
+	if t.root = root; added {
+		t.count++
+	}
+}
+
+func (t *transientMap) dissoc(k interface{}) {
+	if t.root == nil {
+		return
+	}
+
+	root, removed := t.root.without(t.edit, 0, hasher(k), k)
+	if t.root = root; removed {
+		t.count--
+	}
+}
+
+// persistent returns the map built. The transient must not be used
+// afterwards.
+func (t *transientMap) persistent() *pmap {
+	t.edit = nil
+	return &pmap{t.count, t.root}
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/xiaq/persistent/hashmap"
+)
+
+// collidingKey hashes the same as the other keys of the same length.
+type collidingKey string
+
+func (k collidingKey) Eval(_ Scope) (Value, error) { return k, nil }
+
+func (k collidingKey) String() string { return string(k) }
+
+func (k collidingKey) Hash() uint32 { return uint32(len(k)) }
+
+func (k collidingKey) Compare(other Value) bool { return other == Value(k) }
+
+func TestPmap_Ops(t *testing.T) {
+	t.Parallel()
+
+	rnd := rand.New(rand.NewSource(1))
+	var m hashmap.Map = emptyMap
+	want := map[Int]Int{}
+
+	for i := 0; i < 5000; i++ {
+		before, beforeWant := m, copyModel(want)
+
+		k := Int(rnd.Intn(500))
+		if rnd.Intn(3) == 0 {
+			m = m.Dissoc(k)
+			delete(want, k)
+		} else {
+			m = m.Assoc(k, Int(i))
+			want[k] = Int(i)
+		}
+
+		checkMap(t, before, beforeWant)
+		checkMap(t, m, want)
+	}
+}
+
+func TestPmap_Collisions(t *testing.T) {
+	t.Parallel()
+
+	var m hashmap.Map = emptyMap
+	keys := []collidingKey{"ab", "cd", "ef", "x"}
+	for i, k := range keys {
+		m = m.Assoc(k, Int(i))
+	}
+
+	m = m.Dissoc(keys[1])
+	if m.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", m.Len())
+	}
+
+	for i, k := range keys {
+		v, found := m.Index(k)
+		if found != (i != 1) || (found && v != Int(i)) {
+			t.Errorf("Index(%v) = %v, %v", k, v, found)
+		}
+	}
+}
+
+func TestTransientMap(t *testing.T) {
+	t.Parallel()
+
+	rnd := rand.New(rand.NewSource(2))
+	var base hashmap.Map = emptyMap
+	baseWant := map[Int]Int{}
+	for i := 0; i < 300; i++ {
+		base = base.Assoc(Int(i), Int(i))
+		baseWant[Int(i)] = Int(i)
+	}
+
+	tm := newTransientMap(base)
+	want := copyModel(baseWant)
+
+	for i := 0; i < 5000; i++ {
+		k := Int(rnd.Intn(600))
+		if rnd.Intn(3) == 0 {
+			tm.dissoc(k)
+			delete(want, k)
+		} else {
+			tm.assoc(k, Int(-i))
+			want[k] = Int(-i)
+		}
+
+		if tm.count != len(want) {
+			t.Fatalf("count = %d, want %d", tm.count, len(want))
+		}
+	}
+
+	checkMap(t, tm.persistent(), want)
+	checkMap(t, base, baseWant)
+}
+
+func copyModel(m map[Int]Int) map[Int]Int {
+	res := make(map[Int]Int, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+func checkMap(t *testing.T, m hashmap.Map, want map[Int]Int) {
+	t.Helper()
+
+	if m.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", m.Len(), len(want))
+	}
+
+	seen := 0
+	for it := m.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		if want[k.(Int)] != v {
+			t.Fatalf("iterated %v: %v, want %v", k, v, want[k.(Int)])
+		}
+		seen++
+	}
+
+	if seen != len(want) {
+		t.Fatalf("iterated %d entries, want %d", seen, len(want))
+	}
+
+	for k, v := range want {
+		if got, found := m.Index(k); !found || got != v {
+			t.Fatalf("Index(%v) = %v, %v, want %v", k, got, found, v)
+		}
+	}
+}
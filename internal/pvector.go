@@ -0,0 +1,439 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/xiaq/persistent/vector"
+)
+
+const (
+	trieBits  = 5
+	trieWidth = 1 << trieBits
+	trieMask  = trieWidth - 1
+)
+
+// owner marks the nodes a transient may change in place. Every transient
+// has its own, nodes of another owner are copied before they are changed.
+type owner struct{ _ int }
+
+// vecNode is a node of the vector trie. The array holds the children of
+// branches and the values of leaves.
+type vecNode struct {
+	edit  *owner
+	array [trieWidth]interface{}
+}
+
+// editable returns the node if it is owned by edit, or a copy owned by
+// edit otherwise. A nil edit always copies.
+func (n *vecNode) editable(edit *owner) *vecNode {
+	if edit != nil && n.edit == edit {
+		return n
+	}
+	return &vecNode{edit: edit, array: n.array}
+}
+
+// pvector is a persistent vector on a trie of 32-way nodes, the last
+// values are kept in a tail out of the trie. It implements vector.Vector
+// and, unlike the implementation it replaces, can be built in place by a
+// transientVector.
+type pvector struct {
+	count int
+	shift uint
+	root  *vecNode
+	tail  []interface{}
+}
+
+var emptyVector = &pvector{shift: trieBits, root: &vecNode{}}
+
+func (v *pvector) Len() int { return v.count }
+
+// tailOffset returns the number of values stored in the trie.
+func tailOffset(count int) int {
+	if count < trieWidth {
+		return 0
+	}
+	return ((count - 1) >> trieBits) << trieBits
+}
+
+// leafFor returns the values of the leaf holding the i-th value.
+func leafFor(root *vecNode, shift uint, i int) []interface{} {
+	n := root
+	for level := shift; level > 0; level -= trieBits {
+		n = n.array[(i>>level)&trieMask].(*vecNode)
+	}
+	return n.array[:]
+}
+
+func (v *pvector) arrayFor(i int) []interface{} {
+	if i >= tailOffset(v.count) {
+		return v.tail
+	}
+	return leafFor(v.root, v.shift, i)
+}
+
+func (v *pvector) Index(i int) (interface{}, bool) {
+	if i < 0 || i >= v.count {
+		return nil, false
+	}
+	return v.arrayFor(i)[i&trieMask], true
+}
+
+func (v *pvector) Assoc(i int, val interface{}) vector.Vector {
+	if i < 0 || i > v.count {
+		return nil
+	} else if i == v.count {
+		return v.Cons(val)
+	}
+
+	if i >= tailOffset(v.count) {
+		tail := append([]interface{}(nil), v.tail...)
+		tail[i&trieMask] = val
+		return &pvector{v.count, v.shift, v.root, tail}
+	}
+	return &pvector{v.count, v.shift, assocTrie(nil, v.shift, v.root, i, val), v.tail}
+}
+
+// assocTrie returns the trie with the i-th value replaced, the nodes owned
+// by edit are changed in place.
+func assocTrie(edit *owner, level uint, n *vecNode, i int, val interface{}) *vecNode {
+	m := n.editable(edit)
+	if level == 0 {
+		m.array[i&trieMask] = val
+	} else {
+		sub := (i >> level) & trieMask
+		m.array[sub] = assocTrie(edit, level-trieBits, m.array[sub].(*vecNode), i, val)
+	}
+	return m
+}
+
+func (v *pvector) Cons(val interface{}) vector.Vector {
+	if v.count-tailOffset(v.count) < trieWidth {
+		tail := make([]interface{}, len(v.tail)+1)
+		copy(tail, v.tail)
+		tail[len(v.tail)] = val
+		return &pvector{v.count + 1, v.shift, v.root, tail}
+	}
+
+	leaf := &vecNode{}
+	copy(leaf.array[:], v.tail)
+	root, shift := pushLeaf(nil, v.count, v.shift, v.root, leaf)
+	return &pvector{v.count + 1, shift, root, []interface{}{val}}
+}
+
+// pushLeaf returns the trie of count values with the full leaf appended
+// and its shift, which grows by a level once the root overflows.
+func pushLeaf(edit *owner, count int, shift uint, root, leaf *vecNode) (*vecNode, uint) {
+	if (count >> trieBits) > (1 << shift) {
+		n := &vecNode{edit: edit}
+		n.array[0] = root
+		n.array[1] = newPath(edit, shift, leaf)
+		return n, shift + trieBits
+	}
+	return pushTail(edit, count, shift, root, leaf), shift
+}
+
+func pushTail(edit *owner, count int, level uint, parent, leaf *vecNode) *vecNode {
+	m := parent.editable(edit)
+	sub := ((count - 1) >> level) & trieMask
+
+	if level == trieBits {
+		m.array[sub] = leaf
+	} else if child, ok := m.array[sub].(*vecNode); ok {
+		m.array[sub] = pushTail(edit, count, level-trieBits, child, leaf)
+	} else {
+		m.array[sub] = newPath(edit, level-trieBits, leaf)
+	}
+	return m
+}
+
+// newPath returns the leaf wrapped in branches up to the level.
+func newPath(edit *owner, level uint, leaf *vecNode) *vecNode {
+	if level == 0 {
+		return leaf
+	}
+	n := &vecNode{edit: edit}
+	n.array[0] = newPath(edit, level-trieBits, leaf)
+	return n
+}
+
+func (v *pvector) Pop() vector.Vector {
+	switch {
+	case v.count == 0:
+		return nil
+
+	case v.count == 1:
+		return emptyVector
+
+	case v.count-tailOffset(v.count) > 1:
+		tail := make([]interface{}, len(v.tail)-1)
+		copy(tail, v.tail)
+		return &pvector{v.count - 1, v.shift, v.root, tail}
+	}
+
+	tail := v.arrayFor(v.count - 2)
+	root, shift := popLeaf(nil, v.count, v.shift, v.root)
+	return &pvector{v.count - 1, shift, root, tail}
+}
+
+// popLeaf returns the trie of count values without its last leaf and its
+// shift, which shrinks by a level once the root has a single child.
+func popLeaf(edit *owner, count int, shift uint, root *vecNode) (*vecNode, uint) {
+	n := popTail(edit, count, shift, root)
+	if n == nil {
+		n = &vecNode{edit: edit}
+	}
+
+	if shift > trieBits && n.array[1] == nil {
+		child := n.array[0].(*vecNode)
+		if edit != nil {
+			child = child.editable(edit)
+		}
+		return child, shift - trieBits
+	}
+	return n, shift
+}
+
+func popTail(edit *owner, count int, level uint, n *vecNode) *vecNode {
+	sub := ((count - 2) >> level) & trieMask
+
+	if level > trieBits {
+		child := popTail(edit, count, level-trieBits, n.array[sub].(*vecNode))
+		if child == nil && sub == 0 {
+			return nil
+		}
+
+		m := n.editable(edit)
+		if child == nil {
+			m.array[sub] = nil
+		} else {
+			m.array[sub] = child
+		}
+		return m
+	} else if sub == 0 {
+		return nil
+	}
+
+	m := n.editable(edit)
+	m.array[sub] = nil
+	return m
+}
+
+func (v *pvector) SubVector(i, j int) vector.Vector {
+	if i < 0 || i > j || j > v.count {
+		return nil
+	}
+	return &subVector{v, i, j}
+}
+
+func (v *pvector) Iterator() vector.Iterator {
+	return newVectorIterator(v, 0, v.count)
+}
+
+func (v *pvector) MarshalJSON() ([]byte, error) {
+	return marshalVector(v.Iterator())
+}
+
+// subVector is a view of the values of a vector from begin up to end.
+type subVector struct {
+	v          *pvector
+	begin, end int
+}
+
+func (s *subVector) Len() int { return s.end - s.begin }
+
+func (s *subVector) Index(i int) (interface{}, bool) {
+	if i < 0 || s.begin+i >= s.end {
+		return nil, false
+	}
+	return s.v.Index(s.begin + i)
+}
+
+func (s *subVector) Assoc(i int, val interface{}) vector.Vector {
+	if i < 0 || s.begin+i > s.end {
+		return nil
+	} else if s.begin+i == s.end {
+		return s.Cons(val)
+	}
+	return s.v.Assoc(s.begin+i, val).SubVector(s.begin, s.end)
+}
+
+func (s *subVector) Cons(val interface{}) vector.Vector {
+	return s.v.Assoc(s.end, val).SubVector(s.begin, s.end+1)
+}
+
+func (s *subVector) Pop() vector.Vector {
+	switch s.Len() {
+	case 0:
+		return nil
+	case 1:
+		return emptyVector
+	}
+	return s.v.SubVector(s.begin, s.end-1)
+}
+
+func (s *subVector) SubVector(i, j int) vector.Vector {
+	return s.v.SubVector(s.begin+i, s.begin+j)
+}
+
+func (s *subVector) Iterator() vector.Iterator {
+	return newVectorIterator(s.v, s.begin, s.end)
+}
+
+func (s *subVector) MarshalJSON() ([]byte, error) {
+	return marshalVector(s.Iterator())
+}
+
+// vectorIterator iterates the values of a vector a leaf at a time.
+type vectorIterator struct {
+	v        *pvector
+	i, end   int
+	leaf     []interface{}
+	leafBase int
+}
+
+func newVectorIterator(v *pvector, begin, end int) *vectorIterator {
+	it := &vectorIterator{v: v, i: begin, end: end}
+	if begin < end {
+		it.leaf, it.leafBase = v.arrayFor(begin), begin&^trieMask
+	}
+	return it
+}
+
+func (it *vectorIterator) Elem() interface{} { return it.leaf[it.i-it.leafBase] }
+
+func (it *vectorIterator) HasElem() bool { return it.i < it.end }
+
+func (it *vectorIterator) Next() {
+	it.i++
+	if it.i < it.end && it.i-it.leafBase == trieWidth {
+		it.leaf, it.leafBase = it.v.arrayFor(it.i), it.i
+	}
+}
+
+func marshalVector(it vector.Iterator) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for first := true; it.HasElem(); it.Next() {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		b, err := json.Marshal(it.Elem())
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// transientVector builds a vector in place. It shares the trie of the
+// vector it was made from and copies a node the first time it changes it.
+// It must not be used after persistent.
+type transientVector struct {
+	edit  *owner
+	count int
+	shift uint
+	root  *vecNode
+	tail  []interface{}
+}
+
+// newTransientVector returns a transient of the vector. Vectors of other
+// implementations are copied.
+func newTransientVector(vec vector.Vector) *transientVector {
+	v, ok := vec.(*pvector)
+	if !ok {
+		t := newTransientVector(emptyVector)
+		for it := vec.Iterator(); it.HasElem(); it.Next() {
+			t.conj(it.Elem())
+		}
+		return t
+	}
+
+	edit := &owner{}
+	tail := make([]interface{}, trieWidth)
+	copy(tail, v.tail)
+	return &transientVector{
+		edit:  edit,
+		count: v.count,
+		shift: v.shift,
+		root:  v.root.editable(edit),
+		tail:  tail,
+	}
+}
+
+func (t *transientVector) index(i int) (interface{}, bool) {
+	if i < 0 || i >= t.count {
+		return nil, false
+	} else if i >= tailOffset(t.count) {
+		return t.tail[i&trieMask], true
+	}
+	return leafFor(t.root, t.shift, i)[i&trieMask], true
+}
+
+func (t *transientVector) conj(val interface{}) {
+	if t.count-tailOffset(t.count) < trieWidth {
+		t.tail[t.count&trieMask] = val
+		t.count++
+		return
+	}
+
+	leaf := &vecNode{edit: t.edit}
+	copy(leaf.array[:], t.tail)
+	t.root, t.shift = pushLeaf(t.edit, t.count, t.shift, t.root, leaf)
+
+	t.tail = make([]interface{}, trieWidth)
+	t.tail[0] = val
+	t.count++
+}
+
+// assoc replaces the i-th value, or appends it if i is the length. Returns
+// false if i is out of bounds.
+func (t *transientVector) assoc(i int, val interface{}) bool {
+	switch {
+	case i < 0 || i > t.count:
+		return false
+
+	case i == t.count:
+		t.conj(val)
+
+	case i >= tailOffset(t.count):
+		t.tail[i&trieMask] = val
+
+	default:
+		t.root = assocTrie(t.edit, t.shift, t.root, i, val)
+	}
+	return true
+}
+
+// pop removes the last value. Returns false if the vector is empty.
+func (t *transientVector) pop() bool {
+	switch {
+	case t.count == 0:
+		return false
+
+	case t.count == 1 || (t.count-1)&trieMask > 0:
+		t.count--
+		t.tail[t.count&trieMask] = nil
+		return true
+	}
+
+	leaf := leafFor(t.root, t.shift, t.count-2)
+	t.tail = make([]interface{}, trieWidth)
+	copy(t.tail, leaf)
+	t.root, t.shift = popLeaf(t.edit, t.count, t.shift, t.root)
+	t.count--
+	return true
+}
+
+// persistent returns the vector built. The transient must not be used
+// afterwards.
+func (t *transientVector) persistent() *pvector {
+	t.edit = nil
+	tail := make([]interface{}, t.count-tailOffset(t.count))
+	copy(tail, t.tail)
+	return &pvector{t.count, t.shift, t.root, tail}
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/xiaq/persistent/vector"
+)
+
+func TestPvector_Ops(t *testing.T) {
+	t.Parallel()
+
+	rnd := rand.New(rand.NewSource(1))
+	var vec vector.Vector = emptyVector
+	var want []interface{}
+
+	for i := 0; i < 5000; i++ {
+		before, beforeWant := vec, append([]interface{}(nil), want...)
+
+		switch op := rnd.Intn(10); {
+		case op < 6:
+			vec = vec.Cons(i)
+			want = append(want, i)
+
+		case op < 8 && len(want) > 0:
+			j := rnd.Intn(len(want))
+			vec = vec.Assoc(j, -i)
+			want[j] = -i
+
+		case len(want) > 0:
+			vec = vec.Pop()
+			want = want[:len(want)-1]
+		}
+
+		checkVector(t, before, beforeWant)
+		checkVector(t, vec, want)
+	}
+}
+
+func TestTransientVector(t *testing.T) {
+	t.Parallel()
+
+	rnd := rand.New(rand.NewSource(2))
+	var base vector.Vector = emptyVector
+	for i := 0; i < 1000; i++ {
+		base = base.Cons(i)
+	}
+
+	baseWant := make([]interface{}, 1000)
+	for i := range baseWant {
+		baseWant[i] = i
+	}
+
+	tv := newTransientVector(base)
+	want := append([]interface{}(nil), baseWant...)
+
+	for i := 0; i < 5000; i++ {
+		switch op := rnd.Intn(10); {
+		case op < 6:
+			tv.conj(i)
+			want = append(want, i)
+
+		case op < 8 && len(want) > 0:
+			j := rnd.Intn(len(want))
+			tv.assoc(j, -i)
+			want[j] = -i
+
+		case len(want) > 0:
+			tv.pop()
+			want = want[:len(want)-1]
+		}
+
+		if v, _ := tv.index(len(want) - 1); len(want) > 0 && v != want[len(want)-1] {
+			t.Fatalf("index(%d) = %v, want %v", len(want)-1, v, want[len(want)-1])
+		}
+	}
+
+	checkVector(t, tv.persistent(), want)
+	checkVector(t, base, baseWant)
+}
+
+func TestTransientVector_Sub(t *testing.T) {
+	t.Parallel()
+
+	var vec vector.Vector = emptyVector
+	for i := 0; i < 100; i++ {
+		vec = vec.Cons(i)
+	}
+
+	tv := newTransientVector(vec.SubVector(10, 60))
+	tv.conj(100)
+
+	want := make([]interface{}, 0, 51)
+	for i := 10; i < 60; i++ {
+		want = append(want, i)
+	}
+	checkVector(t, tv.persistent(), append(want, 100))
+}
+
+func TestTransientVector_Deep(t *testing.T) {
+	t.Parallel()
+
+	const n = 40000
+	tv := newTransientVector(emptyVector)
+	for i := 0; i < n; i++ {
+		tv.conj(i)
+	}
+
+	vec := tv.persistent()
+	if vec.shift != 3*trieBits {
+		t.Fatalf("shift = %d, want %d", vec.shift, 3*trieBits)
+	}
+
+	tv = newTransientVector(vec)
+	for i := n - 1; i >= 0; i-- {
+		if v, _ := tv.index(i); v != i {
+			t.Fatalf("index(%d) = %v", i, v)
+		}
+		tv.pop()
+	}
+
+	if popped := tv.persistent(); popped.Len() != 0 || popped.shift != trieBits {
+		t.Fatalf("Len() = %d and shift = %d after popping all", popped.Len(), popped.shift)
+	}
+
+	for i := 0; i < n; i += 997 {
+		if v, _ := vec.Index(i); v != i {
+			t.Fatalf("Index(%d) = %v after popping a transient of it", i, v)
+		}
+	}
+}
+
+func checkVector(t *testing.T, vec vector.Vector, want []interface{}) {
+	t.Helper()
+
+	if vec.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", vec.Len(), len(want))
+	}
+
+	i := 0
+	for it := vec.Iterator(); it.HasElem(); it.Next() {
+		if v, _ := vec.Index(i); v != want[i] || it.Elem() != want[i] {
+			t.Fatalf("value %d = %v, iterated %v, want %v", i, v, it.Elem(), want[i])
+		}
+		i++
+	}
+
+	if i != len(want) {
+		t.Fatalf("iterated %d values, want %d", i, len(want))
+	}
+}
@@ -8,15 +8,12 @@ import (
 	"io"
 	"math"
 	"net"
-	"os"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
-
-	"github.com/xiaq/persistent/hashmap"
 )
 
 const dispatchTrigger = '#'
@@ -38,7 +35,7 @@ var (
 		'\\': '\\',
 		't':  '\t',
 		'a':  '\a',
-		'f':  '\a',
+		'f':  '\f',
 		'r':  '\r',
 		'b':  '\b',
 		'v':  '\v',
@@ -70,6 +67,7 @@ func NewReader(rs io.Reader) *Reader {
 		rs:       bufio.NewReader(rs),
 		macros:   defaultReadTable(),
 		dispatch: defaultDispatchTable(),
+		tagged:   readBuiltinTag,
 	}
 }
 
@@ -82,13 +80,29 @@ type ReaderMacro func(rd *Reader, init rune) (Value, error)
 type Reader struct {
 	File string
 
-	rs          io.RuneReader
-	buf         []rune
-	line, col   int
-	lastCol     int
-	macros      map[rune]ReaderMacro
-	dispatch    map[rune]ReaderMacro
-	dispatching bool
+	rs        io.RuneReader
+	buf       []rune
+	line, col int
+	lastCol   int
+	macros    map[rune]ReaderMacro
+	dispatch  map[rune]ReaderMacro
+
+	// tagged returns the value of a tagged literal, such as #point [1 2].
+	// Tagged literals are not read if it is nil.
+	tagged func(tag Symbol, form Value) (Value, error)
+}
+
+// SetScope makes the reader read tagged literals with the data readers
+// registered on the instance of the scope and the classes bound in it.
+// Otherwise only the #inst and #uuid literals are read.
+func (rd *Reader) SetScope(scope Scope) {
+	rd.tagged = func(tag Symbol, form Value) (Value, error) {
+		v, found, err := readTag(scope, nil, tag, form)
+		if err != nil || found {
+			return v, err
+		}
+		return nil, fmt.Errorf("no reader function for tag '%s'", tag)
+	}
 }
 
 // All consumes characters from stream until EOF and returns a list of all the
@@ -128,6 +142,10 @@ func (rd *Reader) One() (Value, error) {
 			return nil, rd.annotateErr(err)
 		}
 
+		if _, ok := form.(splice); ok {
+			err := errors.New("reader conditional splicing not allowed at the top level")
+			return nil, rd.annotateErr(err)
+		}
 		return form, nil
 	}
 }
@@ -140,13 +158,6 @@ func (rd *Reader) IsTerminal(r rune) bool {
 		return true
 	}
 
-	if rd.dispatching {
-		_, found := rd.dispatch[r]
-		if found {
-			return true
-		}
-	}
-
 	_, found := rd.macros[r]
 	return found
 }
@@ -312,16 +323,15 @@ func (rd *Reader) execDispatch() (Value, error) {
 	}
 
 	dispatchMacro, found := rd.dispatch[r2]
+	if !found && rd.tagged != nil && unicode.IsLetter(r2) {
+		dispatchMacro, found = readTagged, true
+	}
+
 	if !found {
 		rd.Unread(r2)
 		return nil, nil
 	}
 
-	rd.dispatching = true
-	defer func() {
-		rd.dispatching = false
-	}()
-
 	form, err := dispatchMacro(rd, r2)
 	if err != nil {
 		return nil, err
@@ -365,13 +375,14 @@ func readString(rd *Reader, _ rune) (Value, error) {
 				return nil, err
 			}
 
-			// TODO: Support for Unicode escape \uNN format.
-
-			escaped, err := getEscape(r2)
+			if r2 == 'u' {
+				r, err = readUnicodeEscape(rd)
+			} else {
+				r, err = getEscape(r2)
+			}
 			if err != nil {
 				return nil, err
 			}
-			r = escaped
 
 		} else if r == '"' {
 			break
@@ -383,6 +394,27 @@ func readString(rd *Reader, _ rune) (Value, error) {
 	return String(b.String()), nil
 }
 
+// readUnicodeEscape reads the four hex digits of a \uXXXX escape.
+func readUnicodeEscape(rd *Reader) (rune, error) {
+	var digits [4]rune
+	for i := range digits {
+		r, err := rd.NextRune()
+		if err != nil {
+			if err == io.EOF {
+				return -1, fmt.Errorf("%w: while reading string", ErrEOF)
+			}
+			return -1, err
+		}
+		digits[i] = r
+	}
+
+	char, err := readUnicodeChar(string(digits[:]), 16)
+	if err != nil {
+		return -1, fmt.Errorf("illegal escape sequence '\\u%s'", string(digits[:]))
+	}
+	return rune(char), nil
+}
+
 func readNumber(rd *Reader, init rune) (Value, error) {
 	numStr, err := readToken(rd, init)
 	if err != nil {
@@ -391,8 +423,9 @@ func readNumber(rd *Reader, init rune) (Value, error) {
 
 	decimalPoint := strings.ContainsRune(numStr, '.')
 	isRadix := strings.ContainsRune(numStr, 'r')
-	isScientific := strings.ContainsRune(numStr, 'e')
-	isNotDecimal := hasBase(numStr) && !decimalPoint
+	isScientific := strings.ContainsRune(numStr, 'e') &&
+		!strings.HasSuffix(numStr, "M")
+	isNotDecimal := hasBase(numStr) && !strings.ContainsAny(numStr, ".MN/")
 
 	switch {
 	case isRadix && (decimalPoint || isScientific):
@@ -405,19 +438,10 @@ func readNumber(rd *Reader, init rune) (Value, error) {
 		return parseRadix(numStr)
 
 	case isNotDecimal:
-		v, err := strconv.ParseInt(numStr, 0, 64)
-		if err != nil {
-			return nil, err
-		}
-		return Number(v), nil
+		return parseInt(numStr, 0, fmt.Errorf("illegal number format '%s'", numStr))
 
 	default:
-		v, err := strconv.ParseFloat(numStr, 64)
-		if err != nil {
-			return nil, fmt.Errorf("illegal number format '%s'", numStr)
-		}
-
-		return Number(v), nil
+		return parseNumber(numStr)
 	}
 }
 
@@ -582,7 +606,7 @@ func readHashMap(rd *Reader, _ rune) (Value, error) {
 
 	hm := &HashMap{
 		Position: pi,
-		Data:     hashmap.New(compare, hasher),
+		Data:     emptyMap,
 	}
 
 	for i := 0; i < len(forms); i += 2 {
@@ -640,17 +664,147 @@ func readSet(rd *Reader, _ rune) (Value, error) {
 		return nil, err
 	}
 
-	set := Set{
-		Values:   forms,
-		Position: pi,
-	}
-	if !set.valid() {
+	set := NewSet().SetPosition(pi).Conj(forms...).(*Set)
+	if set.Size() != len(forms) {
 		return nil, errors.New("duplicate value in set")
 	}
 
 	return set, nil
 }
 
+// readTagged reads a tagged literal, a tag symbol followed by a form, and
+// returns the value of the literal.
+func readTagged(rd *Reader, init rune) (Value, error) {
+	tag, err := readSymbol(rd, init)
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := rd.One()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: while reading tagged literal #%s", ErrEOF, tag)
+		}
+		return nil, err
+	}
+
+	return rd.tagged(tag.(Symbol), form)
+}
+
+// readDiscard reads the next form and discards it.
+func readDiscard(rd *Reader, _ rune) (Value, error) {
+	if _, err := rd.One(); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: while reading discarded form", ErrEOF)
+		}
+		return nil, err
+	}
+	return nil, ErrSkip
+}
+
+// readConditional reads a reader conditional, #?(:spirit form :default
+// form), to the form of the first feature supported. It is discarded if
+// there is none. Tagged literals of the other features are not read by
+// their readers, as they may not be known. The forms of a splicing
+// conditional, #?@(:spirit [a b]), are spliced into the enclosing form.
+func readConditional(rd *Reader, _ rune) (Value, error) {
+	r, err := rd.NextRune()
+	splicing := err == nil && r == '@'
+	if splicing {
+		r, err = rd.NextRune()
+	}
+	if err != nil || r != '(' {
+		return nil, errors.New("reader conditional must be a list")
+	}
+
+	var form Value
+	found := false
+	for {
+		feature, end, err := readConditionalForm(rd)
+		if err != nil {
+			return nil, err
+		} else if end {
+			break
+		}
+
+		kw, ok := feature.(Keyword)
+		if !ok {
+			return nil, fmt.Errorf("feature must be a keyword, got %s", feature)
+		}
+
+		selected := !found && (kw == "spirit" || kw == "default")
+		tagged := rd.tagged
+		if !selected {
+			rd.tagged = keepTagged
+		}
+
+		v, end, err := readConditionalForm(rd)
+		rd.tagged = tagged
+		if err != nil {
+			return nil, err
+		} else if end {
+			return nil, errors.New("expecting even number of forms within reader conditional")
+		}
+
+		if selected {
+			form, found = v, true
+		}
+	}
+
+	if !found {
+		return nil, ErrSkip
+	} else if !splicing {
+		return form, nil
+	}
+
+	seq, ok := form.(Seq)
+	if !ok {
+		return nil, fmt.Errorf("spliced form must be a list or vector, got %s", form)
+	}
+	return splice(realize(seq).Values), nil
+}
+
+// splice is the forms of a splicing reader conditional, which are read into
+// the enclosing container.
+type splice []Value
+
+func (s splice) Eval(_ Scope) (Value, error) {
+	return nil, errors.New("reader conditional splicing not allowed at the top level")
+}
+
+func (s splice) String() string { return containerString(s, "#?@(", ")", " ") }
+
+// readConditionalForm reads the next form of a reader conditional, end is
+// true once the conditional ends instead.
+func readConditionalForm(rd *Reader) (form Value, end bool, err error) {
+	for {
+		if err := rd.SkipSpaces(); err != nil {
+			if err == io.EOF {
+				return nil, false, fmt.Errorf("%w: while reading reader conditional", ErrEOF)
+			}
+			return nil, false, err
+		}
+
+		r, err := rd.NextRune()
+		if err != nil {
+			return nil, false, err
+		} else if r == ')' {
+			return nil, true, nil
+		}
+		rd.Unread(r)
+
+		form, err := rd.readOne()
+		if err == ErrSkip {
+			continue
+		}
+		return form, false, err
+	}
+}
+
+func keepTagged(tag Symbol, form Value) (Value, error) {
+	return TaggedLiteral{Tag: tag, Form: form}, nil
+}
+
 func readUnicodeChar(token string, base int) (Character, error) {
 	num, err := strconv.ParseInt(token, base, 64)
 	if err != nil {
@@ -721,15 +875,15 @@ func quoteFormReader(expandFunc string) ReaderMacro {
 	}
 }
 
-func parseRadix(numStr string) (Number, error) {
+func parseRadix(numStr string) (Value, error) {
 	parts := strings.Split(numStr, "r")
 	if len(parts) != 2 {
-		return 0, fmt.Errorf("illegal radix notation '%s'", numStr)
+		return nil, fmt.Errorf("illegal radix notation '%s'", numStr)
 	}
 
 	base, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
-		return 0, fmt.Errorf("illegal radix notation '%s'", numStr)
+		return nil, fmt.Errorf("illegal radix notation '%s'", numStr)
 	}
 
 	repr := parts[1]
@@ -738,12 +892,10 @@ func parseRadix(numStr string) (Number, error) {
 		repr = "-" + repr
 	}
 
-	v, err := strconv.ParseInt(repr, int(base), 64)
-	if err != nil {
-		return 0, fmt.Errorf("illegal radix notation '%s'", numStr)
+	if base < 2 || base > 36 {
+		return nil, fmt.Errorf("illegal radix notation '%s'", numStr)
 	}
-
-	return Number(v), nil
+	return parseInt(repr, int(base), fmt.Errorf("illegal radix notation '%s'", numStr))
 }
 
 func parseScientific(numStr string) (Number, error) {
@@ -835,6 +987,11 @@ func readContainer(rd *Reader, _ rune, end rune, formType string) ([]Value, erro
 			}
 			return nil, err
 		}
+
+		if spliced, ok := expr.(splice); ok {
+			forms = append(forms, spliced...)
+			continue
+		}
 		forms = append(forms, expr)
 	}
 
@@ -868,12 +1025,17 @@ func defaultDispatchTable() map[rune]ReaderMacro {
 		')': unmatchedDelimiter,
 		'[': readLazySeq,
 		']': unmatchedDelimiter,
+		'_': readDiscard,
+		'?': readConditional,
+		'"': readRegex,
 	}
 }
 
 func isHashable(v Value) bool {
 	switch v.(type) {
-	case String, Number, Nil, Character, Keyword:
+	// symbols are the locals of destructuring patterns, or evaluated to
+	// the key; collections are hashed by their values
+	case Nil, Hashable:
 		return true
 
 	default:
@@ -887,7 +1049,7 @@ func isSpace(r rune) bool {
 
 func inferFileName(rs io.Reader) string {
 	switch r := rs.(type) {
-	case *os.File:
+	case interface{ Name() string }:
 		return r.Name()
 
 	case *strings.Reader:
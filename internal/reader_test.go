@@ -3,10 +3,12 @@ package internal_test
 import (
 	"bytes"
 	"io"
+	"math/big"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/issadarkthing/spirit/internal"
 	"github.com/kr/pretty"
@@ -132,21 +134,19 @@ func TestReader_All(t *testing.T) {
 						},
 					},
 				},
-				internal.Set{
-					Position: internal.Position{
-						File:   "<string>",
-						Line:   1,
-						Column: 9,
-					},
-				},
-				internal.Number(123),
+				internal.NewSet().SetPosition(internal.Position{
+					File:   "<string>",
+					Line:   1,
+					Column: 8,
+				}),
+				internal.Int(123),
 				internal.String("Hello\tWorld"),
 				internal.Number(12.34),
-				internal.Number(-15),
-				internal.Number(8),
+				internal.Int(-15),
+				internal.Int(8),
 				internal.Bool(true),
 				internal.Nil{},
-				internal.Number(10),
+				internal.Int(10),
 				internal.Character('a'),
 				internal.Keyword("hello"),
 			},
@@ -245,7 +245,7 @@ func TestReader_One(t *testing.T) {
 									Column: 3,
 								},
 							},
-							internal.Number(3),
+							internal.Int(3),
 						},
 						Position: internal.Position{
 							File:   "<string>",
@@ -272,7 +272,7 @@ func TestReader_One(t *testing.T) {
 									Column: 3,
 								},
 							},
-							internal.Number(3),
+							internal.Int(3),
 							&internal.List{
 								Values: []internal.Value{
 									internal.Symbol{
@@ -288,9 +288,9 @@ func TestReader_One(t *testing.T) {
 													Column: 10,
 												},
 											},
-											internal.Number(1),
-											internal.Number(2),
-											internal.Number(3),
+											internal.Int(1),
+											internal.Int(2),
+											internal.Int(3),
 										},
 										Position: internal.Position{
 											File:   "<string>",
@@ -332,22 +332,160 @@ func TestReader_One(t *testing.T) {
 	})
 }
 
+func TestReader_One_Dispatch(t *testing.T) {
+	executeReaderTests(t, []readerTestCase{
+		{
+			name: "Discard",
+			src:  "#_ (x 1) #_ #_ 2 3 :a",
+			want: internal.Keyword("a"),
+		},
+		{
+			name:    "DiscardEOF",
+			src:     "#_",
+			wantErr: true,
+		},
+		{
+			name: "Conditional",
+			src:  "#?(:clj 1 :spirit 2 :default 3)",
+			want: internal.Int(2),
+		},
+		{
+			name: "ConditionalDefault",
+			src:  "#?(:cljs #js [1] :default :d)",
+			want: internal.Keyword("d"),
+		},
+		{
+			name: "ConditionalNoFeature",
+			src:  "#?(:clj 1) :a",
+			want: internal.Keyword("a"),
+		},
+		{
+			name:    "ConditionalOddForms",
+			src:     "#?(:clj 1 :spirit)",
+			wantErr: true,
+		},
+		{
+			name: "ConditionalSplice",
+			src:  "[0 #?@(:clj [1] :spirit [2 3]) #?@(:cljs [4])]",
+			want: internal.NewVector().
+				SetPosition(internal.Position{File: "<string>", Line: 1, Column: 1}).
+				Cons(internal.Int(3)).
+				Cons(internal.Int(2)).
+				Cons(internal.Int(0)),
+		},
+		{
+			name:    "ConditionalSpliceTopLevel",
+			src:     "#?@(:spirit [1 2])",
+			wantErr: true,
+		},
+		{
+			name:    "ConditionalSpliceNotSeq",
+			src:     "[#?@(:spirit 1)]",
+			wantErr: true,
+		},
+		{
+			name: "Inst",
+			src:  `#inst "2020-01-02T03:04:05.5Z"`,
+			want: internal.Inst{Time: time.Date(2020, 1, 2, 3, 4, 5, 5e8, time.UTC)},
+		},
+		{
+			name: "InstDate",
+			src:  `#inst "2020-01-02"`,
+			want: internal.Inst{Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "InvalidInst",
+			src:     `#inst "yesterday"`,
+			wantErr: true,
+		},
+		{
+			name: "UUID",
+			src:  `#uuid "F81D4FAE-7DEC-11D0-A765-00A0C91E6BF6"`,
+			want: internal.UUID{
+				0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0,
+				0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6,
+			},
+		},
+		{
+			name:    "InvalidUUID",
+			src:     `#uuid "f81d4fae7dec11d0a76500a0c91e6bf6"`,
+			wantErr: true,
+		},
+		{
+			name:    "UnknownTag",
+			src:     `#point [1 2]`,
+			wantErr: true,
+		},
+		{
+			name: "Regex",
+			src:  `#"\d+\.\s"`,
+			want: mustRegex(`\d+\.\s`),
+		},
+		{
+			name: "RegexQuote",
+			src:  `#"say \"(\w+)\""`,
+			want: mustRegex(`say \"(\w+)\"`),
+		},
+		{
+			name:    "InvalidRegex",
+			src:     `#"(a"`,
+			wantErr: true,
+		},
+		{
+			name:    "RegexEOF",
+			src:     `#"abc`,
+			wantErr: true,
+		},
+	})
+}
+
+func mustRegex(pattern string) internal.Regex {
+	re, err := internal.NewRegex(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+func TestReader_SetScope(t *testing.T) {
+	t.Parallel()
+
+	scope := internal.NewSpirit()
+	scope.RegisterTag("point", func(form internal.Value) (internal.Value, error) {
+		return internal.NewHashMap().Set(internal.Keyword("xy"), form), nil
+	})
+
+	rd := internal.NewReader(strings.NewReader(`#point [1 2] #other 1`))
+	rd.SetScope(scope)
+
+	got, err := rd.One()
+	if err != nil {
+		t.Fatalf("One() unexpected error: %v", err)
+	} else if want := `{:xy [1 2]}`; got.String() != want {
+		t.Errorf("One() = %v, want %v", got, want)
+	}
+
+	if _, err := rd.One(); err == nil {
+		t.Errorf("One() of a tag without a reader returned no error")
+	}
+}
+
 func TestReader_One_Number(t *testing.T) {
 	executeReaderTests(t, []readerTestCase{
 		{
 			name: "NumberWithLeadingSpaces",
 			src:  "    +1234",
-			want: internal.Number(1234),
+			want: internal.Int(1234),
 		},
 		{
 			name: "PositiveInt",
 			src:  "+1245",
-			want: internal.Number(1245),
+			want: internal.Int(1245),
 		},
 		{
 			name: "NegativeInt",
 			src:  "-234",
-			want: internal.Number(-234),
+			want: internal.Int(-234),
 		},
 		{
 			name: "PositiveFloat",
@@ -362,52 +500,52 @@ func TestReader_One_Number(t *testing.T) {
 		{
 			name: "PositiveHex",
 			src:  "0x124",
-			want: internal.Number(0x124),
+			want: internal.Int(0x124),
 		},
 		{
 			name: "NegativeHex",
 			src:  "-0x124",
-			want: internal.Number(-0x124),
+			want: internal.Int(-0x124),
 		},
 		{
 			name: "PositiveOctal",
 			src:  "0123",
-			want: internal.Number(0123),
+			want: internal.Int(0123),
 		},
 		{
 			name: "NegativeOctal",
 			src:  "-0123",
-			want: internal.Number(-0123),
+			want: internal.Int(-0123),
 		},
 		{
 			name: "PositiveBinary",
 			src:  "0b10",
-			want: internal.Number(2),
+			want: internal.Int(2),
 		},
 		{
 			name: "NegativeBinary",
 			src:  "-0b10",
-			want: internal.Number(-2),
+			want: internal.Int(-2),
 		},
 		{
 			name: "PositiveBase2Radix",
 			src:  "2r10",
-			want: internal.Number(2),
+			want: internal.Int(2),
 		},
 		{
 			name: "NegativeBase2Radix",
 			src:  "-2r10",
-			want: internal.Number(-2),
+			want: internal.Int(-2),
 		},
 		{
 			name: "PositiveBase4Radix",
 			src:  "4r123",
-			want: internal.Number(27),
+			want: internal.Int(27),
 		},
 		{
 			name: "NegativeBase4Radix",
 			src:  "-4r123",
-			want: internal.Number(-27),
+			want: internal.Int(-27),
 		},
 		{
 			name: "ScientificSimple",
@@ -424,6 +562,41 @@ func TestReader_One_Number(t *testing.T) {
 			src:  "1.5e10",
 			want: internal.Number(1.5e+10),
 		},
+		{
+			name: "BigInt",
+			src:  "9223372036854775808",
+			want: internal.BigInt{Int: new(big.Int).Lsh(big.NewInt(1), 63)},
+		},
+		{
+			name: "BigIntSuffix",
+			src:  "10N",
+			want: internal.Int(10),
+		},
+		{
+			name: "Ratio",
+			src:  "-2/4",
+			want: internal.Ratio{Rat: big.NewRat(-1, 2)},
+		},
+		{
+			name: "WholeRatio",
+			src:  "4/2",
+			want: internal.Int(2),
+		},
+		{
+			name: "BigDecimal",
+			src:  "12.50M",
+			want: internal.BigDecimal{Unscaled: big.NewInt(1250), Scale: 2},
+		},
+		{
+			name: "ScientificBigDecimal",
+			src:  "1.5e2M",
+			want: internal.BigDecimal{Unscaled: big.NewInt(150), Scale: 0},
+		},
+		{
+			name:    "RatioZeroDenominator",
+			src:     "1/0",
+			wantErr: true,
+		},
 		{
 			name:    "FloatStartingWith0",
 			src:     "012.3",
@@ -505,6 +678,16 @@ func TestReader_One_String(t *testing.T) {
 			src:  `"hello\\world"`,
 			want: internal.String(`hello\world`),
 		},
+		{
+			name: "EscapeUnicode",
+			src:  `"tab\u0009é\f"`,
+			want: internal.String("tab\té\f"),
+		},
+		{
+			name:    "InvalidUnicodeEscape",
+			src:     `"\u00g1"`,
+			wantErr: true,
+		},
 		{
 			name:    "UnexpectedEOF",
 			src:     `"double quote is`,
@@ -697,7 +880,7 @@ func TestReader_One_List(t *testing.T) {
 							Column: 2,
 						},
 					},
-					internal.Number(15),
+					internal.Int(15),
 					internal.Number(3.1413),
 				},
 				Position: internal.Position{
@@ -720,7 +903,7 @@ func TestReader_One_List(t *testing.T) {
 							Column: 2,
 						},
 					},
-					internal.Number(15),
+					internal.Int(15),
 					internal.Number(3.1413),
 				},
 				Position: internal.Position{
@@ -746,7 +929,7 @@ func TestReader_One_List(t *testing.T) {
 							Column: 2,
 						},
 					},
-					internal.Number(15),
+					internal.Int(15),
 					internal.Number(3.1413),
 				},
 				Position: internal.Position{
@@ -772,7 +955,7 @@ func TestReader_One_List(t *testing.T) {
 							Column: 2,
 						},
 					},
-					internal.Number(15),
+					internal.Int(15),
 					internal.Number(3.1413),
 				},
 				Position: internal.Position{
@@ -830,7 +1013,7 @@ func TestReader_One_Vector(t *testing.T) {
 					Column: 1,
 				}).
 				Cons(internal.Number(3.1413)).
-				Cons(internal.Number(15)).
+				Cons(internal.Int(15)).
 				Cons(internal.Symbol{
 					Value: "+",
 					Position: internal.Position{
@@ -849,7 +1032,7 @@ func TestReader_One_Vector(t *testing.T) {
 					Column: 1,
 				}).
 				Cons(internal.Number(3.1413)).
-				Cons(internal.Number(15)).
+				Cons(internal.Int(15)).
 				Cons(internal.Symbol{
 					Value: "+",
 					Position: internal.Position{
@@ -871,7 +1054,7 @@ func TestReader_One_Vector(t *testing.T) {
 					Column: 1,
 				}).
 				Cons(internal.Number(3.1413)).
-				Cons(internal.Number(15)).
+				Cons(internal.Int(15)).
 				Cons(internal.Symbol{
 					Value: "+",
 					Position: internal.Position{
@@ -894,7 +1077,7 @@ func TestReader_One_Vector(t *testing.T) {
 					Column: 1,
 				}).
 				Cons(internal.Number(3.1413)).
-				Cons(internal.Number(15)).
+				Cons(internal.Int(15)).
 				Cons(internal.Symbol{
 					Value: "+",
 					Position: internal.Position{
@@ -913,55 +1096,46 @@ func TestReader_One_Vector(t *testing.T) {
 }
 
 func TestReader_One_Set(t *testing.T) {
+	pos := internal.Position{File: "<string>", Line: 1, Column: 1}
+
 	executeReaderTests(t, []readerTestCase{
 		{
 			name: "Empty",
 			src:  "#{}",
-			want: internal.Set{
-				Values: nil,
-				Position: internal.Position{
-					File:   "<string>",
-					Line:   1,
-					Column: 2,
-				},
-			},
-		},
-		{
-			name: "Valid",
-			src:  "#{1 2 []}",
-			want: internal.Set{
-				Values: []internal.Value{internal.Number(1),
-					internal.Number(2),
-					internal.NewVector().
-						SetPosition(internal.Position{
-							File:   "<string>",
-							Column: 7,
-							Line:   1,
-						}),
-				},
-				Position: internal.Position{
-					File:   "<string>",
-					Line:   1,
-					Column: 2,
-				},
-			},
+			want: internal.NewSet().SetPosition(pos),
 		},
 		{
 			name:    "HasDuplicate",
 			src:     "#{1 2 2}",
 			wantErr: true,
 		},
+		{
+			name:    "HasEqualNumbers",
+			src:     "#{1 1.0}",
+			wantErr: true,
+		},
 	})
+
+	// sets of values hold the functions of their hash map, which are never
+	// deeply equal, so they are compared by members and position only.
+	got, err := internal.NewReader(strings.NewReader("#{1 2 []}")).One()
+	if err != nil {
+		t.Fatalf("One() unexpected error: %v", err)
+	}
+
+	want := internal.NewSet().SetPosition(pos).Conj(
+		internal.Int(1),
+		internal.Int(2),
+		internal.NewVector(),
+	).(*internal.Set)
+	if !want.Compare(got) || got.(*internal.Set).Position != pos {
+		t.Errorf("One() got -> %s\nwant -> %s", pretty.Sprint(got), pretty.Sprint(want))
+	}
 }
 
 func TestReader_One_HashMap(t *testing.T) {
 
 	executeReaderTests(t, []readerTestCase{
-		{
-			name:    "NonHashableKey",
-			src:     `{[] 10}`,
-			wantErr: true,
-		},
 		{
 			name:    "OddNumberOfForms",
 			src:     "{:hello 10 :age}",
@@ -970,6 +1144,40 @@ func TestReader_One_HashMap(t *testing.T) {
 	})
 }
 
+func TestReader_One_HashMapCompositeKeys(t *testing.T) {
+	t.Parallel()
+
+	got, err := internal.NewReader(strings.NewReader(
+		`{[1 2] :vector #{1} :set {:a [1]} :map (f x) :list [] :empty}`,
+	)).One()
+	if err != nil {
+		t.Fatalf("One() unexpected error: %v", err)
+	}
+
+	hm := got.(*internal.HashMap)
+	want := map[string]string{
+		`[1 2]`:    `:vector`,
+		`#{1}`:     `:set`,
+		`{:a [1]}`: `:map`,
+		`(f x)`:    `:list`,
+		`[]`:       `:empty`,
+	}
+	if hm.Size() != len(want) {
+		t.Errorf("Size() = %d, want %d", hm.Size(), len(want))
+	}
+
+	for key, v := range want {
+		k, err := internal.NewReader(strings.NewReader(key)).One()
+		if err != nil {
+			t.Fatalf("One() unexpected error: %v", err)
+		}
+
+		if got := hm.Get(k); got == nil || got.String() != v {
+			t.Errorf("Get(%s) = %v, want %s", key, got, v)
+		}
+	}
+}
+
 type readerTestCase struct {
 	name    string
 	src     string
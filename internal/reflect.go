@@ -2,6 +2,7 @@ package internal
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
 	"strings"
 )
@@ -30,6 +31,14 @@ func ValueOf(v interface{}) Value {
 		return Type{T: rt}
 	}
 
+	switch n := v.(type) {
+	case *big.Int:
+		return bigValue(new(big.Int).Set(n))
+
+	case *big.Rat:
+		return ratValue(new(big.Rat).Set(n))
+	}
+
 	rv := reflect.ValueOf(v)
 
 	switch rv.Kind() {
@@ -37,7 +46,10 @@ func ValueOf(v interface{}) Value {
 		return reflectFn(rv)
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return Number(rv.Int())
+		return Int(rv.Int())
+
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return bigValue(new(big.Int).SetUint64(rv.Uint()))
 
 	case reflect.Float32, reflect.Float64:
 		return Number(rv.Float())
@@ -60,6 +72,75 @@ func ValueOf(v interface{}) Value {
 	}
 }
 
+// ToGo converts a spirit Value to its natural Go representation. It is the
+// inverse of ValueOf for primitive types and collections. Nil becomes nil,
+// Number becomes float64, Int becomes int64, BigInt and Ratio become *big.Int
+// and *big.Rat, BigDecimal becomes its decimal string, String, Keyword and
+// Symbol become string, Seq types become []interface{} and HashMap becomes
+// map[interface{}]interface{}.
+// Values wrapped in Any are unwrapped and all other values are returned as is.
+func ToGo(v Value) interface{} {
+	switch val := v.(type) {
+	case nil, Nil:
+		return nil
+
+	case Bool:
+		return bool(val)
+
+	case Number:
+		return float64(val)
+
+	case Int:
+		return int64(val)
+
+	case BigInt:
+		return new(big.Int).Set(val.Int)
+
+	case Ratio:
+		return new(big.Rat).Set(val.Rat)
+
+	case BigDecimal:
+		return strings.TrimSuffix(val.String(), "M")
+
+	case String:
+		return string(val)
+
+	case Character:
+		return rune(val)
+
+	case Keyword:
+		return string(val)
+
+	case Symbol:
+		return val.Value
+
+	case Any:
+		return val.V.Interface()
+
+	case *HashMap:
+		m := make(map[interface{}]interface{}, val.Size())
+		for it := val.Data.Iterator(); it.HasElem(); it.Next() {
+			k, v := it.Elem()
+			key := ToGo(k.(Value))
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				key = k.(Value).String()
+			}
+			m[key] = ToGo(v.(Value))
+		}
+		return m
+
+	case Seq:
+		result := []interface{}{}
+		for _, item := range realize(val).Values {
+			result = append(result, ToGo(item))
+		}
+		return result
+
+	default:
+		return v
+	}
+}
+
 func convertToVector(sl reflect.Value) *Vector {
 
 	pv := NewVector()
@@ -112,8 +193,8 @@ func (t Type) Invoke(scope Scope, args ...Value) (Value, error) {
 		pv = pv.Conj(argVals...).(*Vector)
 		return pv, nil
 
-	case reflect.TypeOf(Set{}):
-		return Set{Values: Values(argVals).Uniq()}, nil
+	case reflect.TypeOf(&Set{}):
+		return NewSet().Conj(argVals...), nil
 	}
 
 	likeSeq := isKind(t.T, reflect.Slice, reflect.Array)
@@ -134,7 +215,7 @@ func reflectFn(rv reflect.Value) *Fn {
 		Func: func(scope Scope, args []Value) (_ Value, err error) {
 			defer func() {
 				if v := recover(); v != nil {
-					err = fmt.Errorf("panic: %v", v)
+					err = panicError(v)
 				}
 			}()
 
@@ -143,6 +224,15 @@ func reflectFn(rv reflect.Value) *Fn {
 				return nil, err
 			}
 
+			return fw.Call(scope, args...)
+		},
+		strict: func(scope Scope, args []Value) (_ Value, err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = panicError(v)
+				}
+			}()
+
 			return fw.Call(scope, args...)
 		},
 	}
@@ -295,17 +385,28 @@ func convertArgsTo(expected reflect.Type, args ...reflect.Value) ([]reflect.Valu
 		case actual.ConvertibleTo(expected):
 			converted = append(converted, arg.Convert(expected))
 
+		// exact numbers which are not integers are passed to Go as floats
+		case isKind(expected, reflect.Float32, reflect.Float64) && isNumber(valueOfArg(arg)):
+			converted = append(converted, reflect.ValueOf(toFloat(valueOfArg(arg))).Convert(expected))
+
 		default:
-			return args, fmt.Errorf(
-				"TypeError: expected %s instead got %s",
-				RemovePrefix(expected.String()), RemovePrefix(actual.String()),
-			)
+			return args, TypeError{
+				expectedType: expected,
+				gotType:      actual,
+			}
 		}
 	}
 
 	return converted, nil
 }
 
+// valueOfArg returns the spirit value of a reflected argument, nil if it is
+// not one.
+func valueOfArg(arg reflect.Value) Value {
+	v, _ := arg.Interface().(Value)
+	return v
+}
+
 func isAssignable(from, to reflect.Type) bool {
 	return (from == to) || from.AssignableTo(to) ||
 		(to.Kind() == reflect.Interface && from.Implements(to))
@@ -21,9 +21,9 @@ func TestValueOf(t *testing.T) {
 		want Value
 	}{
 		{
-			name: "Number",
+			name: "Int",
 			v:    int64(10),
-			want: Number(10),
+			want: Int(10),
 		},
 		{
 			name: "Number",
@@ -102,7 +102,7 @@ func Test_strictFn_Invoke(t *testing.T) {
 		{
 			name: "SimpleNoArg",
 			v:    func() int { return 10 },
-			want: Number(10),
+			want: Int(10),
 		},
 		{
 			name:    "NoArgSingleErrorReturn",
@@ -125,13 +125,13 @@ func Test_strictFn_Invoke(t *testing.T) {
 			name: "SimpleSingleReturn",
 			v:    func(arg Number) int64 { return 10 },
 			args: []Value{Number(10)},
-			want: Number(10),
+			want: Int(10),
 		},
 		{
 			name: "MultiReturn",
 			v:    func(arg Number) (int64, string) { return 10, "hello" },
 			args: []Value{Number(10)},
-			want: Values([]Value{Number(10), String("hello")}),
+			want: Values([]Value{Int(10), String("hello")}),
 		},
 		{
 			name:    "NoArgMultiReturnWithError",
@@ -141,7 +141,7 @@ func Test_strictFn_Invoke(t *testing.T) {
 		{
 			name: "NoArgMultiReturnWithoutError",
 			v:    func() (int, error) { return 10, nil },
-			want: Number(10),
+			want: Int(10),
 		},
 		{
 			name: "PureVariadicNoCallArgs",
@@ -152,7 +152,7 @@ func Test_strictFn_Invoke(t *testing.T) {
 				}
 				return sum
 			},
-			want: Number(0),
+			want: Int(0),
 		},
 		{
 			name: "PureVariadicWithCallArgs",
@@ -164,7 +164,7 @@ func Test_strictFn_Invoke(t *testing.T) {
 				return sum
 			},
 			args: []Value{Number(1), Number(10)},
-			want: Number(11),
+			want: Int(11),
 		},
 		{
 			name:    "ArityErrorNonVariadic",
@@ -215,3 +215,38 @@ func Test_strictFn_Invoke(t *testing.T) {
 		})
 	}
 }
+
+func Test_reflectFn_TypeError(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name string
+		v    interface{}
+		arg  Value
+		want string
+	}{
+		{
+			name: "Interface",
+			v:    func(err error) {},
+			arg:  Int(1),
+			want: "TypeError: expected error instead got Int",
+		},
+		{
+			name: "Pointer",
+			v:    func(m *HashMap) {},
+			arg:  String("a"),
+			want: "TypeError: expected HashMap instead got String",
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			fn := reflectFn(reflect.ValueOf(tt.v))
+
+			_, err := fn.Invoke(NewScope(nil), tt.arg)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Invoke() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/xiaq/persistent/hash"
+)
+
+// Regex is a compiled regular expression, read from #"pattern". Patterns
+// use the RE2 syntax of the regexp package.
+type Regex struct {
+	re *regexp.Regexp
+
+	// full is the pattern anchored at both ends, for matching whole
+	// strings.
+	full *regexp.Regexp
+
+	// named is true if the pattern has named groups.
+	named bool
+}
+
+// NewRegex compiles the pattern into a regular expression.
+func NewRegex(pattern string) (Regex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Regex{}, err
+	}
+
+	full, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return Regex{}, err
+	}
+	named := false
+	for _, name := range re.SubexpNames() {
+		named = named || name != ""
+	}
+	return Regex{re: re, full: full, named: named}, nil
+}
+
+// Eval returns the regular expression itself.
+func (re Regex) Eval(_ Scope) (Value, error) { return re, nil }
+
+func (re Regex) String() string {
+	if re.re == nil {
+		return `#""`
+	}
+	return `#"` + re.re.String() + `"`
+}
+
+// Compare returns true if the other value is a regular expression of the
+// same pattern.
+func (re Regex) Compare(other Value) bool {
+	o, ok := other.(Regex)
+	return ok && re.re.String() == o.re.String()
+}
+
+// Hash returns the hash of the pattern.
+func (re Regex) Hash() uint32 { return hash.String(re.re.String()) }
+
+// match returns the value of the match at the submatch indexes of s: the
+// text matched if the pattern has no groups, the groups as a map if it has
+// named groups, or else a vector of the text matched followed by the
+// groups. Groups which took no part in the match are nil.
+func (re Regex) match(s string, loc []int) Value {
+	if re.re.NumSubexp() == 0 {
+		return String(s[loc[0]:loc[1]])
+	} else if re.named {
+		return re.groups(s, loc)
+	}
+
+	vals := make([]Value, 0, len(loc)/2)
+	for i := 0; i < len(loc); i += 2 {
+		vals = append(vals, submatch(s, loc[i], loc[i+1]))
+	}
+	return NewVector().Conj(vals...)
+}
+
+// groups returns the groups of the match at the submatch indexes of s as a
+// map, keyed by keyword for named groups and by index otherwise.
+func (re Regex) groups(s string, loc []int) Value {
+	var m Value = NewHashMap()
+	for i, name := range re.re.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+
+		var key Value = Int(i)
+		if name != "" {
+			key = Keyword(name)
+		}
+		m = m.(*HashMap).Set(key, submatch(s, loc[2*i], loc[2*i+1]))
+	}
+	return m
+}
+
+func submatch(s string, start, end int) Value {
+	if start < 0 {
+		return Nil{}
+	}
+	return String(s[start:end])
+}
+
+// readRegex reads the pattern of a regex literal. Backslashes are kept as
+// they are for the pattern to interpret, \" included.
+func readRegex(rd *Reader, _ rune) (Value, error) {
+	var b strings.Builder
+
+	for {
+		r, err := rd.NextRune()
+		if err != nil {
+			return nil, regexEOF(err)
+		}
+
+		if r == '"' {
+			break
+		}
+
+		b.WriteRune(r)
+		if r == '\\' {
+			r2, err := rd.NextRune()
+			if err != nil {
+				return nil, regexEOF(err)
+			}
+			b.WriteRune(r2)
+		}
+	}
+
+	re, err := NewRegex(b.String())
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+func regexEOF(err error) error {
+	if err == io.EOF {
+		return fmt.Errorf("%w: while reading regex", ErrEOF)
+	}
+	return err
+}
+
+// regexArg returns the argument as a regular expression, compiling it if
+// it is a string.
+func regexArg(v Value) (Regex, error) {
+	switch val := v.(type) {
+	case Regex:
+		return val, nil
+
+	case String:
+		return NewRegex(string(val))
+
+	default:
+		return Regex{}, TypeError{
+			Expected: Regex{},
+			Got:      v,
+		}
+	}
+}
+
+// regexArgs returns the regular expression and the string searched of the
+// first two arguments.
+func regexArgs(args []Value) (Regex, string, error) {
+	re, err := regexArg(args[0])
+	if err != nil {
+		return Regex{}, "", err
+	}
+
+	s, ok := args[1].(String)
+	if !ok {
+		return Regex{}, "", TypeError{
+			Expected: String(""),
+			Got:      args[1],
+		}
+	}
+	return re, string(s), nil
+}
+
+// rePattern compiles the string into a regular expression.
+func rePattern(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+	return regexArg(args[0])
+}
+
+// reFind returns the first match of the pattern in the string, nil if there
+// is none.
+func reFind(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2}, args); err != nil {
+		return nil, err
+	}
+
+	re, s, err := regexArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := re.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return Nil{}, nil
+	}
+	return re.match(s, loc), nil
+}
+
+// reMatches returns the match of the pattern if it matches the whole
+// string, nil otherwise.
+func reMatches(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2}, args); err != nil {
+		return nil, err
+	}
+
+	re, s, err := regexArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := re.full.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return Nil{}, nil
+	}
+	return re.match(s, loc), nil
+}
+
+// reSeq returns a list of the successive matches of the pattern in the
+// string.
+func reSeq(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2}, args); err != nil {
+		return nil, err
+	}
+
+	re, s, err := regexArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	locs := re.re.FindAllStringSubmatchIndex(s, -1)
+	vals := make([]Value, len(locs))
+	for i, loc := range locs {
+		vals[i] = re.match(s, loc)
+	}
+	return &List{Values: vals}, nil
+}
+
+// reGroups returns the groups of the first match of the pattern in the
+// string, nil if there is no match. Like re-find, the groups are a map if
+// the pattern has named groups, with the named groups keyed by keyword and
+// the others by their index.
+func reGroups(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2}, args); err != nil {
+		return nil, err
+	}
+
+	re, s, err := regexArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := re.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return Nil{}, nil
+	}
+	return re.match(s, loc), nil
+}
+
+// reReplace replaces the matches of the pattern in the string. A string
+// replacement may refer to groups with $1 or ${name}; a function
+// replacement is called with each match and its result is inserted as by
+// str.
+func reReplace(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{3}, args); err != nil {
+		return nil, err
+	}
+
+	re, s, err := regexArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if repl, ok := args[2].(String); ok {
+		return String(re.re.ReplaceAllString(s, string(repl))), nil
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.re.FindAllStringSubmatchIndex(s, -1) {
+		v, err := invoke(scope, args[2], re.match(s, loc))
+		if err != nil {
+			return nil, err
+		}
+
+		b.WriteString(s[last:loc[0]])
+		if str, ok := v.(String); ok {
+			b.WriteString(string(str))
+		} else if v != (Nil{}) {
+			b.WriteString(v.String())
+		}
+		last = loc[1]
+	}
+	b.WriteString(s[last:])
+
+	return String(b.String()), nil
+}
+
+// reSplit splits the string around the matches of the pattern, into at
+// most limit parts if given.
+func reSplit(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2, 3}, args); err != nil {
+		return nil, err
+	}
+
+	re, s, err := regexArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := -1
+	if len(args) == 3 {
+		n, ok := args[2].(Int)
+		if !ok {
+			return nil, TypeError{
+				Expected: Int(0),
+				Got:      args[2],
+			}
+		}
+		limit = int(n)
+	}
+
+	parts := re.re.Split(s, limit)
+	vals := make([]Value, len(parts))
+	for i, part := range parts {
+		vals[i] = String(part)
+	}
+	return NewVector().Conj(vals...), nil
+}
+
+func isRegex(v Value) bool {
+	_, ok := v.(Regex)
+	return ok
+}
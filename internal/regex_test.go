@@ -0,0 +1,144 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/issadarkthing/spirit/internal"
+)
+
+func TestRegex(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "FindNoGroups",
+			src:  `(re-find #"\d+" "abc 123 45")`,
+			want: `"123"`,
+		},
+		{
+			name: "FindGroups",
+			src:  `(re-find #"(\w+)@(\w+)?" "mail: joe@")`,
+			want: `["joe@" "joe" nil]`,
+		},
+		{
+			name: "FindNone",
+			src:  `(re-find #"\d" "abc")`,
+			want: `nil`,
+		},
+		{
+			name: "FindStringPattern",
+			src:  `(re-find "b+" "abbc")`,
+			want: `"bb"`,
+		},
+		{
+			name: "MatchesWhole",
+			src:  `(re-matches #"a|ab" "ab")`,
+			want: `"ab"`,
+		},
+		{
+			name: "MatchesPartial",
+			src:  `(re-matches #"\d+" "12a")`,
+			want: `nil`,
+		},
+		{
+			name: "Seq",
+			src:  `(re-seq #"(\w)=(\d)" "a=1, b=2")`,
+			want: `(["a=1" "a" "1"] ["b=2" "b" "2"])`,
+		},
+		{
+			name: "SeqNone",
+			src:  `(re-seq #"x" "abc")`,
+			want: `()`,
+		},
+		{
+			name: "GroupsNamed",
+			src:  `(re-groups #"(?P<year>\d{4})-(\d\d)" "on 2024-05")`,
+			want: `{:year "2024" 2 "05"}`,
+		},
+		{
+			name: "GroupsUnnamed",
+			src:  `(re-groups #"(\w)(\d)" "a1")`,
+			want: `["a1" "a" "1"]`,
+		},
+		{
+			name: "FindNamed",
+			src:  `(re-find #"(?P<key>\w+)=(\d)" "a=1")`,
+			want: `{:key "a" 2 "1"}`,
+		},
+		{
+			name: "MatchesNamed",
+			src:  `(re-matches #"(?P<key>\w+)=(?P<val>\d)" "a=1")`,
+			want: `{:key "a" :val "1"}`,
+		},
+		{
+			name: "SeqNamed",
+			src:  `(re-seq #"(?P<d>\d)" "a1b2")`,
+			want: `({:d "1"} {:d "2"})`,
+		},
+		{
+			name: "GroupsNone",
+			src:  `(re-groups #"(?P<year>\d{4})" "today")`,
+			want: `nil`,
+		},
+		{
+			name: "ReplaceString",
+			src:  `(re-replace #"(?P<k>\w+)=(\w+)" "a=1 b=2" "$2:${k}")`,
+			want: `"1:a 2:b"`,
+		},
+		{
+			name: "ReplaceFn",
+			src:  `(re-replace #"\d+" "a1b22" (fn* [m] (str m m)))`,
+			want: `"a11b2222"`,
+		},
+		{
+			name: "ReplaceFnGroups",
+			src:  `(re-replace #"(\w)(\w*)" "hello world" (fn* [m] (str (m 2) (m 1))))`,
+			want: `"elloh orldw"`,
+		},
+		{
+			name: "Split",
+			src:  `(re-split #"\s*,\s*" "a , b,c")`,
+			want: `["a" "b" "c"]`,
+		},
+		{
+			name: "SplitLimit",
+			src:  `(re-split #":" "a:b:c" 2)`,
+			want: `["a" "b:c"]`,
+		},
+		{
+			name: "Pattern",
+			src:  `(= (re-pattern "a\\d") #"a\d")`,
+			want: `true`,
+		},
+		{
+			name:    "InvalidPattern",
+			src:     `(re-find "(a" "a")`,
+			wantErr: true,
+		},
+		{
+			name:    "NotString",
+			src:     `(re-find #"a" 1)`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := internal.NewSpirit().ReadEvalStr(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadEvalStr() error = %v, wantErr %v", err, tt.wantErr)
+			} else if tt.wantErr {
+				return
+			}
+
+			if want := mustRead(t, tt.want); !internal.Compare(got, want) {
+				t.Errorf("ReadEvalStr() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@
 package internal
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -11,7 +14,9 @@ import (
 
 // Eval evaluates the given form against the scope and returns the result
 // of evaluation.
-func Eval(scope Scope, form Value) (Value, error) {
+func Eval(scope Scope, form Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+
 	if form == nil {
 		return Nil{}, nil
 	}
@@ -24,10 +29,69 @@ func Eval(scope Scope, form Value) (Value, error) {
 	return v, nil
 }
 
+// EvalContext is like Eval but the evaluation is stopped with the context
+// error once ctx is cancelled or its deadline is exceeded. Loops, recursive
+// calls and blocking operations like deref check the context, so runaway
+// forms can be interrupted. Futures started during the evaluation inherit
+// the context.
+func EvalContext(ctx context.Context, scope Scope, form Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+
+	exec := executionOf(scope)
+	if exec == nil {
+		return Eval(scope, form)
+	}
+
+	prev := exec.ctx
+	exec.ctx = ctx
+	defer func() { exec.ctx = prev }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return notRecur(Eval(scope, form))
+}
+
+// ReadEvalContext is like ReadEval but the evaluation is stopped once ctx
+// is done. See EvalContext().
+func ReadEvalContext(ctx context.Context, scope Scope, r io.Reader) (_ Value, err error) {
+	defer recoverSeq(&err)
+
+	exec := executionOf(scope)
+	if exec == nil {
+		return ReadEval(scope, r)
+	}
+
+	prev := exec.ctx
+	exec.ctx = ctx
+	defer func() { exec.ctx = prev }()
+
+	return ReadEval(scope, r)
+}
+
 // ReadEval consumes data from reader 'r' till EOF, parses into forms
-// and evaluates all the forms obtained and returns the result.
-func ReadEval(scope Scope, r io.Reader) (Value, error) {
-	mod, err := NewReader(r).All()
+// and evaluates all the forms obtained and returns the result. The forms
+// are read and evaluated one at a time, so a form may change how the forms
+// after it are read, e.g. by registering a tag reader. Definitions are
+// hoisted from a first read of the source which leaves tagged literals
+// unread.
+func ReadEval(scope Scope, r io.Reader) (_ Value, err error) {
+	defer recoverSeq(&err)
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	file := inferFileName(r)
+
+	rd := NewReader(bytes.NewReader(src))
+	rd.File = file
+	rd.SetScope(scope)
+	rd.tagged = keepTagged
+
+	mod, err := rd.All()
 	if err != nil {
 		return nil, err
 	}
@@ -37,11 +101,46 @@ func ReadEval(scope Scope, r io.Reader) (Value, error) {
 		return nil, err
 	}
 
-	return Eval(scope, mod)
+	rd = NewReader(bytes.NewReader(src))
+	rd.File = file
+	rd.SetScope(scope)
+
+	var res Value = Nil{}
+	for {
+		form, err := rd.One()
+		if err == io.EOF {
+			return res, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		res, err = notRecur(Eval(scope, form))
+		if err != nil {
+			return nil, newEvalErr(form, err)
+		}
+	}
+}
+
+// notRecur fails if recur is evaluated outside of a loop or function. The
+// compiler cannot tell for forms compiled at top level.
+func notRecur(v Value, err error) (Value, error) {
+	if err == nil && isRecur(v) {
+		return nil, errors.New("recur outside of loop or fn")
+	}
+	return v, err
 }
 
 func hoistValues(scope Scope, value Value) error {
 
+	// namespace forms switch the namespace in order too, so the values are
+	// hoisted into the namespace they are defined in. The namespaces are
+	// required once the module is evaluated, as they may be defined by the
+	// module itself. The current namespace is restored for the evaluation.
+	if spirit, ok := RootScope(scope).(*Spirit); ok {
+		ns := Symbol{Value: spirit.CurrentNS()}
+		defer func() { _ = spirit.SwitchNS(ns) }()
+	}
+
 	hoistedVals := []string{"def", "defn", "defmacro", "defclass"}
 	for _, form := range value.(Module) {
 		list, ok := form.(*List)
@@ -59,6 +158,14 @@ func hoistValues(scope Scope, value Value) error {
 				reflect.TypeOf(list.Values[0]))
 		}
 
+		if def.Value == "ns" || def.Value == "in-ns" {
+			switchNS := &List{Values: list.Values[:2]}
+			if _, err := switchNS.Eval(scope); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if !includes(def.String(), hoistedVals) {
 			continue
 		}
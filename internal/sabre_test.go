@@ -1,8 +1,12 @@
 package internal_test
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/issadarkthing/spirit/internal"
 )
@@ -81,7 +85,7 @@ func TestEval(t *testing.T) {
 		{
 			name: "SingleForm",
 			src:  "123",
-			want: internal.Number(123),
+			want: internal.Int(123),
 		},
 		{
 			name: "MultiForm",
@@ -170,3 +174,81 @@ func isTruthy(v internal.Value) bool {
 	}
 	return true
 }
+
+func TestEvalContext(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "Loop",
+			src:  `(loop [x 0] (recur x))`,
+		},
+		{
+			name: "FnRecur",
+			src: `(def f (fn* [x] (recur x)))
+				  (f 1)`,
+		},
+		{
+			name: "Deref",
+			src:  `(deref (future* (loop [x 0] (recur x))))`,
+		},
+		{
+			name: "Sleep",
+			src:  `(sleep 10000)`,
+		},
+		{
+			name: "ChanFirst",
+			src:  `(def c (chan)) (c.First)`,
+		},
+		{
+			name: "ChanSize",
+			src:  `(def c (chan 1)) (c.Size)`,
+		},
+		{
+			name: "ChanNext",
+			src:  `(def c (chan 1)) (c.Conj 1) (c.Next)`,
+		},
+		{
+			name: "TryDoesNotCatch",
+			src:  `(try (loop [x 0] (recur x)) (fn* [e] :caught))`,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := internal.NewSpirit()
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err := internal.ReadEvalContext(ctx, scope, strings.NewReader(tt.src))
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("ReadEvalContext() error = %v, want %v",
+					err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
+
+func TestEvalContext_Completes(t *testing.T) {
+	scope := internal.NewSpirit()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got, err := internal.ReadEvalContext(ctx, scope,
+		strings.NewReader(`(loop [x 0] (if (= x 10) x (recur (+ x 1))))`))
+	if err != nil {
+		t.Fatalf("ReadEvalContext() unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, internal.Int(10)) {
+		t.Errorf("ReadEvalContext() got = %v, want 10", got)
+	}
+
+	cancel()
+	if _, err := scope.EvalContext(ctx, internal.Number(1)); err != context.Canceled {
+		t.Errorf("EvalContext() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/metrics"
+	"strings"
+	"sync/atomic"
+)
+
+// Capability is a privilege granted to a sandboxed Spirit instance. Each
+// capability unlocks a group of builtins, see Restrict().
+type Capability uint
+
+const (
+	// CapFileRead allows reading files and standard input.
+	CapFileRead Capability = 1 << iota
+	// CapFileWrite allows writing files.
+	CapFileWrite
+	// CapShell allows running shell commands.
+	CapShell
+	// CapNetwork allows builtins bound with the network capability. See
+	// BindCapability().
+	CapNetwork
+	// CapReflect allows member access on arbitrary Go values and
+	// inspecting the host runtime.
+	CapReflect
+	// CapTime allows observing and waiting on the clock.
+	CapTime
+
+	// CapAll grants every capability.
+	CapAll = CapFileRead | CapFileWrite | CapShell | CapNetwork | CapReflect | CapTime
+)
+
+var capabilityNames = map[Capability]string{
+	CapFileRead:  "file-read",
+	CapFileWrite: "file-write",
+	CapShell:     "shell",
+	CapNetwork:   "network",
+	CapReflect:   "reflect",
+	CapTime:      "time",
+}
+
+func (c Capability) String() string {
+	var names []string
+	for cap := CapFileRead; cap <= CapTime; cap <<= 1 {
+		if c&cap != 0 {
+			names = append(names, capabilityNames[cap])
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// builtinCapabilities maps the builtins to the capability they require.
+var builtinCapabilities = map[string]Capability{
+	"core/read-file":  CapFileRead,
+	"core/import":     CapFileRead,
+	"core/read*":      CapFileRead,
+	"core/json-seq":   CapFileRead,
+	"core/write-file": CapFileWrite,
+	"core/write-json": CapFileWrite,
+	"core/write-edn":  CapFileWrite,
+	"core/$":          CapShell,
+	"core/memory":     CapReflect,
+	"core/mem":        CapReflect,
+	"core/force-gc":   CapReflect,
+	"core/sleep":      CapTime,
+	"core/time":       CapTime,
+	"core/timeout":    CapTime,
+}
+
+// reflectFreeMembers are the members that can be accessed on spirit values
+// without the reflect capability. These are required by the core library.
+var reflectFreeMembers = map[string]bool{
+	"GetDoc":    true,
+	"Name":      true,
+	"SubVector": true,
+	"Index":     true,
+}
+
+func init() {
+	for _, iface := range []reflect.Type{
+		reflect.TypeOf((*Seq)(nil)).Elem(),
+		reflect.TypeOf((*Assoc)(nil)).Elem(),
+	} {
+		for i := 0; i < iface.NumMethod(); i++ {
+			reflectFreeMembers[iface.Method(i).Name] = true
+		}
+	}
+}
+
+// memoryCheckInterval is the number of evaluation steps between two checks
+// of the memory limit. Reading the heap size is too expensive to be done on
+// every step.
+const memoryCheckInterval = 1 << 12
+
+// DefaultMaxDepth is the call depth limit of instances which are not given
+// one by their sandbox.
+const DefaultMaxDepth = 100000
+
+// Sandbox restricts what the forms evaluated by a Spirit instance can do.
+type Sandbox struct {
+	// Capabilities are the privileges granted to the instance.
+	Capabilities Capability
+	// MaxSteps limits the number of forms the instance evaluates, along with
+	// the iterations of builtins looping over collections. Zero means no
+	// limit.
+	MaxSteps int64
+	// MaxMemory limits the bytes by which the heap grows from its size when
+	// the instance is first restricted. The heap is shared by the process,
+	// so the allocations of other instances and goroutines count as well.
+	// Zero means no limit.
+	MaxMemory uint64
+	// MaxDepth limits the number of nested calls, exceeding it fails with
+	// StackOverflowError. Zero means DefaultMaxDepth.
+	MaxDepth int
+
+	// heapBase is the heap in use when the instance was first restricted.
+	heapBase uint64
+}
+
+// Restrict puts the instance into the given sandbox. Builtins requiring a
+// capability that is not granted are replaced with functions that fail with
+// PermissionError. Restrictions can only be tightened by further calls.
+func (s *Spirit) Restrict(sb Sandbox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sandbox != nil {
+		sb.Capabilities &= s.sandbox.Capabilities
+		sb.heapBase = s.sandbox.heapBase
+
+		if prev := s.sandbox.MaxDepth; prev > 0 && (sb.MaxDepth == 0 || sb.MaxDepth > prev) {
+			sb.MaxDepth = prev
+		}
+	} else {
+		sb.heapBase = heapInUse()
+	}
+
+	for name, cap := range builtinCapabilities {
+		s.deny(name, cap, sb.Capabilities)
+	}
+
+	for name, cap := range s.capabilities {
+		s.deny(name, cap, sb.Capabilities)
+	}
+
+	atomic.StoreInt64(&s.steps, 0)
+	s.sandbox = &sb
+}
+
+// BindCapability binds the value like BindGo but only when the instance is
+// granted the capability. Otherwise invoking the value fails with a
+// PermissionError.
+func (s *Spirit) BindCapability(symbol string, cap Capability, v interface{}) error {
+	if err := s.BindGo(symbol, v); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nsSym, err := s.splitSymbol(symbol)
+	if err != nil {
+		return err
+	}
+
+	name := nsSym.NS + string(nsSeparator) + nsSym.Name
+	if s.capabilities == nil {
+		s.capabilities = map[string]Capability{}
+	}
+	s.capabilities[name] = cap
+
+	if s.sandbox != nil {
+		s.deny(name, cap, s.sandbox.Capabilities)
+	}
+
+	return nil
+}
+
+// Allowed returns true if the instance is granted the capability.
+func (s *Spirit) Allowed(cap Capability) bool {
+	sb := s.restriction()
+	return sb == nil || sb.Capabilities&cap == cap
+}
+
+// restriction returns the sandbox of the instance, nil if it is not
+// restricted. The sandbox is replaced rather than changed by Restrict.
+func (s *Spirit) restriction() *Sandbox {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sandbox
+}
+
+// deny replaces the builtin with one failing with PermissionError unless
+// the capability is granted. s.mu must be held.
+func (s *Spirit) deny(name string, cap, granted Capability) {
+	if granted&cap == cap {
+		return
+	}
+
+	nsSym, err := s.splitSymbol(name)
+	if err != nil {
+		return
+	}
+
+	if _, found := s.Bindings[*nsSym]; !found {
+		return
+	}
+
+	s.Bindings[*nsSym] = &Fn{
+		Args:     []string{"args"},
+		Variadic: true,
+		Func: func(_ Scope, _ []Value) (Value, error) {
+			return nil, PermissionError{Name: name, Capability: cap}
+		},
+	}
+	atomic.AddUint64(&s.version, 1)
+}
+
+// step accounts one evaluation step against the limits of the sandbox.
+func (s *Spirit) step() error {
+	sb := s.restriction()
+	if sb == nil || (sb.MaxSteps == 0 && sb.MaxMemory == 0) {
+		return nil
+	}
+
+	steps := atomic.AddInt64(&s.steps, 1)
+	if sb.MaxSteps > 0 && steps > sb.MaxSteps {
+		return LimitError{Limit: "steps", Max: uint64(sb.MaxSteps)}
+	}
+
+	if sb.MaxMemory > 0 && steps%memoryCheckInterval == 0 {
+		if heap := heapInUse(); heap > sb.heapBase && heap-sb.heapBase > sb.MaxMemory {
+			return LimitError{Limit: "memory", Max: sb.MaxMemory}
+		}
+	}
+
+	return nil
+}
+
+// maxDepth returns the call depth limit of the instance.
+func (s *Spirit) maxDepth() int {
+	if sb := s.restriction(); sb != nil && sb.MaxDepth > 0 {
+		return sb.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// checkCapability returns PermissionError if the instance is not granted the
+// capability, for builtins which only require it for some of their uses.
+func checkCapability(scope Scope, name string, cap Capability) error {
+	spirit, ok := RootScope(scope).(*Spirit)
+	if !ok || spirit.Allowed(cap) {
+		return nil
+	}
+	return PermissionError{Name: name, Capability: cap}
+}
+
+// checkMemberAccess returns PermissionError if accessing the member of the
+// value requires the reflect capability that the instance does not have.
+func checkMemberAccess(scope Scope, target reflect.Value, member string) error {
+	spirit, ok := RootScope(scope).(*Spirit)
+	if !ok || spirit.Allowed(CapReflect) {
+		return nil
+	}
+
+	switch target.Interface().(type) {
+	case Object, Class:
+		return nil
+
+	case Any:
+
+	case Value:
+		if reflectFreeMembers[member] {
+			return nil
+		}
+	}
+
+	return PermissionError{
+		Name:       fmt.Sprintf("member '%s'", member),
+		Capability: CapReflect,
+	}
+}
+
+func heapInUse() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
@@ -0,0 +1,219 @@
+package internal_test
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/issadarkthing/spirit/internal"
+)
+
+type host struct{ Secret string }
+
+func TestSpirit_Restrict(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name     string
+		sandbox  internal.Sandbox
+		src      string
+		wantErr  error
+		wantPerm bool
+	}{
+		{
+			name:     "ShellDenied",
+			src:      `($ "echo hello")`,
+			wantPerm: true,
+		},
+		{
+			name:    "ShellGranted",
+			sandbox: internal.Sandbox{Capabilities: internal.CapShell},
+			src:     `($ "true")`,
+		},
+		{
+			name:     "ReadFileDenied",
+			sandbox:  internal.Sandbox{Capabilities: internal.CapShell},
+			src:      `(read-file "/etc/hostname")`,
+			wantPerm: true,
+		},
+		{
+			name:     "JSONSeqDenied",
+			src:      `(json-seq "/etc/hostname")`,
+			wantPerm: true,
+		},
+		{
+			name:     "WriteJSONDenied",
+			src:      `(write-json "/tmp/spirit.json" [])`,
+			wantPerm: true,
+		},
+		{
+			name:     "WriteEDNDenied",
+			src:      `(write-edn "/tmp/spirit.edn" {})`,
+			wantPerm: true,
+		},
+		{
+			name:     "ImportDenied",
+			src:      `(import "./core.st")`,
+			wantPerm: true,
+		},
+		{
+			name:     "SleepDenied",
+			src:      `(sleep 1)`,
+			wantPerm: true,
+		},
+		{
+			name:     "TimeoutDenied",
+			src:      `(timeout 1)`,
+			wantPerm: true,
+		},
+		{
+			name:     "DerefTimeoutDenied",
+			src:      `(deref (future* 1) 10 :late)`,
+			wantPerm: true,
+		},
+		{
+			name: "DerefAllowed",
+			src:  `(deref (future* 1))`,
+		},
+		{
+			name:    "DerefTimeoutGranted",
+			sandbox: internal.Sandbox{Capabilities: internal.CapTime},
+			src:     `(deref (future* 1) 10 :late)`,
+		},
+		{
+			name: "SeqMemberAllowed",
+			src:  `(def v [1 2]) (v.First)`,
+		},
+		{
+			name:     "GoMemberDenied",
+			src:      `host.Secret`,
+			wantPerm: true,
+		},
+		{
+			name:     "InternalMemberDenied",
+			src:      `(def f (fn* [] 1)) f.Methods`,
+			wantPerm: true,
+		},
+		{
+			name:    "GoMemberGranted",
+			sandbox: internal.Sandbox{Capabilities: internal.CapReflect},
+			src:     `host.Secret`,
+		},
+		{
+			name:    "StepLimit",
+			sandbox: internal.Sandbox{MaxSteps: 100},
+			src:     `(loop [x 0] (recur (+ x 1)))`,
+			wantErr: internal.LimitError{Limit: "steps", Max: 100},
+		},
+		{
+			name:    "StepLimitGoLoop",
+			sandbox: internal.Sandbox{MaxSteps: 100},
+			src:     `(doseq [x (lazy-range* 0 1000 1)] x)`,
+			wantErr: internal.LimitError{Limit: "steps", Max: 100},
+		},
+		{
+			name:    "StepLimitNotCaught",
+			sandbox: internal.Sandbox{MaxSteps: 100},
+			src:     `(try (loop [x 0] (recur (+ x 1))) (fn* [e] e))`,
+			wantErr: internal.LimitError{Limit: "steps", Max: 100},
+		},
+		{
+			name:    "DepthLimit",
+			sandbox: internal.Sandbox{MaxDepth: 100},
+			src:     `(def f (fn* [n] (+ 1 (f n)))) (f 1)`,
+			wantErr: internal.StackOverflowError{Depth: 100},
+		},
+		{
+			name:    "DepthLimitCaught",
+			sandbox: internal.Sandbox{MaxDepth: 100},
+			src: `(def f (fn* [n] (+ 1 (f n))))
+				  (try (f 1) (catch StackOverflowError e :overflow))`,
+		},
+		{
+			name:    "MemoryLimit",
+			sandbox: internal.Sandbox{MaxMemory: 1},
+			src:     `(loop [x 0] (recur (+ x 1)))`,
+			wantErr: internal.LimitError{Limit: "memory", Max: 1},
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := internal.NewSpirit()
+			_ = scope.BindGo("host", host{Secret: "password"})
+			scope.Restrict(tt.sandbox)
+
+			_, err := scope.ReadEvalStr(tt.src)
+
+			var permErr internal.PermissionError
+			if isPerm := errors.As(err, &permErr); isPerm != tt.wantPerm {
+				t.Errorf("ReadEvalStr() error = %v, wantPerm %v", err, tt.wantPerm)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadEvalStr() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !tt.wantPerm && tt.wantErr == nil && err != nil {
+				t.Errorf("ReadEvalStr() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSpirit_Restrict_MemoryBaseline(t *testing.T) {
+	// the heap in use before the instance is restricted does not count
+	// against its limit.
+	ballast := make([]byte, 64<<20)
+
+	scope := internal.NewSpirit()
+	scope.Restrict(internal.Sandbox{MaxMemory: 16 << 20})
+
+	if _, err := scope.ReadEvalStr(`(doseq [x (lazy-range* 0 10000 1)] (do x))`); err != nil {
+		t.Errorf("ReadEvalStr() unexpected error: %v", err)
+	}
+	runtime.KeepAlive(ballast)
+}
+
+func TestSpirit_BindCapability(t *testing.T) {
+	scope := internal.NewSpirit()
+	fetch := func(url string) string { return "response" }
+
+	if err := scope.BindCapability("fetch", internal.CapNetwork, fetch); err != nil {
+		t.Fatalf("BindCapability() unexpected error: %v", err)
+	}
+
+	if _, err := scope.ReadEvalStr(`(fetch "url")`); err != nil {
+		t.Errorf("ReadEvalStr() unexpected error before Restrict(): %v", err)
+	}
+
+	scope.Restrict(internal.Sandbox{Capabilities: internal.CapTime})
+
+	_, err := scope.ReadEvalStr(`(fetch "url")`)
+	if !errors.As(err, &internal.PermissionError{}) {
+		t.Errorf("ReadEvalStr() error = %v, want PermissionError", err)
+	}
+
+	if scope.Allowed(internal.CapNetwork) || !scope.Allowed(internal.CapTime) {
+		t.Errorf("Allowed() does not match granted capabilities")
+	}
+}
+
+func TestSpirit_Restrict_Concurrent(t *testing.T) {
+	scope := internal.NewSpirit()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = scope.Allowed(internal.CapTime)
+		}
+		_, _ = scope.ReadEvalStr(`(do (do 1))`)
+	}()
+
+	for i := 0; i < 100; i++ {
+		scope.Restrict(internal.Sandbox{Capabilities: internal.CapAll, MaxSteps: 1 << 30})
+		_ = scope.BindCapability("fetch", internal.CapNetwork, func() {})
+	}
+	<-done
+}
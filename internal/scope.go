@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"errors"
 )
 
@@ -36,16 +37,140 @@ func New() *MapScope {
 // NewScope returns an instance of MapScope with no bindings. If you need
 // builtin special forms, pass result of New() as argument.
 func NewScope(parent Scope) *MapScope {
-	return &MapScope{
+	scope := &MapScope{
 		parent:   parent,
 		bindings: map[string]Value{},
 	}
+
+	if p, ok := parent.(*MapScope); ok {
+		scope.exec = p.exec
+	}
+
+	return scope
 }
 
 // MapScope implements Scope using a Go native hash-map.
 type MapScope struct {
 	parent   Scope
 	bindings map[string]Value
+	exec     *execution
+}
+
+// execution holds the state of a single flow of evaluation. Function scopes
+// take the execution of their caller instead of the scope they were defined
+// in, so the state follows the call chain rather than the lexical one.
+// Every goroutine evaluating forms, e.g. a future, has its own execution.
+type execution struct {
+	ctx   context.Context
+	stack *Stack
+	// depth is the number of nested calls being evaluated.
+	depth int
+}
+
+// enter counts a nested call. Returns StackOverflowError once the depth
+// limit of the instance is reached, well before the Go stack overflows.
+func (e *execution) enter(root *Spirit) error {
+	if max := root.maxDepth(); e.depth >= max {
+		return StackOverflowError{Depth: max}
+	}
+
+	e.depth++
+	return nil
+}
+
+// leave ends a call counted by enter.
+func (e *execution) leave() {
+	e.depth--
+}
+
+// newExecution returns an execution with an empty call stack that is
+// cancelled along with ctx.
+func newExecution(ctx context.Context) *execution {
+	return &execution{ctx: ctx, stack: &Stack{}}
+}
+
+// detach returns a scope of the given one evaluating in an execution of its
+// own, cancelled along with the evaluation in the given scope. Lazy
+// sequences are realised in a detached scope, as any goroutine using them
+// may realise them.
+func detach(scope Scope) *MapScope {
+	s := NewScope(scope)
+	s.exec = newExecution(contextOf(scope))
+	return s
+}
+
+// executionOf finds the execution the given scope is evaluated in. Returns
+// nil if the scope does not belong to a Spirit instance.
+func executionOf(scope Scope) *execution {
+	for s := scope; s != nil; s = s.Parent() {
+		switch sc := s.(type) {
+		case *frame:
+			return sc.exec
+
+		case *MapScope:
+			if sc.exec != nil {
+				return sc.exec
+			}
+
+		case *Spirit:
+			return &sc.main
+		}
+	}
+
+	return nil
+}
+
+// checkContext returns the context error once the evaluation in the given
+// scope has been cancelled or its deadline exceeded. It also accounts a step
+// against the sandbox limits of the instance, as builtins looping in Go
+// call it for each iteration.
+func checkContext(scope Scope) error {
+	exec := executionOf(scope)
+	if exec == nil {
+		return nil
+	}
+
+	if err := exec.err(); err != nil {
+		return err
+	}
+
+	if spirit, ok := RootScope(scope).(*Spirit); ok {
+		return spirit.step()
+	}
+	return nil
+}
+
+// err returns the context error once the execution has been cancelled.
+func (exec *execution) err() error {
+	if exec.ctx == nil {
+		return nil
+	}
+
+	select {
+	case <-exec.ctx.Done():
+		return exec.ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// contextOf returns the context of the evaluation in the given scope.
+func contextOf(scope Scope) context.Context {
+	exec := executionOf(scope)
+	if exec == nil || exec.ctx == nil {
+		return context.Background()
+	}
+
+	return exec.ctx
+}
+
+// isAbort returns true if err is caused by cancelling the context of an
+// evaluation or by exceeding a sandbox limit. Such errors can not be caught
+// by try.
+func isAbort(err error) bool {
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &LimitError{})
 }
 
 // Parent returns the parent scope of this scope.
@@ -67,7 +192,7 @@ func (scope *MapScope) Resolve(symbol string) (Value, error) {
 			return scope.parent.Resolve(symbol)
 		}
 
-		return nil, ResolveError{Symbol{Value: symbol}}
+		return nil, ResolveError{Sym: Symbol{Value: symbol}}
 	}
 
 	return v, nil
@@ -84,3 +209,223 @@ func (scope *MapScope) Has(symbol string) bool {
 func (scope *MapScope) BindGo(symbol string, v interface{}) error {
 	return scope.Bind(symbol, ValueOf(v))
 }
+
+// layout describes the locals of a compiled let, loop or function body.
+// Each local gets the slot at the index of its name.
+type layout struct {
+	names  []string
+	parent *layout
+}
+
+// lookup finds the slot of the local bound to the symbol. depth is the
+// number of enclosing layouts to go up from this one.
+func (l *layout) lookup(symbol string) (depth, idx int, found bool) {
+	for ; l != nil; l = l.parent {
+		if idx := l.index(symbol); idx >= 0 {
+			return depth, idx, true
+		}
+		depth++
+	}
+
+	return 0, 0, false
+}
+
+func (l *layout) index(symbol string) int {
+	for i := len(l.names) - 1; i >= 0; i-- {
+		if l.names[i] == symbol {
+			return i
+		}
+	}
+	return -1
+}
+
+// frame is the scope compiled code runs in. Locals are held in slots at
+// the index assigned by the compiler, so they are accessed without hashing
+// their names. A frame without layout is a transparent wrapper around a
+// scope that is not a frame.
+type frame struct {
+	layout *layout
+	slots  []Value
+	inline [4]Value
+
+	up     *frame
+	parent Scope
+	// outer is the first scope up the chain which is not a frame. global
+	// is true if it is the root Spirit, so global lookups can be cached.
+	outer  Scope
+	global bool
+
+	root *Spirit
+	exec *execution
+	// dynamic holds the bindings made at run time to symbols that are
+	// not locals, e.g. by doseq.
+	dynamic map[string]Value
+}
+
+func newFrame(l *layout, up *frame, exec *execution) *frame {
+	f := &frame{
+		layout: l,
+		up:     up,
+		parent: up,
+		outer:  up.outer,
+		global: up.global,
+		root:   up.root,
+		exec:   exec,
+	}
+
+	if n := len(l.names); n <= len(f.inline) {
+		f.slots = f.inline[:n]
+	} else {
+		f.slots = make([]Value, n)
+	}
+
+	return f
+}
+
+// frameOf returns the frame to run compiled code in the given scope.
+// Returns nil if the scope does not belong to a Spirit instance.
+func frameOf(scope Scope) *frame {
+	switch sc := scope.(type) {
+	case *frame:
+		return sc
+
+	case *Spirit:
+		if sc.top != nil {
+			return sc.top
+		}
+	}
+
+	root, ok := RootScope(scope).(*Spirit)
+	if !ok {
+		return nil
+	}
+
+	return &frame{
+		parent: scope,
+		outer:  scope,
+		global: scope == Scope(root),
+		root:   root,
+		exec:   executionOf(scope),
+	}
+}
+
+// check returns an error once the evaluation has been cancelled or has
+// exceeded a sandbox limit.
+func (f *frame) check() error {
+	if err := f.exec.err(); err != nil {
+		return err
+	}
+
+	return f.root.step()
+}
+
+// Parent returns the parent scope of this frame.
+func (f *frame) Parent() Scope { return f.parent }
+
+// Bind sets the local bound to the symbol. Symbols which are not locals
+// are bound dynamically in this frame.
+func (f *frame) Bind(symbol string, v Value) error {
+	if f.layout == nil {
+		return f.parent.Bind(symbol, v)
+	}
+
+	if idx := f.layout.index(symbol); idx >= 0 {
+		f.slots[idx] = v
+		return nil
+	}
+
+	if f.dynamic == nil {
+		f.dynamic = map[string]Value{}
+	}
+	f.dynamic[symbol] = v
+	return nil
+}
+
+// Has returns true if the symbol is a local or dynamically bound in this
+// frame.
+func (f *frame) Has(symbol string) bool {
+	if f.layout == nil {
+		return f.parent.Has(symbol)
+	}
+
+	if _, found := f.local(symbol); found {
+		return true
+	}
+
+	_, found := f.dynamic[symbol]
+	return found
+}
+
+// Resolve finds the value bound to the symbol in this frame or the parent
+// scopes.
+func (f *frame) Resolve(symbol string) (Value, error) {
+	if f.layout != nil {
+		if v, found := f.local(symbol); found {
+			return v, nil
+		}
+
+		if v, found := f.dynamic[symbol]; found {
+			return v, nil
+		}
+	}
+
+	if f.parent == nil {
+		return nil, ResolveError{Sym: Symbol{Value: symbol}}
+	}
+
+	return f.parent.Resolve(symbol)
+}
+
+// snapshot returns a scope holding the values bound at this point in the
+// given scope and its parents up to the root scope. Evaluations started in
+// another goroutine run in a snapshot, so they do not share the locals of
+// the evaluation which started them.
+func snapshot(scope Scope) *MapScope {
+	snap := &MapScope{bindings: map[string]Value{}}
+	bind := func(symbol string, v Value) {
+		if _, found := snap.bindings[symbol]; !found && v != nil {
+			snap.bindings[symbol] = v
+		}
+	}
+
+	s := scope
+	for ; s != nil; s = s.Parent() {
+		switch sc := s.(type) {
+		case *frame:
+			if sc.layout == nil {
+				continue
+			}
+
+			for i := len(sc.layout.names) - 1; i >= 0; i-- {
+				bind(sc.layout.names[i], sc.slots[i])
+			}
+			for symbol, v := range sc.dynamic {
+				bind(symbol, v)
+			}
+			continue
+
+		case *MapScope:
+			for symbol, v := range sc.bindings {
+				bind(symbol, v)
+			}
+			continue
+		}
+
+		// the root scope or a scope whose bindings are unknown
+		break
+	}
+
+	snap.parent = s
+	return snap
+}
+
+// local returns the value of the latest local bound to the symbol. Slots
+// of the locals which are not bound yet are skipped.
+func (f *frame) local(symbol string) (Value, bool) {
+	for i := len(f.layout.names) - 1; i >= 0; i-- {
+		if f.layout.names[i] == symbol && f.slots[i] != nil {
+			return f.slots[i], true
+		}
+	}
+	return nil, false
+}
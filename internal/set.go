@@ -0,0 +1,173 @@
+package internal
+
+// union returns the set of the members of all the sets.
+func union(sets ...*Set) *Set {
+	if len(sets) == 0 {
+		return NewSet()
+	}
+
+	// conjoin the members of the smaller sets into the largest one
+	res := sets[0]
+	for _, set := range sets[1:] {
+		if set.Size() > res.Size() {
+			res = set
+		}
+	}
+
+	for _, set := range sets {
+		if set != res {
+			res = res.Conj(set.values()...).(*Set)
+		}
+	}
+	return res
+}
+
+// intersection returns the set of the members found in every set.
+func intersection(set *Set, sets ...*Set) *Set {
+	res := set
+	for _, other := range sets {
+		for _, v := range res.values() {
+			if !other.Contains(v) {
+				res = res.Disj(v)
+			}
+		}
+	}
+	return res
+}
+
+// difference returns the set of the members of the first set found in none
+// of the other sets.
+func difference(set *Set, sets ...*Set) *Set {
+	res := set
+	for _, other := range sets {
+		res = res.Disj(other.values()...)
+	}
+	return res
+}
+
+// isSubset returns true if every member of set is a member of other.
+func isSubset(set, other *Set) bool {
+	if set.Size() > other.Size() {
+		return false
+	}
+
+	for _, v := range set.values() {
+		if !other.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSuperset returns true if set has every member of other.
+func isSuperset(set, other *Set) bool {
+	return isSubset(other, set)
+}
+
+// selectSet returns the set of the members for which the predicate returns
+// true.
+func selectSet(scope Scope, args []Value) (Value, error) {
+	pred := args[0]
+	set, ok := args[1].(*Set)
+	if !ok {
+		return nil, TypeError{
+			Expected: NewSet(),
+			Got:      args[1],
+		}
+	}
+
+	res := set
+	for _, v := range set.values() {
+		keep, err := invoke(scope, pred, v)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isTruthy(keep) {
+			res = res.Disj(v)
+		}
+	}
+	return res, nil
+}
+
+// index groups the maps of xrel by their values of the keys. It returns a
+// map from the maps of those keys and values to the set of maps having
+// them.
+func index(xrel Seq, keys Seq) (*HashMap, error) {
+	ks := realize(keys).Values
+	res := NewHashMap()
+
+	for _, v := range realize(xrel).Values {
+		m, ok := v.(*HashMap)
+		if !ok {
+			return nil, TypeError{
+				Expected: NewHashMap(),
+				Got:      v,
+			}
+		}
+
+		var key Value = NewHashMap()
+		for _, k := range ks {
+			if kv, found := m.Data.Index(k); found {
+				key = key.(*HashMap).Set(k, kv.(Value))
+			}
+		}
+
+		group, ok := res.Get(key).(*Set)
+		if !ok {
+			group = NewSet()
+		}
+		res = res.Set(key, group.Conj(m)).(*HashMap)
+	}
+
+	return res, nil
+}
+
+// disj returns the set without the values.
+func disj(set Value, vals ...Value) (Value, error) {
+	switch s := set.(type) {
+	case *Set:
+		return s.Disj(vals...), nil
+
+	case *SortedSet:
+		return s.Disj(vals...)
+	}
+
+	return nil, TypeError{
+		Expected: NewSet(),
+		Got:      set,
+	}
+}
+
+// contains returns true if the key is a member of a set, a key of a map or
+// an index of a vector.
+func contains(coll Value, key Value) (bool, error) {
+	switch c := coll.(type) {
+	case *Set:
+		return c.Contains(key), nil
+
+	case *SortedSet:
+		n, err := c.tree.find(key)
+		return n != nil, err
+
+	case *HashMap:
+		_, found := c.Data.Index(key)
+		return found, nil
+
+	case *SortedMap:
+		n, err := c.tree.find(key)
+		return n != nil, err
+
+	case *Vector:
+		i, ok := toIndex(key)
+		return ok && i >= 0 && i < c.Size(), nil
+
+	case Nil:
+		return false, nil
+	}
+
+	return false, TypeError{
+		Expected: NewSet(),
+		Got:      coll,
+	}
+}
@@ -0,0 +1,994 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortedMap is a persistent map which keeps its entries sorted by key. The
+// keys are ordered by `compare` unless the map is created with a
+// comparator by `sorted-map-by`.
+type SortedMap struct {
+	Position
+	tree rbTree
+	hash uint32
+}
+
+// NewSortedMap returns an empty map sorted by the comparator, or by
+// `compare` if it is nil.
+func NewSortedMap(cmp Comparator) *SortedMap {
+	return &SortedMap{tree: rbTree{cmp: orCompare(cmp)}}
+}
+
+// Eval returns the map itself, sorted maps have no literal form.
+func (sm *SortedMap) Eval(_ Scope) (Value, error) { return sm, nil }
+
+func (sm *SortedMap) String() string {
+	var str strings.Builder
+	str.WriteRune('{')
+	for it, i := sm.tree.iter(true), 0; ; i++ {
+		n := it.next()
+		if n == nil {
+			break
+		}
+
+		if i != 0 {
+			str.WriteString(", ")
+		}
+		fmt.Fprintf(&str, "%v %v", n.key, n.val)
+	}
+	str.WriteRune('}')
+	return str.String()
+}
+
+// Size returns the number of entries.
+func (sm *SortedMap) Size() int { return sm.tree.size }
+
+// First returns the entry with the least key as a vector of the key and
+// the value.
+func (sm *SortedMap) First() Value {
+	n := sm.tree.min()
+	if n == nil {
+		return nil
+	}
+	return entryOf(n)
+}
+
+// Next returns the map without the entry with the least key.
+func (sm *SortedMap) Next() Seq {
+	if sm.Size() < 2 {
+		return nil
+	}
+	return &SortedMap{tree: sm.tree.removeMin()}
+}
+
+// Cons adds the entry given as a vector of a key and a value.
+func (sm *SortedMap) Cons(v Value) Seq {
+	vec, ok := v.(*Vector)
+	if !ok || vec.Size() != 2 {
+		return sm
+	}
+	return sm.Set(vec.Index(0), vec.Index(1)).(Seq)
+}
+
+func (sm *SortedMap) Conj(vals ...Value) Seq {
+	var m Seq = sm
+	for _, v := range vals {
+		m = m.Cons(v)
+	}
+	return m
+}
+
+// Set returns a new map with the key mapped to the value. A comparator
+// failing on the key is raised as the error of the evaluation.
+func (sm *SortedMap) Set(k, v Value) Value {
+	tree, err := sm.tree.insert(k, v)
+	if err != nil {
+		panic(seqPanic{err: err})
+	}
+	return &SortedMap{Position: sm.Position, tree: tree}
+}
+
+// Get returns the value mapped under the key, nil if there is none.
+func (sm *SortedMap) Get(k Value) Value {
+	v, _ := sm.lookup(k)
+	return v
+}
+
+// Delete returns a new map without the key.
+func (sm *SortedMap) Delete(k Value) *SortedMap {
+	tree, err := sm.tree.remove(k)
+	if err != nil {
+		panic(seqPanic{err: err})
+	}
+	return &SortedMap{Position: sm.Position, tree: tree}
+}
+
+// Empty returns an empty map with the same comparator.
+func (sm *SortedMap) Empty() *SortedMap {
+	return &SortedMap{tree: rbTree{cmp: sm.tree.cmp}}
+}
+
+func (sm *SortedMap) Invoke(scope Scope, args ...Value) (Value, error) {
+	vals, err := EvalValueList(scope, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return sm.invokeValues(scope, vals)
+}
+
+// invokeValues is like Invoke but the arguments are already evaluated.
+func (sm *SortedMap) invokeValues(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+		return nil, err
+	}
+
+	n, err := sm.tree.find(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if n != nil {
+		return n.val, nil
+	} else if len(args) == 2 {
+		return args[1], nil
+	}
+	return Nil{}, nil
+}
+
+// Compare returns true if the other value is a map with the same entries,
+// regardless of their order.
+func (sm *SortedMap) Compare(other Value) bool {
+	return compareMaps(sm, other)
+}
+
+// Hash returns the hash of the entries, the same as a hash map of them.
+func (sm *SortedMap) Hash() uint32 {
+	return cachedHash(&sm.hash, func() uint32 { return hashEntries(sm) })
+}
+
+func (sm *SortedMap) lookup(k Value) (Value, bool) {
+	n, err := sm.tree.find(k)
+	if err != nil || n == nil {
+		return nil, false
+	}
+	return n.val, true
+}
+
+func (sm *SortedMap) entries() []Value {
+	var vals []Value
+	for it := sm.tree.iter(true); ; {
+		n := it.next()
+		if n == nil {
+			return vals
+		}
+		vals = append(vals, entryOf(n))
+	}
+}
+
+// SortedSet is a persistent set which keeps its members sorted. The
+// members are ordered by `compare` unless the set is created with a
+// comparator by `sorted-set-by`.
+type SortedSet struct {
+	Position
+	tree rbTree
+	hash uint32
+}
+
+// NewSortedSet returns an empty set sorted by the comparator, or by
+// `compare` if it is nil.
+func NewSortedSet(cmp Comparator) *SortedSet {
+	return &SortedSet{tree: rbTree{cmp: orCompare(cmp)}}
+}
+
+// Eval returns the set itself, sorted sets have no literal form.
+func (ss *SortedSet) Eval(_ Scope) (Value, error) { return ss, nil }
+
+func (ss *SortedSet) String() string {
+	return containerString(ss.values(), "#{", "}", " ")
+}
+
+// Size returns the number of members.
+func (ss *SortedSet) Size() int { return ss.tree.size }
+
+// First returns the least member.
+func (ss *SortedSet) First() Value {
+	n := ss.tree.min()
+	if n == nil {
+		return nil
+	}
+	return n.key
+}
+
+// Next returns the set without its least member.
+func (ss *SortedSet) Next() Seq {
+	if ss.Size() < 2 {
+		return nil
+	}
+	return &SortedSet{tree: ss.tree.removeMin()}
+}
+
+func (ss *SortedSet) Cons(v Value) Seq {
+	return ss.Conj(v)
+}
+
+// Conj returns a new set with the values as members. A comparator failing
+// on a value is raised as the error of the evaluation.
+func (ss *SortedSet) Conj(vals ...Value) Seq {
+	tree := ss.tree
+	for _, v := range vals {
+		var err error
+		if tree, err = tree.insert(v, v); err != nil {
+			panic(seqPanic{err: err})
+		}
+	}
+	return &SortedSet{Position: ss.Position, tree: tree}
+}
+
+// Disj returns a new set without the values.
+func (ss *SortedSet) Disj(vals ...Value) (*SortedSet, error) {
+	tree := ss.tree
+	for _, v := range vals {
+		var err error
+		if tree, err = tree.remove(v); err != nil {
+			return nil, err
+		}
+	}
+	return &SortedSet{Position: ss.Position, tree: tree}, nil
+}
+
+// Contains returns true if v is a member of the set.
+func (ss *SortedSet) Contains(v Value) bool {
+	n, err := ss.tree.find(v)
+	return err == nil && n != nil
+}
+
+// Empty returns an empty set with the same comparator.
+func (ss *SortedSet) Empty() *SortedSet {
+	return &SortedSet{tree: rbTree{cmp: ss.tree.cmp}}
+}
+
+// Invoke returns the member equal to the argument, or the default value
+// if it is not a member.
+func (ss *SortedSet) Invoke(scope Scope, args ...Value) (Value, error) {
+	vals, err := EvalValueList(scope, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.invokeValues(scope, vals)
+}
+
+// invokeValues is like Invoke but the arguments are already evaluated.
+func (ss *SortedSet) invokeValues(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+		return nil, err
+	}
+
+	n, err := ss.tree.find(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if n != nil {
+		return n.key, nil
+	} else if len(args) == 2 {
+		return args[1], nil
+	}
+	return Nil{}, nil
+}
+
+// Compare returns true if the other value is a set with the same members,
+// regardless of their order.
+func (ss *SortedSet) Compare(other Value) bool {
+	return compareSets(ss, other)
+}
+
+// Hash returns the hash of the members, the same as a hash set of them.
+func (ss *SortedSet) Hash() uint32 {
+	return cachedHash(&ss.hash, func() uint32 { return hashUnordered(ss.values()) })
+}
+
+func (ss *SortedSet) values() []Value {
+	var vals []Value
+	for it := ss.tree.iter(true); ; {
+		n := it.next()
+		if n == nil {
+			return vals
+		}
+		vals = append(vals, n.key)
+	}
+}
+
+// Comparator returns a negative number, zero or a positive number when a
+// is less than, equal to or greater than b.
+type Comparator func(a, b Value) (int, error)
+
+func orCompare(cmp Comparator) Comparator {
+	if cmp == nil {
+		return compareValues
+	}
+	return cmp
+}
+
+// fnComparator returns a comparator calling the function. The function
+// returns a number like `compare`, or true if its first argument comes
+// before the second like `<`.
+func fnComparator(scope Scope, f Value) Comparator {
+	return func(a, b Value) (int, error) {
+		res, err := invoke(scope, f, a, b)
+		if err != nil {
+			return 0, err
+		}
+
+		if isNumber(res) {
+			c, _, err := compareNums(res, Int(0))
+			return c, err
+		}
+
+		if isTruthy(res) {
+			return -1, nil
+		}
+
+		res, err = invoke(scope, f, b, a)
+		if err != nil {
+			return 0, err
+		} else if isTruthy(res) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+}
+
+// compareValues orders numbers, strings, characters, keywords, symbols,
+// booleans and vectors of those. Nil comes before any other value, shorter
+// vectors before longer ones.
+func compareValues(a, b Value) (int, error) {
+	if isNumber(a) && isNumber(b) {
+		c, ordered, err := compareNums(a, b)
+		if err == nil && !ordered {
+			err = ArithmeticError{Reason: fmt.Sprintf("%v and %v are not ordered", a, b)}
+		}
+		return c, err
+	}
+
+	aNil, bNil := a == nil || a == (Nil{}), b == nil || b == (Nil{})
+	switch {
+	case aNil && bNil:
+		return 0, nil
+	case aNil:
+		return -1, nil
+	case bNil:
+		return 1, nil
+	}
+
+	switch x := a.(type) {
+	case String:
+		if y, ok := b.(String); ok {
+			return strings.Compare(string(x), string(y)), nil
+		}
+
+	case Keyword:
+		if y, ok := b.(Keyword); ok {
+			return strings.Compare(string(x), string(y)), nil
+		}
+
+	case Symbol:
+		if y, ok := b.(Symbol); ok {
+			return strings.Compare(x.Value, y.Value), nil
+		}
+
+	case Character:
+		if y, ok := b.(Character); ok {
+			return int(x) - int(y), nil
+		}
+
+	case Bool:
+		if y, ok := b.(Bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !bool(x):
+				return -1, nil
+			}
+			return 1, nil
+		}
+
+	case *Vector:
+		if y, ok := b.(*Vector); ok {
+			return compareVectors(x, y)
+		}
+	}
+
+	return 0, TypeError{
+		Expected: a,
+		Got:      b,
+	}
+}
+
+func compareVectors(x, y *Vector) (int, error) {
+	if x.Size() != y.Size() {
+		return x.Size() - y.Size(), nil
+	}
+
+	for i := 0; i < x.Size(); i++ {
+		c, err := compareValues(x.Index(i), y.Index(i))
+		if err != nil || c != 0 {
+			return c, err
+		}
+	}
+	return 0, nil
+}
+
+// compareFn is the `compare` function, it returns -1, 0 or 1.
+func compareFn(a, b Value) (Int, error) {
+	c, err := compareValues(a, b)
+	switch {
+	case err != nil:
+		return 0, err
+	case c < 0:
+		return -1, nil
+	case c > 0:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func sortedMap(scope Scope, args []Value) (Value, error) {
+	return sortedMapOf(NewSortedMap(nil), args)
+}
+
+func sortedMapBy(scope Scope, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, ArgumentError{Fn: "sorted-map-by", Got: 0}
+	}
+	return sortedMapOf(NewSortedMap(fnComparator(scope, args[0])), args[1:])
+}
+
+func sortedMapOf(sm *SortedMap, kvs []Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+
+	if len(kvs)%2 != 0 {
+		return nil, fmt.Errorf("sorted-map requires an even number of forms")
+	}
+
+	for i := 0; i < len(kvs); i += 2 {
+		sm = sm.Set(kvs[i], kvs[i+1]).(*SortedMap)
+	}
+	return sm, nil
+}
+
+func sortedSet(scope Scope, args []Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+	return NewSortedSet(nil).Conj(args...), nil
+}
+
+func sortedSetBy(scope Scope, args []Value) (_ Value, err error) {
+	defer recoverSeq(&err)
+
+	if len(args) == 0 {
+		return nil, ArgumentError{Fn: "sorted-set-by", Got: 0}
+	}
+	return NewSortedSet(fnComparator(scope, args[0])).Conj(args[1:]...), nil
+}
+
+// subseq returns the entries of a sorted map, or the members of a sorted
+// set, whose keys pass the tests in ascending order. A test is one of `<`,
+// `<=`, `>` or `>=` and is called with the result of the comparator and 0.
+func subseq(scope Scope, args []Value) (Value, error) {
+	return rangeOf(scope, args, true)
+}
+
+// rsubseq is like subseq but returns the entries in descending order.
+func rsubseq(scope Scope, args []Value) (Value, error) {
+	return rangeOf(scope, args, false)
+}
+
+func rangeOf(scope Scope, args []Value, asc bool) (Value, error) {
+	if err := verifyArgCount([]int{3, 5}, args); err != nil {
+		return nil, err
+	}
+
+	var tree rbTree
+	var entry func(n *rbNode) Value
+	switch sc := args[0].(type) {
+	case *SortedMap:
+		tree, entry = sc.tree, entryOf
+
+	case *SortedSet:
+		tree, entry = sc.tree, func(n *rbNode) Value { return n.key }
+
+	default:
+		return nil, TypeError{
+			Expected: NewSortedMap(nil),
+			Got:      args[0],
+		}
+	}
+
+	// the bound the walk starts from and the bound it stops at
+	start, end := rangeTest{}, rangeTest{}
+	if len(args) == 5 {
+		start = rangeTest{scope: scope, tree: &tree, test: args[1], key: args[2]}
+		end = rangeTest{scope: scope, tree: &tree, test: args[3], key: args[4]}
+		if !asc {
+			start, end = end, start
+		}
+	} else {
+		bound := rangeTest{scope: scope, tree: &tree, test: args[1], key: args[2]}
+		above, err := bound.above()
+		if err != nil {
+			return nil, err
+		}
+
+		if above == asc {
+			start = bound
+		} else {
+			end = bound
+		}
+	}
+
+	it := tree.iter(asc)
+	if start.test != nil {
+		var err error
+		if it, err = tree.seek(start.key, asc); err != nil {
+			return nil, err
+		}
+	}
+
+	var vals []Value
+	for n := it.next(); n != nil; n = it.next() {
+		ok, err := start.includes(n)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+
+		if ok, err = end.includes(n); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+
+		vals = append(vals, entry(n))
+	}
+
+	return &List{Values: vals}, nil
+}
+
+// rangeTest is a bound of subseq and rsubseq.
+type rangeTest struct {
+	scope Scope
+	tree  *rbTree
+	test  Value
+	key   Value
+}
+
+// above returns true if the test includes the keys after its key.
+func (rt rangeTest) above() (bool, error) {
+	res, err := invoke(rt.scope, rt.test, Int(1), Int(0))
+	return isTruthy(res), err
+}
+
+func (rt rangeTest) includes(n *rbNode) (bool, error) {
+	if rt.test == nil {
+		return true, nil
+	}
+
+	c, err := rt.tree.cmp(n.key, rt.key)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := invoke(rt.scope, rt.test, Int(c), Int(0))
+	return isTruthy(res), err
+}
+
+func entryOf(n *rbNode) Value {
+	return NewVector().Conj(n.key, n.val)
+}
+
+// mapLike is implemented by HashMap and SortedMap.
+type mapLike interface {
+	Size() int
+	lookup(k Value) (Value, bool)
+	entries() []Value
+}
+
+func compareMaps(m Value, other Value) bool {
+	m1, ok1 := m.(mapLike)
+	m2, ok2 := other.(mapLike)
+	if !ok1 || !ok2 || m1.Size() != m2.Size() {
+		return false
+	}
+
+	for _, e := range m1.entries() {
+		entry := e.(*Vector)
+		v, found := m2.lookup(entry.Index(0))
+		if !found || !Compare(entry.Index(1), v) {
+			return false
+		}
+	}
+	return true
+}
+
+// setLike is implemented by Set and SortedSet.
+type setLike interface {
+	Size() int
+	Contains(v Value) bool
+	values() []Value
+}
+
+func compareSets(set Value, other Value) bool {
+	s1, ok1 := set.(setLike)
+	s2, ok2 := other.(setLike)
+	if !ok1 || !ok2 || s1.Size() != s2.Size() {
+		return false
+	}
+
+	for _, v := range s1.values() {
+		if !s2.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// rbTree is a persistent red-black tree. Insertion and deletion copy the
+// path to the changed node and share the rest with the original tree. The
+// balancing follows Kahrs, "Red-black trees with types".
+type rbTree struct {
+	root *rbNode
+	size int
+	cmp  Comparator
+}
+
+type rbNode struct {
+	key, val    Value
+	red         bool
+	left, right *rbNode
+}
+
+// find returns the node of the key, nil if there is none.
+func (t rbTree) find(k Value) (*rbNode, error) {
+	for n := t.root; n != nil; {
+		c, err := t.cmp(k, n.key)
+		switch {
+		case err != nil:
+			return nil, err
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+func (t rbTree) min() *rbNode {
+	n := t.root
+	for n != nil && n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+// insert returns a tree with the key mapped to the value. The key already
+// in the tree is kept if there is an equal one.
+func (t rbTree) insert(k, v Value) (rbTree, error) {
+	root, added, err := t.ins(t.root, k, v)
+	if err != nil {
+		return t, err
+	}
+
+	t.root = blacken(root)
+	if added {
+		t.size++
+	}
+	return t, nil
+}
+
+func (t rbTree) ins(n *rbNode, k, v Value) (*rbNode, bool, error) {
+	if n == nil {
+		return &rbNode{key: k, val: v, red: true}, true, nil
+	}
+
+	c, err := t.cmp(k, n.key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case c < 0:
+		l, added, err := t.ins(n.left, k, v)
+		if err != nil {
+			return nil, false, err
+		} else if n.red {
+			return mkNode(true, l, n.key, n.val, n.right), added, nil
+		}
+		return balance(l, n.key, n.val, n.right), added, nil
+
+	case c > 0:
+		r, added, err := t.ins(n.right, k, v)
+		if err != nil {
+			return nil, false, err
+		} else if n.red {
+			return mkNode(true, n.left, n.key, n.val, r), added, nil
+		}
+		return balance(n.left, n.key, n.val, r), added, nil
+	}
+
+	return mkNode(n.red, n.left, n.key, v, n.right), false, nil
+}
+
+// remove returns a tree without the key.
+func (t rbTree) remove(k Value) (rbTree, error) {
+	if n, err := t.find(k); err != nil || n == nil {
+		return t, err
+	}
+
+	root, err := t.del(t.root, k)
+	if err != nil {
+		return t, err
+	}
+
+	t.root = blacken(root)
+	t.size--
+	return t, nil
+}
+
+// removeMin returns the tree without its least key.
+func (t rbTree) removeMin() rbTree {
+	if t.root == nil {
+		return t
+	}
+
+	t.root = blacken(delMin(t.root))
+	t.size--
+	return t
+}
+
+func (t rbTree) del(n *rbNode, k Value) (*rbNode, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	c, err := t.cmp(k, n.key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c < 0:
+		l, err := t.del(n.left, k)
+		if err != nil {
+			return nil, err
+		}
+		return delLeft(n, l), nil
+
+	case c > 0:
+		r, err := t.del(n.right, k)
+		if err != nil {
+			return nil, err
+		}
+		return delRight(n, r), nil
+	}
+
+	return fuse(n.left, n.right), nil
+}
+
+func delMin(n *rbNode) *rbNode {
+	if n.left == nil {
+		return fuse(nil, n.right)
+	}
+	return delLeft(n, delMin(n.left))
+}
+
+// delLeft rebuilds n with l, its left child after a deletion.
+func delLeft(n, l *rbNode) *rbNode {
+	if isBlack(n.left) {
+		return balLeft(l, n.key, n.val, n.right)
+	}
+	return mkNode(true, l, n.key, n.val, n.right)
+}
+
+// delRight rebuilds n with r, its right child after a deletion.
+func delRight(n, r *rbNode) *rbNode {
+	if isBlack(n.right) {
+		return balRight(n.left, n.key, n.val, r)
+	}
+	return mkNode(true, n.left, n.key, n.val, r)
+}
+
+func mkNode(red bool, l *rbNode, k, v Value, r *rbNode) *rbNode {
+	return &rbNode{key: k, val: v, red: red, left: l, right: r}
+}
+
+func isRed(n *rbNode) bool { return n != nil && n.red }
+
+func isBlack(n *rbNode) bool { return n != nil && !n.red }
+
+func blacken(n *rbNode) *rbNode {
+	if !isRed(n) {
+		return n
+	}
+	return mkNode(false, n.left, n.key, n.val, n.right)
+}
+
+func redden(n *rbNode) *rbNode {
+	if !isBlack(n) {
+		panic("red-black tree invariant violated")
+	}
+	return mkNode(true, n.left, n.key, n.val, n.right)
+}
+
+func balance(l *rbNode, k, v Value, r *rbNode) *rbNode {
+	switch {
+	case isRed(l) && isRed(r):
+		return mkNode(true, blacken(l), k, v, blacken(r))
+
+	case isRed(l) && isRed(l.left):
+		return mkNode(true, blacken(l.left), l.key, l.val,
+			mkNode(false, l.right, k, v, r))
+
+	case isRed(l) && isRed(l.right):
+		lr := l.right
+		return mkNode(true, mkNode(false, l.left, l.key, l.val, lr.left),
+			lr.key, lr.val, mkNode(false, lr.right, k, v, r))
+
+	case isRed(r) && isRed(r.right):
+		return mkNode(true, mkNode(false, l, k, v, r.left), r.key, r.val,
+			blacken(r.right))
+
+	case isRed(r) && isRed(r.left):
+		rl := r.left
+		return mkNode(true, mkNode(false, l, k, v, rl.left), rl.key, rl.val,
+			mkNode(false, rl.right, r.key, r.val, r.right))
+	}
+
+	return mkNode(false, l, k, v, r)
+}
+
+func balLeft(l *rbNode, k, v Value, r *rbNode) *rbNode {
+	switch {
+	case isRed(l):
+		return mkNode(true, blacken(l), k, v, r)
+
+	case isBlack(r):
+		return balance(l, k, v, redden(r))
+
+	case isRed(r) && isBlack(r.left):
+		rl := r.left
+		return mkNode(true, mkNode(false, l, k, v, rl.left), rl.key, rl.val,
+			balance(rl.right, r.key, r.val, redden(r.right)))
+	}
+
+	panic("red-black tree invariant violated")
+}
+
+func balRight(l *rbNode, k, v Value, r *rbNode) *rbNode {
+	switch {
+	case isRed(r):
+		return mkNode(true, l, k, v, blacken(r))
+
+	case isBlack(l):
+		return balance(redden(l), k, v, r)
+
+	case isRed(l) && isBlack(l.right):
+		lr := l.right
+		return mkNode(true, balance(redden(l.left), l.key, l.val, lr.left),
+			lr.key, lr.val, mkNode(false, lr.right, k, v, r))
+	}
+
+	panic("red-black tree invariant violated")
+}
+
+// fuse joins the children of a deleted node.
+func fuse(l, r *rbNode) *rbNode {
+	switch {
+	case l == nil:
+		return r
+
+	case r == nil:
+		return l
+
+	case l.red && r.red:
+		m := fuse(l.right, r.left)
+		if isRed(m) {
+			return mkNode(true, mkNode(true, l.left, l.key, l.val, m.left),
+				m.key, m.val, mkNode(true, m.right, r.key, r.val, r.right))
+		}
+		return mkNode(true, l.left, l.key, l.val,
+			mkNode(true, m, r.key, r.val, r.right))
+
+	case !l.red && !r.red:
+		m := fuse(l.right, r.left)
+		if isRed(m) {
+			return mkNode(true, mkNode(false, l.left, l.key, l.val, m.left),
+				m.key, m.val, mkNode(false, m.right, r.key, r.val, r.right))
+		}
+		return balLeft(l.left, l.key, l.val,
+			mkNode(false, m, r.key, r.val, r.right))
+
+	case r.red:
+		return mkNode(true, fuse(l, r.left), r.key, r.val, r.right)
+	}
+
+	return mkNode(true, l.left, l.key, l.val, fuse(l.right, r))
+}
+
+// rbIter walks the nodes of a tree in order.
+type rbIter struct {
+	stack []*rbNode
+	asc   bool
+}
+
+// iter returns an iterator from the least key, or the greatest key if asc
+// is false.
+func (t rbTree) iter(asc bool) *rbIter {
+	it := &rbIter{asc: asc}
+	it.push(t.root)
+	return it
+}
+
+// seek returns an iterator from the first key not before k in the order of
+// the walk.
+func (t rbTree) seek(k Value, asc bool) (*rbIter, error) {
+	it := &rbIter{asc: asc}
+	for n := t.root; n != nil; {
+		c, err := t.cmp(n.key, k)
+		if err != nil {
+			return nil, err
+		}
+
+		if !asc {
+			c = -c
+		}
+
+		if c >= 0 {
+			it.stack = append(it.stack, n)
+			n = it.toward(n)
+		} else {
+			n = it.away(n)
+		}
+	}
+	return it, nil
+}
+
+func (it *rbIter) next() *rbNode {
+	if len(it.stack) == 0 {
+		return nil
+	}
+
+	n := it.stack[len(it.stack)-1]
+	it.stack = it.stack[:len(it.stack)-1]
+	it.push(it.away(n))
+	return n
+}
+
+// push adds the path to the first node of the subtree in the order of the
+// walk.
+func (it *rbIter) push(n *rbNode) {
+	for ; n != nil; n = it.toward(n) {
+		it.stack = append(it.stack, n)
+	}
+}
+
+// toward returns the child on the side the walk starts from.
+func (it *rbIter) toward(n *rbNode) *rbNode {
+	if it.asc {
+		return n.left
+	}
+	return n.right
+}
+
+// away returns the child on the side the walk ends at.
+func (it *rbIter) away(n *rbNode) *rbNode {
+	if it.asc {
+		return n.right
+	}
+	return n.left
+}
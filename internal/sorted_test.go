@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestRbTree_Invariants(t *testing.T) {
+	t.Parallel()
+
+	rnd := rand.New(rand.NewSource(1))
+	tree := rbTree{cmp: compareValues}
+	want := map[Int]bool{}
+	var keys []Int
+
+	for i := 0; i < 2000; i++ {
+		k := Int(rnd.Intn(300))
+		before, beforeKeys := tree, keys
+
+		var err error
+		switch rnd.Intn(6) {
+		case 0, 1:
+			tree, err = tree.remove(k)
+			delete(want, k)
+
+		case 2:
+			if len(keys) > 0 {
+				tree = tree.removeMin()
+				delete(want, keys[0])
+			}
+
+		default:
+			tree, err = tree.insert(k, k)
+			want[k] = true
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if fmt.Sprint(treeKeys(before)) != fmt.Sprint(beforeKeys) {
+			t.Fatalf("update changed the previous version of the tree")
+		}
+
+		if isRed(tree.root) {
+			t.Fatalf("root is red")
+		}
+		blackHeight(t, tree.root)
+
+		keys = treeKeys(tree)
+
+		if len(keys) != len(want) || tree.size != len(want) {
+			t.Fatalf("size = %d with %d keys, want %d", tree.size, len(keys), len(want))
+		}
+
+		for j := range keys {
+			if !want[keys[j]] || (j > 0 && keys[j-1] >= keys[j]) {
+				t.Fatalf("keys out of order or unexpected: %v", keys)
+			}
+		}
+	}
+}
+
+func TestRbTree_Seek(t *testing.T) {
+	t.Parallel()
+
+	tree := rbTree{cmp: compareValues}
+	for _, k := range []Int{10, 20, 30, 40} {
+		tree, _ = tree.insert(k, k)
+	}
+
+	table := []struct {
+		key  Int
+		asc  bool
+		want []Int
+	}{
+		{key: 20, asc: true, want: []Int{20, 30, 40}},
+		{key: 25, asc: true, want: []Int{30, 40}},
+		{key: 45, asc: true, want: nil},
+		{key: 30, asc: false, want: []Int{30, 20, 10}},
+		{key: 25, asc: false, want: []Int{20, 10}},
+		{key: 5, asc: false, want: nil},
+	}
+
+	for _, tt := range table {
+		it, err := tree.seek(tt.key, tt.asc)
+		if err != nil {
+			t.Fatalf("seek() unexpected error: %v", err)
+		}
+
+		var got []Int
+		for n := it.next(); n != nil; n = it.next() {
+			got = append(got, n.key.(Int))
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("seek(%v, %v) got = %v, want %v", tt.key, tt.asc, got, tt.want)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("seek(%v, %v) got = %v, want %v", tt.key, tt.asc, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func treeKeys(tree rbTree) []Int {
+	var keys []Int
+	for it := tree.iter(true); ; {
+		n := it.next()
+		if n == nil {
+			return keys
+		}
+		keys = append(keys, n.key.(Int))
+	}
+}
+
+// blackHeight returns the number of black nodes on every path from n to a
+// leaf, failing the test if a red node has a red child or the paths
+// differ.
+func blackHeight(t *testing.T, n *rbNode) int {
+	if n == nil {
+		return 0
+	}
+
+	if n.red && (isRed(n.left) || isRed(n.right)) {
+		t.Fatalf("red node %v has a red child", n.key)
+	}
+
+	l, r := blackHeight(t, n.left), blackHeight(t, n.right)
+	if l != r {
+		t.Fatalf("black height of %v differs, %d and %d", n.key, l, r)
+	}
+
+	if n.red {
+		return l
+	}
+	return l + 1
+}
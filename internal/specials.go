@@ -10,59 +10,77 @@ import (
 var (
 	// Def implements (def symbol value) form for defining bindings.
 	Def = SpecialForm{
-		Name:  "def",
-		Parse: parseDef,
+		Name:    "def",
+		Parse:   parseDef,
+		compile: compileDef,
 	}
 
 	// Lambda defines an anonymous function and returns. Must have the form
 	// (fn* name? [arg*] expr*) or (fn* name? ([arg]* expr*)+)
 	Lambda = SpecialForm{
-		Name:  "fn*",
-		Parse: fnParser(false),
+		Name:    "fn*",
+		Parse:   fnParser(false),
+		compile: fnCompiler(false),
 	}
 
 	// Macro defines an anonymous function and returns. Must have the form
 	// (macro* name? [arg*] expr*) or (fn* name? ([arg]* expr*)+)
 	Macro = SpecialForm{
-		Name:  "macro*",
-		Parse: fnParser(true),
+		Name:    "macro*",
+		Parse:   fnParser(true),
+		compile: fnCompiler(true),
 	}
 
 	// Let implements the (let [binding*] expr*) form. expr are evaluated
 	// with given local bindings.
 	Let = SpecialForm{
-		Name:  "let",
-		Parse: parseLet,
+		Name:    "let",
+		Parse:   parseLet,
+		compile: compileLet,
 	}
 
 	// Do special form evaluates args one by one and returns the result of
 	// the last expr.
 	Do = SpecialForm{
-		Name:  "do",
-		Parse: parseDo,
+		Name:    "do",
+		Parse:   parseDo,
+		compile: compileDo,
 	}
 
 	// If implements if-conditional flow using (if test then else?) form.
 	If = SpecialForm{
-		Name:  "if",
-		Parse: parseIf,
+		Name:    "if",
+		Parse:   parseIf,
+		compile: compileIf,
 	}
 
 	Try = SpecialForm{
-		Name:  "try",
-		Parse: parseTry,
+		Name:    "try",
+		Parse:   parseTry,
+		compile: compileTry,
+	}
+
+	// Recur implements (recur expr*) which evaluates the expressions and
+	// rebinds the enclosing loop or function to the values, without
+	// growing the stack. It can only be used in tail position.
+	Recur = SpecialForm{
+		Name:    "recur",
+		Parse:   parseRecur,
+		compile: compileRecur,
 	}
 
 	// SimpleQuote prevents a form from being evaluated.
 	SimpleQuote = SpecialForm{
-		Name:  "quote",
-		Parse: parseSimpleQuote,
+		Name:    "quote",
+		Parse:   parseSimpleQuote,
+		compile: compileSimpleQuote,
 	}
 
 	// SyntaxQuote recursively applies the quoting to the form.
 	SyntaxQuote = SpecialForm{
-		Name:  "syntax-quote",
-		Parse: parseSyntaxQuote,
+		Name:    "syntax-quote",
+		Parse:   parseSyntaxQuote,
+		compile: compileSyntaxQuote,
 	}
 )
 
@@ -121,36 +139,9 @@ func fnParser(isMacro bool) func(scope Scope, forms []Value) (*Fn, error) {
 }
 
 func parseLet(scope Scope, args []Value) (*Fn, error) {
-	if len(args) < 1 {
-		return nil, fmt.Errorf("call requires at-least bindings argument")
-	}
-
-	vec, isVector := args[0].(*Vector)
-	if !isVector {
-		return nil, fmt.Errorf(
-			"first argument to let must be bindings vector, not %v",
-			reflect.TypeOf(args[0]),
-		)
-	}
-
-	if vec.Size()%2 != 0 {
-		return nil, fmt.Errorf("bindings must contain event forms")
-	}
-
-	var bindings []binding
-	for i := 0; i < vec.Size(); i += 2 {
-		sym, isSymbol := vec.Index(i).(Symbol)
-		if !isSymbol {
-			return nil, fmt.Errorf(
-				"item at %d must be symbol, not %s",
-				i, vec.Index(i),
-			)
-		}
-
-		bindings = append(bindings, binding{
-			Name: sym.Value,
-			Expr: vec.Index(i + 1),
-		})
+	bindings, err := readBindings(args)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Fn{
@@ -184,20 +175,52 @@ func parseDo(scope Scope, args []Value) (*Fn, error) {
 	}, nil
 }
 
+func parseRecur(scope Scope, args []Value) (*Fn, error) {
+	return &Fn{
+		Func: func(scope Scope, args []Value) (Value, error) {
+			vals, err := EvalValueList(scope, args)
+			if err != nil {
+				return nil, err
+			}
+			return &recurSignal{args: vals}, nil
+		},
+	}, nil
+}
+
 func parseTry(scope Scope, args []Value) (*Fn, error) {
-	if err := verifyArgCount([]int{1, 2}, args); err != nil {
+	tf, err := readTry(args)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := analyze(scope, args[0]); err != nil {
-		return nil, err
+	for _, form := range tf.Body {
+		if err := analyze(scope, form); err != nil {
+			return nil, err
+		}
+	}
+
+	if !tf.legacy() {
+		return &Fn{
+			Func: func(scope Scope, _ []Value) (Value, error) {
+				return evalTry(scope, tf)
+			},
+		}, nil
 	}
 
 	return &Fn{
 		Func: func(scope Scope, args []Value) (Value, error) {
+			stack := executionOf(scope).stack
+			depth := stack.Size()
+
 			tryBlock, tryErr := args[0].Eval(scope)
 
+			if isAbort(tryErr) {
+				return nil, tryErr
+			}
+
 			if tryErr != nil {
+				stack.unwind(depth)
+
 				if len(args) < 2 {
 					return ValueOf(nil), nil
 				}
@@ -221,6 +244,33 @@ func parseTry(scope Scope, args []Value) (*Fn, error) {
 	}, nil
 }
 
+// evalTry evaluates the body of the try, catches the error with the first
+// matching catch clause and finally evaluates the finally clause.
+func evalTry(scope Scope, tf *tryForm) (Value, error) {
+	stack := executionOf(scope).stack
+	depth := stack.Size()
+
+	v, err := EvalValueLast(scope, tf.Body)
+	if err != nil {
+		if i, caught := tf.catch(err); caught {
+			stack.unwind(depth)
+			cc := tf.Catches[i]
+
+			catchScope := NewScope(scope)
+			_ = catchScope.Bind(cc.Name, ValueOf(err))
+			v, err = EvalValueLast(catchScope, cc.Body)
+		}
+	}
+
+	if tf.Finally != nil {
+		if _, ferr := EvalValueLast(scope, tf.Finally); ferr != nil {
+			return nil, ferr
+		}
+	}
+
+	return v, err
+}
+
 func parseDef(scope Scope, forms []Value) (*Fn, error) {
 	if err := verifyArgCount([]int{2}, forms); err != nil {
 		return nil, err
@@ -316,6 +366,10 @@ func parseSyntaxQuote(scope Scope, forms []Value) (*Fn, error) {
 type SpecialForm struct {
 	Name  string
 	Parse func(scope Scope, args []Value) (*Fn, error)
+
+	// compile turns the arguments into code. Special forms without it are
+	// parsed on every evaluation.
+	compile func(c *compiler, args []Value) (code, error)
 }
 
 // Eval always returns error since it is not allowed to directly evaluate
@@ -401,17 +455,15 @@ func recursiveQuote(scope Scope, f Value) (Value, error) {
 				result = append(result, listVal)
 			}
 		}
-		v.Values = result
-
-		quoted, err := quoteSeq(scope, v.Values)
+		quoted, err := quoteSeq(scope, Values(result))
 		if err != nil {
 			return nil, err
 		}
 		return &List{Values: quoted}, nil
 
-	case Set:
-		quoted, err := quoteSeq(scope, v.Values)
-		return Set{Values: quoted}, err
+	case *Set:
+		quoted, err := quoteSeq(scope, Values(v.values()))
+		return NewSet().Conj(quoted...), err
 
 	case *Vector:
 		quoted, err := quoteSeq(scope, v.SubVector(0, v.Size()))
@@ -568,6 +620,7 @@ func makeFn(scope Scope, spec []Value) (*Fn, error) {
 		return nil, fmt.Errorf("insufficient args (%d) for 'fn'", len(spec))
 	}
 
+	spec = unpackParams(spec)
 	body := Module(spec[1:])
 	if err := analyze(scope, body); err != nil {
 		return nil, err
@@ -586,6 +639,179 @@ type binding struct {
 	Expr Value
 }
 
+// readBindings reads the bindings vector of let and loop forms. Patterns
+// are expanded into the bindings of the symbols they destructure.
+func readBindings(args []Value) ([]binding, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("call requires at-least bindings argument")
+	}
+
+	vec, isVector := args[0].(*Vector)
+	if !isVector {
+		return nil, fmt.Errorf(
+			"first argument to let must be bindings vector, not %v",
+			reflect.TypeOf(args[0]),
+		)
+	}
+
+	if vec.Size()%2 != 0 {
+		return nil, fmt.Errorf("bindings must contain even forms")
+	}
+
+	var bindings []binding
+	for i := 0; i < vec.Size(); i += 2 {
+		expanded, err := destructure(vec.Index(i), vec.Index(i+1))
+		if err != nil {
+			return nil, fmt.Errorf("item at %d: %w", i, err)
+		}
+		bindings = append(bindings, expanded...)
+	}
+
+	return bindings, nil
+}
+
+// tryForm is a try form split into its body and clauses. A try without
+// catch and finally clauses passes the error to the handler fn, if any.
+type tryForm struct {
+	Body    []Value
+	Handler Value
+	Catches []catchClause
+	Finally []Value
+}
+
+// catchClause catches the errors it matches. The error is bound to Name
+// while Body is evaluated.
+type catchClause struct {
+	Match func(err error) bool
+	Name  string
+	Body  []Value
+}
+
+// readTry reads the clauses of a try form:
+//
+//	(try body* (catch kind name body*)* (finally body*)?)
+//
+// kind is either the keyword of the exceptions to catch, `:default` to
+// catch any error, or the name of one of the error kinds.
+func readTry(args []Value) (*tryForm, error) {
+	tf := &tryForm{}
+
+	first := len(args)
+	for i, arg := range args {
+		if clauseName(arg) != "" {
+			first = i
+			break
+		}
+	}
+	tf.Body = args[:first]
+
+	if first == len(args) {
+		if err := verifyArgCount([]int{1, 2}, args); err != nil {
+			return nil, err
+		}
+
+		tf.Body = args[:1]
+		if len(args) == 2 {
+			tf.Handler = args[1]
+		}
+		return tf, nil
+	}
+
+	for i, arg := range args[first:] {
+		clause := arg.(*List)
+
+		switch clauseName(arg) {
+		case "catch":
+			cc, err := readCatch(clause.Values[1:])
+			if err != nil {
+				return nil, err
+			}
+			tf.Catches = append(tf.Catches, *cc)
+
+		case "finally":
+			if first+i != len(args)-1 {
+				return nil, fmt.Errorf("finally must be the last clause of try")
+			}
+			tf.Finally = clause.Values[1:]
+
+		default:
+			return nil, fmt.Errorf("expected catch or finally clause, got %v", arg)
+		}
+	}
+
+	return tf, nil
+}
+
+func readCatch(args []Value) (*catchClause, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("catch requires error kind and name")
+	}
+
+	name, isSymbol := args[1].(Symbol)
+	if !isSymbol {
+		return nil, fmt.Errorf("name of caught error must be symbol, not %v", args[1])
+	}
+
+	cc := &catchClause{Name: name.Value, Body: args[2:]}
+
+	switch kind := args[0].(type) {
+	case Keyword:
+		cc.Match = func(err error) bool {
+			return kind == "default" || bool(errorIs(kind, err))
+		}
+
+	case Symbol:
+		match, found := errorKinds[kind.Value]
+		if !found {
+			return nil, fmt.Errorf("unknown error kind '%s'", kind)
+		}
+		cc.Match = match
+
+	default:
+		return nil, fmt.Errorf("catch requires keyword or error kind, not %v", args[0])
+	}
+
+	return cc, nil
+}
+
+// clauseName returns the name of the catch or finally clause, an empty
+// string if the form is not a clause.
+func clauseName(form Value) string {
+	list, isList := form.(*List)
+	if !isList || list.Size() == 0 {
+		return ""
+	}
+
+	sym, isSymbol := list.Values[0].(Symbol)
+	if !isSymbol || (sym.Value != "catch" && sym.Value != "finally") {
+		return ""
+	}
+
+	return sym.Value
+}
+
+// legacy returns true if the try has no clauses and passes the error to
+// the handler fn instead.
+func (tf *tryForm) legacy() bool {
+	return len(tf.Catches) == 0 && tf.Finally == nil
+}
+
+// catch returns the clause catching the error. Errors aborting the
+// evaluation are never caught.
+func (tf *tryForm) catch(err error) (int, bool) {
+	if isAbort(err) {
+		return 0, false
+	}
+
+	for i, cc := range tf.Catches {
+		if cc.Match(err) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
 func accessClassMember(target reflect.Value, name string) (reflect.Value, error) {
 
 	object := target.Interface().(Object)
@@ -1,11 +1,14 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -18,7 +21,16 @@ var _ Scope = (*Spirit)(nil)
 // returns new Spirit instance
 func NewSpirit() *Spirit {
 	sl := &Spirit{
-		Bindings: map[nsSymbol]Value{},
+		Bindings:   map[nsSymbol]Value{},
+		namespaces: map[string]*Namespace{},
+	}
+	sl.main.stack = &sl.Stack
+	sl.top = &frame{
+		parent: sl,
+		outer:  sl,
+		global: true,
+		root:   sl,
+		exec:   &sl.main,
 	}
 
 	if err := bindAll(sl); err != nil {
@@ -27,22 +39,55 @@ func NewSpirit() *Spirit {
 	sl.checkNS = true
 
 	_ = sl.SwitchNS(Symbol{Value: defaultNS})
-	_ = sl.BindGo("ns", sl.SwitchNS)
 	return sl
 }
 
-// Spirit instance
+// Spirit instance. The bindings are safe for concurrent use, so futures
+// may call functions and define values while the instance is evaluating.
+// Stack holds the calls of the evaluations started on the instance itself,
+// every future keeps its own call stack.
 type Spirit struct {
 	Stack
+	main      execution
 	currentNS string
 	checkNS   bool
 	Bindings  map[nsSymbol]Value
-	Files     []string
+	// Files holds the canonical paths of the files read.
+	Files []string
+
+	// namespaces holds the aliases, referred names and private
+	// definitions of every namespace bound to.
+	namespaces map[string]*Namespace
+
+	// paths is the search path of imports, reading is the stack of the
+	// files being read.
+	paths   []string
+	reading []string
+
+	// tags holds the readers of tagged literals registered.
+	tags map[string]Value
+
+	// mu guards Bindings, Files, namespaces, paths, reading, currentNS and
+	// tags.
+	mu sync.RWMutex
+
+	sandbox      *Sandbox
+	steps        int64
+	capabilities map[string]Capability
+
+	// top is the frame compiled code runs in at the top level.
+	top *frame
+	// version changes whenever a binding changes, invalidating the cached
+	// global lookups of compiled code.
+	version uint64
+	// interpret evaluates lists by walking the forms instead of compiling
+	// them. It is only used to compare both strategies.
+	interpret bool
 }
 
 // Eval evaluates the given value in spirit context.
 func (s *Spirit) Eval(v Value) (Value, error) {
-	return Eval(s, v)
+	return notRecur(Eval(s, v))
 }
 
 // ReadEval reads from the given reader and evaluates all the forms
@@ -51,45 +96,58 @@ func (s *Spirit) ReadEval(r io.Reader) (Value, error) {
 	return ReadEval(s, r)
 }
 
-// ReadFile reads the content of the filename given. Use this to
-// prevent recursive source
-func (s *Spirit) ReadFile(filePath string) (Value, error) {
-
-	if s.FileImported(filePath) {
-		return nil, nil
-	}
+// EvalContext is like Eval but stops the evaluation once ctx is done.
+func (s *Spirit) EvalContext(ctx context.Context, v Value) (Value, error) {
+	return EvalContext(ctx, s, v)
+}
 
-	s.AddFile(filePath)
+// ReadEvalContext is like ReadEval but stops the evaluation once ctx is
+// done.
+func (s *Spirit) ReadEvalContext(ctx context.Context, r io.Reader) (Value, error) {
+	return ReadEvalContext(ctx, s, r)
+}
 
-	f, err := os.Open(filePath)
-	defer f.Close()
+// ReadFile reads the content of the filename given. Use this to
+// prevent recursive source. A relative path is resolved against the file
+// being read, or the working directory, and then the search path. See
+// AddPath(). Files are only read once, however their path is written. A
+// file which fails to be read is not recorded, so it can be read again.
+func (s *Spirit) ReadFile(filePath string) (_ Value, err error) {
+	path, err := s.findModule(filePath)
 	if err != nil {
 		return nil, ImportError{err}
 	}
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, OSError{err}
+	if !s.addFile(path) {
+		return nil, nil
 	}
+	defer func() {
+		if err != nil {
+			s.removeFile(path)
+		}
+	}()
 
-	dir := filepath.Dir(filePath)
-	s.BindGo("*cwd*", dir)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, ImportError{err}
+	}
+	defer f.Close()
 
-	os.Chdir(dir)
-	value, err := s.ReadEval(f)
+	restore, err := s.bindTemp("core/*cwd*", ValueOf(filepath.Dir(path)))
 	if err != nil {
 		return nil, err
 	}
-	os.Chdir(cwd)
+	defer restore()
 
-	return value, nil
+	s.pushReading(path)
+	defer s.popReading()
+
+	return s.ReadEval(f)
 }
 
 func (s *Spirit) Has(symbol string) bool {
-
-	if symbol == "ns" {
-		symbol = "user/ns"
-	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	nsSym, err := s.splitSymbol(symbol)
 	if err != nil {
@@ -102,10 +160,51 @@ func (s *Spirit) Has(symbol string) bool {
 
 // AddFile adds file to slice of imported files to prevent circular dependency.
 func (s *Spirit) AddFile(file string) {
+	if path, err := canonicalPath(file); err == nil {
+		file = path
+	}
+	s.addFile(file)
+}
+
+// addFile adds the file to the imported files unless it has been imported
+// already. Returns false if the file was already imported.
+func (s *Spirit) addFile(file string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fileImported(file) {
+		return false
+	}
+
 	s.Files = append(s.Files, file)
+	return true
+}
+
+// removeFile removes the file from the imported files.
+func (s *Spirit) removeFile(file string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, v := range s.Files {
+		if v == file {
+			s.Files = append(s.Files[:i], s.Files[i+1:]...)
+			return
+		}
+	}
 }
 
 func (s *Spirit) FileImported(file string) bool {
+	if path, err := canonicalPath(file); err == nil {
+		file = path
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fileImported(file)
+}
+
+func (s *Spirit) fileImported(file string) bool {
 	for _, v := range s.Files {
 		if v == file {
 			return true
@@ -122,6 +221,8 @@ func (s *Spirit) ReadEvalStr(src string) (Value, error) {
 // Bind binds the given name to the given Value into the spirit interpreter
 // context.
 func (s *Spirit) Bind(symbol string, v Value) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	nsSym, err := s.splitSymbol(symbol)
 	if err != nil {
@@ -132,24 +233,48 @@ func (s *Spirit) Bind(symbol string, v Value) error {
 		return fmt.Errorf("cannot bind outside current namespace")
 	}
 
+	s.namespace(nsSym.NS)
 	s.Bindings[*nsSym] = v
+	atomic.AddUint64(&s.version, 1)
 	return nil
 }
 
-// Resolve finds the value bound to the given symbol and returns it if
-// found in the spirit context and returns it.
-func (s *Spirit) Resolve(symbol string) (Value, error) {
-
-	if symbol == "ns" {
-		symbol = "user/ns"
-	}
+// bindTemp binds the symbol like Bind and returns a function restoring the
+// binding it had before, or removing it if there was none.
+func (s *Spirit) bindTemp(symbol string, v Value) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	nsSym, err := s.splitSymbol(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.resolveAny(symbol, *nsSym, nsSym.WithNS("core"))
+	s.namespace(nsSym.NS)
+	prev, found := s.Bindings[*nsSym]
+	s.Bindings[*nsSym] = v
+	atomic.AddUint64(&s.version, 1)
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if found {
+			s.Bindings[*nsSym] = prev
+		} else {
+			delete(s.Bindings, *nsSym)
+		}
+		atomic.AddUint64(&s.version, 1)
+	}, nil
+}
+
+// Resolve finds the value bound to the given symbol as seen from the
+// current namespace. See resolveIn() for the rules.
+func (s *Spirit) Resolve(symbol string) (Value, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.resolveIn(s.currentNS, symbol)
 }
 
 // BindGo is similar to Bind but handles conversion of Go value 'v' to
@@ -158,14 +283,25 @@ func (s *Spirit) BindGo(symbol string, v interface{}) error {
 	return s.Bind(symbol, ValueOf(v))
 }
 
-// SwitchNS changes the current namespace to the string value of given symbol.
+// SwitchNS changes the current namespace to the string value of given
+// symbol, creating the namespace if it does not exist. `*ns*` is bound in
+// core to the symbol of the current namespace.
 func (s *Spirit) SwitchNS(sym Symbol) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.currentNS = sym.String()
-	return s.Bind("*ns*", sym)
+	s.namespace(s.currentNS)
+	s.Bindings[nsSymbol{NS: "core", Name: "*ns*"}] = sym
+	atomic.AddUint64(&s.version, 1)
+	return nil
 }
 
 // CurrentNS returns the current active namespace.
 func (s *Spirit) CurrentNS() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	return s.currentNS
 }
 
@@ -185,11 +321,65 @@ func (s *Spirit) resolveAny(symbol string, syms ...nsSymbol) (Value, error) {
 	return nil, ResolveError{Sym: Symbol{Value: symbol}}
 }
 
+// globalRef is a reference from compiled code to a symbol which is not a
+// local. The last value the symbol resolved to in the root scope is cached
+// until the bindings change.
+type globalRef struct {
+	name string
+	// ns is the namespace the code was compiled in, the symbol is
+	// resolved as seen from it.
+	ns    string
+	entry atomic.Value
+}
+
+// resolveRef resolves the symbol of the reference in its namespace, or
+// in the current one if it has none.
+func (s *Spirit) resolveRef(ref *globalRef) (Value, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ns := ref.ns
+	if ns == "" {
+		ns = s.currentNS
+	}
+	return s.resolveIn(ns, ref.name)
+}
+
+type globalEntry struct {
+	spirit  *Spirit
+	version uint64
+	value   Value
+}
+
+func (s *Spirit) resolveGlobal(ref *globalRef) (Value, error) {
+	version := atomic.LoadUint64(&s.version)
+	if e, ok := ref.entry.Load().(globalEntry); ok &&
+		e.spirit == s && e.version == version {
+		return e.value, nil
+	}
+
+	v, err := s.resolveRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	ref.entry.Store(globalEntry{spirit: s, version: version, value: v})
+	return v, nil
+}
+
+// splitSymbol qualifies the symbol with its namespace. The caller must hold
+// the lock as the current namespace is used for unqualified symbols.
 func (s *Spirit) splitSymbol(symbol string) (*nsSymbol, error) {
+	return s.qualify(s.currentNS, symbol)
+}
+
+// qualify qualifies the symbol with the given namespace unless it names
+// its namespace.
+func (s *Spirit) qualify(ns, symbol string) (*nsSymbol, error) {
 	sep := string(nsSeparator)
 	if symbol == sep {
 		return &nsSymbol{
-			NS:   s.currentNS,
+			NS:   ns,
 			Name: symbol,
 		}, nil
 	}
@@ -197,7 +387,7 @@ func (s *Spirit) splitSymbol(symbol string) (*nsSymbol, error) {
 	parts := strings.SplitN(symbol, sep, 2)
 	if len(parts) < 2 {
 		return &nsSymbol{
-			NS:   s.currentNS,
+			NS:   ns,
 			Name: symbol,
 		}, nil
 	}
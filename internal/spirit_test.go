@@ -91,6 +91,314 @@ func TestSpirit_Resolve(t *testing.T) {
 	}
 }
 
+func TestSpirit_Namespaces(t *testing.T) {
+	sl := internal.NewSpirit()
+
+	src := `
+	(ns lib)
+	(def shown 1)
+	(def hidden 2)
+	(ns-private 'hidden)
+
+	(ns app (:require [lib :as l :refer [shown]]))`
+
+	if _, err := sl.ReadEval(strings.NewReader(src)); err != nil {
+		t.Fatalf("ReadEval() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		symbol  string
+		wantErr bool
+	}{
+		{
+			name:   "Referred",
+			symbol: "shown",
+		},
+		{
+			name:   "Alias",
+			symbol: "l/shown",
+		},
+		{
+			name:   "Qualified",
+			symbol: "lib/shown",
+		},
+		{
+			name:   "Core",
+			symbol: "impl?",
+		},
+		{
+			name:    "Private",
+			symbol:  "l/hidden",
+			wantErr: true,
+		},
+		{
+			name:    "NotReferred",
+			symbol:  "hidden",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sl.Resolve(tt.symbol)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %#v, wantErr %#v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpirit_ReadFile(t *testing.T) {
+	dir, lib := t.TempDir(), t.TempDir()
+
+	files := map[string]string{
+		filepath.Join(dir, "main.st"): `
+		(import "./counter.st")
+		(import "counter.st")
+		(import "sub/../counter.st")
+		(import "util.st")
+		(require 'app.greet)`,
+		filepath.Join(dir, "counter.st"): `(def counter (+ counter 1))`,
+		filepath.Join(lib, "util.st"):    `(def util :found)`,
+		filepath.Join(lib, "app", "greet.st"): `
+		(ns app.greet)
+		(def greeting :hello)`,
+	}
+
+	for file, src := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+
+	sl := internal.NewSpirit()
+	sl.AddPath(lib)
+	if err := sl.Bind("counter", internal.Number(0)); err != nil {
+		t.Fatalf("Bind() unexpected error: %v", err)
+	}
+
+	if _, err := sl.ReadFile(filepath.Join(dir, "main.st")); err != nil {
+		t.Fatalf("ReadFile() unexpected error: %v", err)
+	}
+
+	if got, _ := os.Getwd(); got != cwd {
+		t.Errorf("Getwd() got = %s, want %s", got, cwd)
+	}
+
+	want := map[string]internal.Value{
+		"counter":            internal.Number(1),
+		"util":               internal.Keyword("found"),
+		"app.greet/greeting": internal.Keyword("hello"),
+	}
+	for symbol, v := range want {
+		got, err := sl.Resolve(symbol)
+		if err != nil {
+			t.Errorf("Resolve(%s) unexpected error: %v", symbol, err)
+			continue
+		}
+		if !internal.Compare(got, v) {
+			t.Errorf("Resolve(%s) got = %v, want %v", symbol, got, v)
+		}
+	}
+
+	if _, err := sl.ReadFile("missing.st"); err == nil {
+		t.Errorf("ReadFile() expected error for missing module")
+	}
+}
+
+func TestSpirit_ReadFile_RestoresState(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		filepath.Join(dir, "main.st"): `
+		(import "sub/inner.st")
+		(require 'app.mod)
+		(def seen *cwd*)`,
+		filepath.Join(dir, "sub", "inner.st"): `(def inner *cwd*)`,
+		filepath.Join(dir, "app", "mod.st"): `
+		(ns app.mod)
+		(def cwd *cwd*)`,
+		filepath.Join(dir, "bad.st"): `
+		(ns bad)
+		(def half 1)
+		(undefined-fn)`,
+		filepath.Join(dir, "app", "broken.st"): `
+		(ns app.broken)
+		(def half 1)
+		(undefined-fn)`,
+	}
+
+	for file, src := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	sl := internal.NewSpirit()
+	sl.AddPath(dir)
+	ns := sl.CurrentNS()
+
+	if _, err := sl.ReadFile(filepath.Join(dir, "main.st")); err != nil {
+		t.Fatalf("ReadFile() unexpected error: %v", err)
+	}
+
+	for symbol, want := range map[string]string{
+		"seen":        dir,
+		"inner":       filepath.Join(dir, "sub"),
+		"app.mod/cwd": filepath.Join(dir, "app"),
+	} {
+		if got, err := sl.Resolve(symbol); err != nil || got != internal.String(want) {
+			t.Errorf("Resolve(%s) got = %v, want %s", symbol, got, want)
+		}
+	}
+
+	if got, err := sl.Resolve("*cwd*"); err == nil {
+		t.Errorf("Resolve(*cwd*) got = %v after ReadFile(), want unbound", got)
+	}
+
+	srcs := []string{`(require 'app.broken)`, `(import "bad.st")`}
+	for _, src := range srcs {
+		// a failed file is read again rather than taken as imported
+		for i := 0; i < 2; i++ {
+			if _, err := sl.ReadEvalStr(src); err == nil {
+				t.Errorf("ReadEvalStr(%s) expected error", src)
+			}
+			if got := sl.CurrentNS(); got != ns {
+				t.Errorf("CurrentNS() got = %s after %s, want %s", got, src, ns)
+			}
+		}
+	}
+
+	if got, err := sl.Resolve("app.broken/half"); err == nil {
+		t.Errorf("Resolve(app.broken/half) got = %v, want the failed namespace dropped", got)
+	}
+
+	for _, file := range []string{filepath.Join(dir, "bad.st"), filepath.Join(dir, "app", "broken.st")} {
+		src := strings.Replace(files[file], "(undefined-fn)", "(def whole 2)", 1)
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	for _, src := range srcs {
+		if _, err := sl.ReadEvalStr(src); err != nil {
+			t.Errorf("ReadEvalStr(%s) unexpected error after fixing the file: %v", src, err)
+		}
+	}
+
+	for _, symbol := range []string{"app.broken/whole", "bad/whole"} {
+		if got, err := sl.Resolve(symbol); err != nil || got != internal.Int(2) {
+			t.Errorf("Resolve(%s) got = %v, want 2", symbol, got)
+		}
+	}
+}
+
+func TestSpirit_Futures(t *testing.T) {
+	sl, err := initspirit()
+	if err != nil {
+		t.Fatalf("failed to init spirit: %v", err)
+	}
+
+	src := `
+	(defn sum [n]
+	  (loop [i 0 acc 0]
+	    (if (< i n) (recur (inc i) (+ acc i)) acc)))
+
+	(def futures
+	  (map (fn [i]
+	         (future
+	           (def last-future i)
+	           (try (throw "ignored") (fn [err] nil))
+	           (sum 100)))
+	       (range 16)))
+
+	(reduce + 0 (map deref futures))`
+
+	got, err := sl.ReadEval(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ReadEval() unexpected error: %v", err)
+	}
+
+	if want := internal.Number(16 * 4950); !internal.Compare(got, want) {
+		t.Errorf("ReadEval() got = %v, want %v", got, want)
+	}
+
+	if sl.Stack.Size() != 0 {
+		t.Errorf("Stack.Size() got = %d, want 0", sl.Stack.Size())
+	}
+}
+
+func TestSpirit_FuturesSharedLazySeq(t *testing.T) {
+	sl, err := initspirit()
+	if err != nil {
+		t.Fatalf("failed to init spirit: %v", err)
+	}
+
+	src := `
+	(def l (map inc (iterate inc 0)))
+	(def s (sequence (map inc) (range 100)))
+	(def e (eduction (filter even?) (map inc) (range 100)))
+
+	(def futures
+	  (map (fn [i]
+	         (future [(nth 100 l) (count s) (count e)]))
+	       (range 8)))
+
+	(into [] (map deref futures))`
+
+	got, err := sl.ReadEval(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ReadEval() unexpected error: %v", err)
+	}
+
+	want := internal.NewVector()
+	for i := 0; i < 8; i++ {
+		want = want.Conj(internal.NewVector().Conj(internal.Int(101), internal.Int(100), internal.Int(50))).(*internal.Vector)
+	}
+	if !internal.Compare(got, want) {
+		t.Errorf("ReadEval() got = %v, want %v", got, want)
+	}
+}
+
+func TestSpirit_FailingLazySeqKey(t *testing.T) {
+	sl, err := initspirit()
+	if err != nil {
+		t.Fatalf("failed to init spirit: %v", err)
+	}
+
+	if _, err := sl.ReadEvalStr(`(def bad (map (fn [x] (throw "x")) (range)))`); err != nil {
+		t.Fatalf("ReadEvalStr() unexpected error: %v", err)
+	}
+
+	for _, src := range []string{`{bad 1}`, `#{bad}`} {
+		if _, err := sl.ReadEvalStr(src); err == nil {
+			t.Errorf("ReadEvalStr(%s) expected error", src)
+		}
+
+		form, err := internal.NewReader(strings.NewReader(src)).One()
+		if err != nil {
+			t.Fatalf("One() unexpected error: %v", err)
+		}
+		if _, err := internal.Eval(sl, form); err == nil {
+			t.Errorf("Eval(%s) expected error", src)
+		}
+	}
+}
+
 func TestSpirit(t *testing.T) {
 	if testing.Short() {
 		return
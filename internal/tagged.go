@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xiaq/persistent/hash"
+)
+
+// instLayouts are the layouts of the timestamps of #inst literals. Parts
+// left out of a timestamp default to the start of the period in UTC.
+var instLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// Inst is an instant in time, read from #inst "2006-01-02T15:04:05Z".
+type Inst struct{ Time time.Time }
+
+// Eval returns the instant itself.
+func (inst Inst) Eval(_ Scope) (Value, error) { return inst, nil }
+
+func (inst Inst) String() string {
+	return fmt.Sprintf("#inst %q", inst.Time.UTC().Format(time.RFC3339Nano))
+}
+
+// Compare returns true if the other value is the same instant.
+func (inst Inst) Compare(other Value) bool {
+	o, ok := other.(Inst)
+	return ok && inst.Time.Equal(o.Time)
+}
+
+// Hash returns the hash of the time since the Unix epoch.
+func (inst Inst) Hash() uint32 {
+	return hashNumber(Int(inst.Time.UnixNano()))
+}
+
+func readInst(form Value) (Value, error) {
+	s, ok := form.(String)
+	if !ok {
+		return nil, fmt.Errorf("#inst expects a string, got %s", form)
+	}
+
+	for _, layout := range instLayouts {
+		if t, err := time.Parse(layout, string(s)); err == nil {
+			return Inst{Time: t}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid timestamp %s", s)
+}
+
+// readKeywordTag reads #spirit/keyword "name", the keywords whose name can
+// not be read as such.
+func readKeywordTag(form Value) (Value, error) {
+	s, ok := form.(String)
+	if !ok {
+		return nil, fmt.Errorf("#spirit/keyword expects a string, got %s", form)
+	}
+	return Keyword(s), nil
+}
+
+// readSymbolTag reads #spirit/symbol "name", the symbols whose name can not
+// be read as such.
+func readSymbolTag(form Value) (Value, error) {
+	s, ok := form.(String)
+	if !ok {
+		return nil, fmt.Errorf("#spirit/symbol expects a string, got %s", form)
+	}
+	return Symbol{Value: string(s)}, nil
+}
+
+// UUID is a universally unique identifier, read from
+// #uuid "f81d4fae-7dec-11d0-a765-00a0c91e6bf6".
+type UUID [16]byte
+
+// Eval returns the UUID itself.
+func (u UUID) Eval(_ Scope) (Value, error) { return u, nil }
+
+func (u UUID) String() string { return fmt.Sprintf("#uuid %q", u.text()) }
+
+// text returns the canonical text of the UUID, lower case hex digits in
+// groups of 8-4-4-4-12.
+func (u UUID) text() string {
+	s := hex.EncodeToString(u[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// Compare returns true if the other value is the same UUID.
+func (u UUID) Compare(other Value) bool {
+	o, ok := other.(UUID)
+	return ok && u == o
+}
+
+// Hash returns the hash of the bytes of the UUID.
+func (u UUID) Hash() uint32 { return hash.String(string(u[:])) }
+
+func readUUID(form Value) (Value, error) {
+	s, ok := form.(String)
+	if !ok {
+		return nil, fmt.Errorf("#uuid expects a string, got %s", form)
+	}
+
+	groups := strings.Split(string(s), "-")
+	lens := []int{8, 4, 4, 4, 12}
+	if len(groups) != len(lens) {
+		return nil, fmt.Errorf("invalid UUID %s", s)
+	}
+
+	var u UUID
+	at := 0
+	for i, group := range groups {
+		if len(group) != lens[i] {
+			return nil, fmt.Errorf("invalid UUID %s", s)
+		}
+
+		n, err := hex.Decode(u[at:], []byte(group))
+		if err != nil {
+			return nil, fmt.Errorf("invalid UUID %s", s)
+		}
+		at += n
+	}
+	return u, nil
+}
+
+func isInst(v Value) bool {
+	_, ok := v.(Inst)
+	return ok
+}
+
+func isUUID(v Value) bool {
+	_, ok := v.(UUID)
+	return ok
+}
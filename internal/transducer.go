@@ -0,0 +1,935 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/xiaq/persistent/hashmap"
+)
+
+// Transducer is a transformation of the values of a reduction which does
+// not depend on the sequence reduced or on the result built. Applying it
+// to a reducer returns a reducer passing the transformed values on, so a
+// pipeline of composed transducers runs in a single pass without building
+// intermediate collections. Functions are called in the scope the
+// transducer is applied in, not the one it was created in.
+type Transducer struct {
+	xf func(scope Scope, rf reducer) (reducer, error)
+}
+
+// reducer accumulates the values of a reduction into a result.
+type reducer interface {
+	// step returns the result with the value accumulated and true if the
+	// reduction should stop.
+	step(acc, v Value) (Value, bool, error)
+	// complete returns the final result once the reduction stops.
+	complete(acc Value) (Value, error)
+}
+
+// Eval returns the transducer itself.
+func (t *Transducer) Eval(_ Scope) (Value, error) {
+	return t, nil
+}
+
+func (t *Transducer) String() string {
+	return "<Transducer>"
+}
+
+// Invoke applies the transducer to a reducing function and returns the
+// transformed reducing function, as transducers written in spirit are
+// applied.
+func (t *Transducer) Invoke(scope Scope, args ...Value) (Value, error) {
+	argVals, err := EvalValueList(scope, args)
+	if err != nil {
+		return nil, err
+	}
+	return t.invokeValues(scope, argVals)
+}
+
+func (t *Transducer) invokeValues(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	rf, err := t.xf(scope, fnReducer{scope: scope, f: args[0]})
+	if err != nil {
+		return nil, err
+	}
+	return reducerFn(rf), nil
+}
+
+// Reduced wraps the result of a reducing function to stop the reduction
+// with it.
+type Reduced struct {
+	Val Value
+}
+
+// Eval returns the value itself.
+func (r Reduced) Eval(_ Scope) (Value, error) {
+	return r, nil
+}
+
+func (r Reduced) String() string {
+	return fmt.Sprintf("<Reduced(%v)>", r.Val)
+}
+
+// Eduction is a sequence of the values of a collection transformed by a
+// transducer. The values are not kept, they are transformed again each
+// time the eduction is traversed or reduced.
+type Eduction struct {
+	scope Scope
+	xform *Transducer
+	coll  Seq
+}
+
+// Eval returns the eduction itself.
+func (e *Eduction) Eval(_ Scope) (Value, error) {
+	return e, nil
+}
+
+func (e *Eduction) String() string {
+	return seqString(e.seq())
+}
+
+func (e *Eduction) First() Value {
+	return e.seq().First()
+}
+
+func (e *Eduction) Next() Seq {
+	return e.seq().Next()
+}
+
+func (e *Eduction) Cons(v Value) Seq {
+	return &chunk{vals: []Value{v}, rest: e}
+}
+
+func (e *Eduction) Conj(vals ...Value) Seq {
+	return lazyCat(e, &List{Values: Values{&List{Values: vals}}})
+}
+
+func (e *Eduction) Size() int {
+	return sizeOf(e)
+}
+
+func (e *Eduction) Compare(other Value) bool {
+	return compareSeq(e.seq(), other)
+}
+
+// seq returns a new lazy sequence of the transformed values.
+func (e *Eduction) seq() Seq {
+	seq, err := transformSeq(e.scope, e.xform, e.coll)
+	if err != nil {
+		panic(seqPanic{err: err})
+	}
+	return seq
+}
+
+// transduce reduces a sequence with a function transformed by the
+// transducer, starting from the initial value given, and completes the
+// result.
+func transduce(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{4}, args); err != nil {
+		return nil, err
+	}
+
+	xform, err := toTransducer(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	coll, err := toSeq(args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	rf, err := xform.xf(scope, fnReducer{scope: scope, f: args[1]})
+	if err != nil {
+		return nil, err
+	}
+
+	acc, _, err := reduceSeq(scope, rf, args[2], coll)
+	if err != nil {
+		return nil, err
+	}
+	return rf.complete(acc)
+}
+
+// intoColl conjoins the values of the last sequence, transformed by the
+// transducer if one is given, to the first one.
+func intoColl(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2, 3}, args); err != nil {
+		return nil, err
+	}
+
+	to, err := toSeq(args[0])
+	if err != nil {
+		return nil, err
+	} else if to == nil {
+		to = &List{}
+	}
+
+	from, err := toSeq(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	var rf reducer = &conjReducer{}
+	if len(args) == 3 {
+		xform, err := toTransducer(args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		if rf, err = xform.xf(scope, rf); err != nil {
+			return nil, err
+		}
+	}
+
+	acc, _, err := reduceSeq(scope, rf, to, from)
+	if err != nil {
+		return nil, err
+	}
+	return rf.complete(acc)
+}
+
+// sequenceOf returns a lazy sequence of the values of the sequence
+// transformed by the transducer.
+func sequenceOf(scope Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{2}, args); err != nil {
+		return nil, err
+	}
+
+	xform, err := toTransducer(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	coll, err := toSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return transformSeq(scope, xform, coll)
+}
+
+// eduction returns an eduction of the last sequence transformed by the
+// transducers before it, the first one transforming the values first.
+func eduction(scope Scope, args []Value) (Value, error) {
+	if len(args) < 1 {
+		return nil, ArgumentError{
+			Got: len(args),
+			Fn:  "eduction*",
+		}
+	}
+
+	xform, err := compXforms(scope, args[:len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	coll, err := toSeq(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	return &Eduction{scope: scope, xform: xform.(*Transducer), coll: coll}, nil
+}
+
+// compXforms returns a transducer applying the transducers given in turn,
+// the first one transforming the values first.
+func compXforms(_ Scope, args []Value) (Value, error) {
+	xforms := make([]*Transducer, len(args))
+	for i, arg := range args {
+		var err error
+		if xforms[i], err = toTransducer(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Transducer{xf: func(scope Scope, rf reducer) (reducer, error) {
+		for i := len(xforms) - 1; i >= 0; i-- {
+			var err error
+			if rf, err = xforms[i].xf(scope, rf); err != nil {
+				return nil, err
+			}
+		}
+		return rf, nil
+	}}, nil
+}
+
+// transformSeq returns a lazy sequence of the values of the sequence
+// transformed by the transducer, realising a chunk of the sequence at a
+// time. Each chunk is realised in an execution of its own, see detach.
+func transformSeq(scope Scope, xform *Transducer, coll Seq) (*Lazy, error) {
+	realizing := detach(scope)
+
+	buf := &conjReducer{}
+	rf, err := xform.xf(realizing, buf)
+	if err != nil {
+		return nil, err
+	}
+	return stepSeq(realizing, rf, buf, coll), nil
+}
+
+func stepSeq(realizing *MapScope, rf reducer, buf *conjReducer, coll Seq) *Lazy {
+	return newLazy(func() (Seq, error) {
+		// the chunks are realised one after the other, so the execution
+		// the reducer steps in can be replaced by the goroutine realising
+		// this one
+		realizing.exec = newExecution(realizing.exec.ctx)
+
+		for len(buf.vals) == 0 {
+			vals, rest := nextChunk(coll)
+
+			done := len(vals) == 0
+			for _, v := range vals {
+				var err error
+				if _, done, err = rf.step(nil, v); err != nil {
+					return nil, err
+				} else if done {
+					break
+				}
+			}
+
+			if done {
+				if _, err := rf.complete(nil); err != nil {
+					return nil, err
+				}
+
+				if vals := buf.take(); len(vals) > 0 {
+					return &chunk{vals: vals}, nil
+				}
+				return nil, nil
+			}
+			coll = rest
+		}
+
+		return &chunk{vals: buf.take(), rest: stepSeq(realizing, rf, buf, coll)}, nil
+	})
+}
+
+// reduceSeq steps the reducer with the values of the sequence until it
+// stops the reduction or there are no values left, and returns the result
+// and true if it was stopped. The reducer is not completed.
+func reduceSeq(scope Scope, rf reducer, acc Value, coll Seq) (Value, bool, error) {
+	switch c := coll.(type) {
+	case *Eduction:
+		inner := &stepOnly{rf: rf}
+		erf, err := c.xform.xf(scope, inner)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if acc, _, err = reduceSeq(scope, erf, acc, c.coll); err != nil {
+			return nil, false, err
+		}
+
+		acc, err = erf.complete(acc)
+		return acc, inner.stopped, err
+
+	case *HashMap:
+		for it := c.Data.Iterator(); it.HasElem(); it.Next() {
+			k, v := it.Elem()
+
+			var (
+				done bool
+				err  error
+			)
+			acc, done, err = rf.step(acc, NewVector().Conj(k.(Value), v.(Value)))
+			if err != nil || done {
+				return acc, done, err
+			}
+		}
+		return acc, false, nil
+	}
+
+	for coll != nil {
+		if err := checkContext(scope); err != nil {
+			return nil, false, err
+		}
+
+		vals, rest := nextChunk(coll)
+		if len(vals) == 0 {
+			break
+		}
+
+		for _, v := range vals {
+			var (
+				done bool
+				err  error
+			)
+			acc, done, err = rf.step(acc, v)
+			if err != nil || done {
+				return acc, done, err
+			}
+		}
+		coll = rest
+	}
+
+	return acc, false, nil
+}
+
+// toTransducer returns the value as a transducer. Functions are taken to be
+// transducers written in spirit, which transform a reducing function.
+func toTransducer(v Value) (*Transducer, error) {
+	switch xform := v.(type) {
+	case *Transducer:
+		return xform, nil
+
+	case Invokable:
+		return &Transducer{xf: func(scope Scope, rf reducer) (reducer, error) {
+			f, err := invoke(scope, xform, reducerFn(rf))
+			if err != nil {
+				return nil, err
+			}
+			return fnReducer{scope: scope, f: f}, nil
+		}}, nil
+	}
+
+	return nil, TypeError{
+		Expected: &Transducer{},
+		Got:      v,
+	}
+}
+
+// fnReducer reduces with a function of the result and a value. Returning
+// a Reduced value from the function stops the reduction.
+type fnReducer struct {
+	scope Scope
+	f     Value
+}
+
+func (r fnReducer) step(acc, v Value) (Value, bool, error) {
+	res, err := invoke(r.scope, r.f, acc, v)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if reduced, ok := res.(Reduced); ok {
+		return reduced.Val, true, nil
+	}
+	return res, false, nil
+}
+
+// complete calls the function with the result alone if it accepts a single
+// argument, otherwise the result is returned as is.
+func (r fnReducer) complete(acc Value) (Value, error) {
+	if !acceptsArgs(r.f, 1) {
+		return acc, nil
+	}
+	return invoke(r.scope, r.f, acc)
+}
+
+// conjReducer collects the values and conjoins them to the result at once
+// when completed.
+type conjReducer struct {
+	vals []Value
+}
+
+func (r *conjReducer) step(acc, v Value) (Value, bool, error) {
+	r.vals = append(r.vals, v)
+	return acc, false, nil
+}
+
+func (r *conjReducer) complete(acc Value) (Value, error) {
+	if acc == nil {
+		return nil, nil
+	}
+
+	seq, ok := acc.(Seq)
+	if !ok {
+		return nil, ImplementError{
+			Name: seqStr,
+			Val:  acc,
+		}
+	}
+	return seq.Conj(r.take()...), nil
+}
+
+// take returns the values collected since the last call.
+func (r *conjReducer) take() []Value {
+	vals := r.vals
+	r.vals = nil
+	return vals
+}
+
+// stepOnly passes the values on to a reducer completed by another
+// reduction, and records if the reducer stopped.
+type stepOnly struct {
+	rf      reducer
+	stopped bool
+}
+
+func (r *stepOnly) step(acc, v Value) (Value, bool, error) {
+	acc, done, err := r.rf.step(acc, v)
+	r.stopped = r.stopped || done
+	return acc, done, err
+}
+
+func (r *stepOnly) complete(acc Value) (Value, error) {
+	return acc, nil
+}
+
+// stepFunc is the step of a reducer.
+type stepFunc func(acc, v Value) (Value, bool, error)
+
+// xfReducer is a reducer transforming the values it passes to the reducer
+// it wraps. flush, if set, is called with the result before the wrapped
+// reducer is completed.
+type xfReducer struct {
+	rf     reducer
+	stepFn stepFunc
+	flush  func(acc Value) (Value, error)
+}
+
+func (r *xfReducer) step(acc, v Value) (Value, bool, error) {
+	return r.stepFn(acc, v)
+}
+
+func (r *xfReducer) complete(acc Value) (Value, error) {
+	if r.flush != nil {
+		var err error
+		if acc, err = r.flush(acc); err != nil {
+			return nil, err
+		}
+	}
+	return r.rf.complete(acc)
+}
+
+// stepXf returns a transducer stepping with the function newStep returns
+// for the reducer transformed. newStep is called for each reduction, so the
+// state a step keeps is not shared between reductions.
+func stepXf(newStep func(scope Scope, rf reducer) stepFunc) *Transducer {
+	return &Transducer{xf: func(scope Scope, rf reducer) (reducer, error) {
+		return &xfReducer{rf: rf, stepFn: newStep(scope, rf)}, nil
+	}}
+}
+
+// reducerFn returns the reducer as a reducing function, called with the
+// result and a value to step and with the result alone to complete.
+func reducerFn(rf reducer) *Fn {
+	return strictFn([]string{"args"}, true, func(_ Scope, args []Value) (Value, error) {
+		switch len(args) {
+		case 1:
+			return rf.complete(args[0])
+
+		case 2:
+			acc, done, err := rf.step(args[0], args[1])
+			if err != nil {
+				return nil, err
+			} else if done {
+				return Reduced{Val: acc}, nil
+			}
+			return acc, nil
+		}
+
+		return nil, ArgumentError{
+			Got: len(args),
+			Fn:  "reducer",
+		}
+	})
+}
+
+// acceptsArgs returns true if the function can be called with n arguments.
+func acceptsArgs(f Value, n int) bool {
+	args := make([]Value, n)
+
+	switch fn := f.(type) {
+	case *Fn:
+		return fn.matchArity(args)
+
+	case MultiFn:
+		_, err := fn.selectMethod(args)
+		return err == nil
+	}
+
+	return true
+}
+
+// mapXf returns a transducer applying f to each value.
+func mapXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	f := args[0]
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		return func(acc, v Value) (Value, bool, error) {
+			v, err := invoke(scope, f, v)
+			if err != nil {
+				return nil, false, err
+			}
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// filterXf returns a transducer keeping the values for which the predicate
+// returns true.
+func filterXf(_ Scope, args []Value) (Value, error) {
+	return predXf(args, true)
+}
+
+// removeXf returns a transducer dropping the values for which the
+// predicate returns true.
+func removeXf(_ Scope, args []Value) (Value, error) {
+	return predXf(args, false)
+}
+
+func predXf(args []Value, keep bool) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	pred := args[0]
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		return func(acc, v Value) (Value, bool, error) {
+			ok, err := invoke(scope, pred, v)
+			if err != nil {
+				return nil, false, err
+			}
+
+			if isTruthy(ok) != keep {
+				return acc, false, nil
+			}
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// keepXf returns a transducer of the results of applying f to each value
+// which are not nil.
+func keepXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	f := args[0]
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		return func(acc, v Value) (Value, bool, error) {
+			v, err := invoke(scope, f, v)
+			if err != nil {
+				return nil, false, err
+			}
+
+			if v == (Nil{}) {
+				return acc, false, nil
+			}
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// takeXf returns a transducer of the first n values. The reduction stops
+// once they are taken.
+func takeXf(_ Scope, args []Value) (Value, error) {
+	n, err := countArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		taken := 0
+		return func(acc, v Value) (Value, bool, error) {
+			if taken >= n {
+				return acc, true, nil
+			}
+
+			taken++
+			acc, done, err := rf.step(acc, v)
+			return acc, done || taken >= n, err
+		}
+	}), nil
+}
+
+// dropXf returns a transducer of the values following the first n.
+func dropXf(_ Scope, args []Value) (Value, error) {
+	n, err := countArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		dropped := 0
+		return func(acc, v Value) (Value, bool, error) {
+			if dropped < n {
+				dropped++
+				return acc, false, nil
+			}
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// takeNthXf returns a transducer of every nth value, starting with the
+// first.
+func takeNthXf(_ Scope, args []Value) (Value, error) {
+	n, err := countArg(args)
+	if err != nil {
+		return nil, err
+	} else if n < 1 {
+		return nil, fmt.Errorf("take-nth requires a positive step, got %d", n)
+	}
+
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		i := 0
+		return func(acc, v Value) (Value, bool, error) {
+			i++
+			if (i-1)%n != 0 {
+				return acc, false, nil
+			}
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// takeWhileXf returns a transducer of the values at the start for which
+// the predicate returns true. The reduction stops at the first value it
+// returns false for.
+func takeWhileXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	pred := args[0]
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		return func(acc, v Value) (Value, bool, error) {
+			ok, err := invoke(scope, pred, v)
+			if err != nil {
+				return nil, false, err
+			}
+
+			if !isTruthy(ok) {
+				return acc, true, nil
+			}
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// dropWhileXf returns a transducer of the values starting from the first
+// one for which the predicate returns false.
+func dropWhileXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	pred := args[0]
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		dropping := true
+		return func(acc, v Value) (Value, bool, error) {
+			if dropping {
+				ok, err := invoke(scope, pred, v)
+				if err != nil {
+					return nil, false, err
+				}
+
+				if isTruthy(ok) {
+					return acc, false, nil
+				}
+				dropping = false
+			}
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// partitionByXf returns a transducer of vectors of the consecutive values
+// for which f returns the same result.
+func partitionByXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	f := args[0]
+	return &Transducer{xf: func(scope Scope, rf reducer) (reducer, error) {
+		var (
+			part []Value
+			key  Value
+		)
+
+		return &xfReducer{
+			rf: rf,
+			stepFn: func(acc, v Value) (Value, bool, error) {
+				k, err := invoke(scope, f, v)
+				if err != nil {
+					return nil, false, err
+				}
+
+				if len(part) > 0 && !Compare(k, key) {
+					vals := part
+					part = nil
+
+					acc, done, err := rf.step(acc, NewVector().Conj(vals...))
+					if err != nil || done {
+						return acc, done, err
+					}
+				}
+
+				part, key = append(part, v), k
+				return acc, false, nil
+			},
+			flush: func(acc Value) (Value, error) {
+				return flushPart(rf, acc, &part)
+			},
+		}, nil
+	}}, nil
+}
+
+// partitionAllXf returns a transducer of vectors of n values, the last one
+// may have fewer.
+func partitionAllXf(_ Scope, args []Value) (Value, error) {
+	n, err := countArg(args)
+	if err != nil {
+		return nil, err
+	} else if n < 1 {
+		return nil, fmt.Errorf("partition-all requires a positive size, got %d", n)
+	}
+
+	return &Transducer{xf: func(scope Scope, rf reducer) (reducer, error) {
+		var part []Value
+
+		return &xfReducer{
+			rf: rf,
+			stepFn: func(acc, v Value) (Value, bool, error) {
+				if part = append(part, v); len(part) < n {
+					return acc, false, nil
+				}
+
+				vals := part
+				part = nil
+				return rf.step(acc, NewVector().Conj(vals...))
+			},
+			flush: func(acc Value) (Value, error) {
+				return flushPart(rf, acc, &part)
+			},
+		}, nil
+	}}, nil
+}
+
+// flushPart steps the reducer with the values of a partition left when the
+// reduction stops, if there are any.
+func flushPart(rf reducer, acc Value, part *[]Value) (Value, error) {
+	if len(*part) == 0 {
+		return acc, nil
+	}
+
+	vals := *part
+	*part = nil
+
+	acc, _, err := rf.step(acc, NewVector().Conj(vals...))
+	return acc, err
+}
+
+// dedupeXf returns a transducer dropping the values equal to the one
+// before them.
+func dedupeXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount(nil, args); err != nil {
+		return nil, err
+	}
+
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		var last Value
+		return func(acc, v Value) (Value, bool, error) {
+			if last != nil && Compare(last, v) {
+				return acc, false, nil
+			}
+
+			last = v
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// distinctXf returns a transducer dropping the values equal to any value
+// before them.
+func distinctXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount(nil, args); err != nil {
+		return nil, err
+	}
+
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		var seen hashmap.Map = emptyMap
+		return func(acc, v Value) (Value, bool, error) {
+			if _, found := seen.Index(v); found {
+				return acc, false, nil
+			}
+
+			seen = seen.Assoc(v, v)
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// interposeXf returns a transducer of the values separated by sep.
+func interposeXf(_ Scope, args []Value) (Value, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return nil, err
+	}
+
+	sep := args[0]
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		started := false
+		return func(acc, v Value) (Value, bool, error) {
+			if started {
+				acc, done, err := rf.step(acc, sep)
+				if err != nil || done {
+					return acc, done, err
+				}
+			}
+
+			started = true
+			return rf.step(acc, v)
+		}
+	}), nil
+}
+
+// catXf returns a transducer of the values of each sequence.
+func catXf() *Transducer {
+	return stepXf(func(scope Scope, rf reducer) stepFunc {
+		return func(acc, v Value) (Value, bool, error) {
+			coll, err := toSeq(v)
+			if err != nil {
+				return nil, false, err
+			}
+			return reduceSeq(scope, rf, acc, coll)
+		}
+	})
+}
+
+// mapcatXf returns a transducer of the values of the sequences returned by
+// applying f to each value.
+func mapcatXf(scope Scope, args []Value) (Value, error) {
+	mapf, err := mapXf(scope, args)
+	if err != nil {
+		return nil, err
+	}
+	return compXforms(scope, []Value{mapf, catXf()})
+}
+
+// countArg returns the number a transducer is created with.
+func countArg(args []Value) (int, error) {
+	if err := verifyArgCount([]int{1}, args); err != nil {
+		return 0, err
+	}
+
+	n, ok := toIndex(args[0])
+	if !ok {
+		return 0, TypeError{
+			Expected: Int(0),
+			Got:      args[0],
+		}
+	}
+	return n, nil
+}
+
+func isTransducer(v Value) bool {
+	_, ok := v.(*Transducer)
+	return ok
+}
+
+func isReduced(v Value) bool {
+	_, ok := v.(Reduced)
+	return ok
+}
+
+func reduced(v Value) Value {
+	return Reduced{Val: v}
+}
@@ -0,0 +1,237 @@
+package internal
+
+import "fmt"
+
+// TransientVector is a vector being built in place. It is owned by the
+// execution that created it and can not be used after persistent!, which
+// returns the vector built.
+type TransientVector struct {
+	vec   *transientVector
+	owner *execution
+}
+
+// Eval returns the transient itself.
+func (tv *TransientVector) Eval(_ Scope) (Value, error) { return tv, nil }
+
+func (tv *TransientVector) String() string {
+	return fmt.Sprintf("<TransientVector(count: %d)>", tv.Size())
+}
+
+// Size returns the number of values.
+func (tv *TransientVector) Size() int { return tv.vec.count }
+
+// use returns the builder of the transient once the evaluation in scope is
+// allowed to change it.
+func (tv *TransientVector) use(scope Scope) (*transientVector, error) {
+	return tv.vec, checkOwner(scope, tv.vec.edit, tv.owner)
+}
+
+// TransientMap is a hash map being built in place. It is owned by the
+// execution that created it and can not be used after persistent!, which
+// returns the map built.
+type TransientMap struct {
+	data  *transientMap
+	owner *execution
+}
+
+// Eval returns the transient itself.
+func (tm *TransientMap) Eval(_ Scope) (Value, error) { return tm, nil }
+
+func (tm *TransientMap) String() string {
+	return fmt.Sprintf("<TransientMap(count: %d)>", tm.Size())
+}
+
+// Size returns the number of entries.
+func (tm *TransientMap) Size() int { return tm.data.count }
+
+// use returns the builder of the transient once the evaluation in scope is
+// allowed to change it.
+func (tm *TransientMap) use(scope Scope) (*transientMap, error) {
+	return tm.data, checkOwner(scope, tm.data.edit, tm.owner)
+}
+
+func checkOwner(scope Scope, edit *owner, exec *execution) error {
+	if edit == nil {
+		return TransientError{Reason: "transient used after persistent!"}
+	} else if executionOf(scope) != exec {
+		return TransientError{Reason: "transient used by non-owner thread"}
+	}
+	return nil
+}
+
+// transient returns a transient of the vector or the hash map.
+func transient(scope Scope, args []Value) (Value, error) {
+	switch coll := args[0].(type) {
+	case *Vector:
+		return &TransientVector{
+			vec:   newTransientVector(coll.Vec),
+			owner: executionOf(scope),
+		}, nil
+
+	case *HashMap:
+		return &TransientMap{
+			data:  newTransientMap(coll.Data),
+			owner: executionOf(scope),
+		}, nil
+	}
+
+	return nil, TypeError{
+		Expected: NewVector(),
+		Got:      args[0],
+	}
+}
+
+// conjBang adds the values to the transient. Values added to a map are
+// vectors of a key and a value.
+func conjBang(scope Scope, args []Value) (Value, error) {
+	switch t := args[0].(type) {
+	case *TransientVector:
+		vec, err := t.use(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range args[1:] {
+			vec.conj(v)
+		}
+		return t, nil
+
+	case *TransientMap:
+		data, err := t.use(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range args[1:] {
+			entry, ok := v.(*Vector)
+			if !ok || entry.Size() != 2 {
+				return nil, fmt.Errorf("map entry must be a vector of a key and a value")
+			}
+			data.assoc(entry.Index(0), entry.Index(1))
+		}
+		return t, nil
+	}
+
+	return nil, notTransient(args[0])
+}
+
+// assocBang associates the keys with the values in the transient. Keys of a
+// vector are indexes up to its length.
+func assocBang(scope Scope, args []Value) (Value, error) {
+	if len(args)%2 != 1 {
+		return nil, ArgumentError{
+			Fn:  "assoc!",
+			Got: len(args),
+		}
+	}
+
+	switch t := args[0].(type) {
+	case *TransientVector:
+		vec, err := t.use(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 1; i < len(args); i += 2 {
+			idx, ok := toIndex(args[i])
+			if !ok {
+				return nil, fmt.Errorf("key must be integer")
+			} else if !vec.assoc(idx, args[i+1]) {
+				return nil, fmt.Errorf("index out of bounds")
+			}
+		}
+		return t, nil
+
+	case *TransientMap:
+		data, err := t.use(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 1; i < len(args); i += 2 {
+			data.assoc(args[i], args[i+1])
+		}
+		return t, nil
+	}
+
+	return nil, notTransient(args[0])
+}
+
+// dissocBang removes the keys from the transient map.
+func dissocBang(scope Scope, args []Value) (Value, error) {
+	t, ok := args[0].(*TransientMap)
+	if !ok {
+		return nil, TypeError{
+			Expected: &TransientMap{},
+			Got:      args[0],
+		}
+	}
+
+	data, err := t.use(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range args[1:] {
+		data.dissoc(k)
+	}
+	return t, nil
+}
+
+// popBang removes the last value of the transient vector.
+func popBang(scope Scope, args []Value) (Value, error) {
+	t, ok := args[0].(*TransientVector)
+	if !ok {
+		return nil, TypeError{
+			Expected: &TransientVector{},
+			Got:      args[0],
+		}
+	}
+
+	vec, err := t.use(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if !vec.pop() {
+		return nil, fmt.Errorf("can't pop empty vector")
+	}
+	return t, nil
+}
+
+// persistentBang returns the collection built by the transient, which can
+// not be used afterwards.
+func persistentBang(scope Scope, args []Value) (Value, error) {
+	switch t := args[0].(type) {
+	case *TransientVector:
+		vec, err := t.use(scope)
+		if err != nil {
+			return nil, err
+		}
+		return &Vector{Vec: vec.persistent()}, nil
+
+	case *TransientMap:
+		data, err := t.use(scope)
+		if err != nil {
+			return nil, err
+		}
+		return &HashMap{Data: data.persistent()}, nil
+	}
+
+	return nil, notTransient(args[0])
+}
+
+func isTransient(v Value) bool {
+	switch v.(type) {
+	case *TransientVector, *TransientMap:
+		return true
+	}
+	return false
+}
+
+func notTransient(got Value) error {
+	return TypeError{
+		Expected: &TransientVector{},
+		Got:      got,
+	}
+}
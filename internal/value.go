@@ -117,13 +117,14 @@ func (vals Values) Size() int { return len(vals) }
 // other sequence will be realized for comparison.
 func (vals Values) Compare(v Value) bool {
 	other, ok := v.(Seq)
-	if !ok {
+	if !ok || !isSequential(other) {
 		return false
 	}
 
+	// the size of a lazy sequence is not known without realising it
 	if s, hasSize := other.(interface {
 		Size() int
-	}); hasSize {
+	}); hasSize && !isLazy(other) {
 		if vals.Size() != s.Size() {
 			return false
 		}
@@ -0,0 +1,16 @@
+// Package lib bundles the spirit standard library so that it is always
+// available without being installed on the host.
+package lib
+
+import (
+	// required by go:embed
+	_ "embed"
+)
+
+// Core is the source of the core standard library.
+//
+//go:embed core.st
+var Core string
+
+// CoreFile is the file name reported for forms read from Core.
+const CoreFile = "core.st"
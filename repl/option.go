@@ -7,7 +7,7 @@ import (
 	"os"
 	"strings"
 
-	"github.com/issadarkthing/spirit/internal"
+	"github.com/issadarkthing/spirit/spirit"
 )
 
 // Option implementations can be provided to New() to configure the REPL
@@ -17,14 +17,14 @@ type Option func(repl *REPL)
 // ReaderFactory should return an instance of reader when called. This might
 // be called repeatedly. See WithReaderFactory()
 type ReaderFactory interface {
-	NewReader(r io.Reader) *internal.Reader
+	NewReader(r io.Reader) *spirit.Reader
 }
 
 // ReaderFactoryFunc implements ReaderFactory using a function value.
-type ReaderFactoryFunc func(r io.Reader) *internal.Reader
+type ReaderFactoryFunc func(r io.Reader) *spirit.Reader
 
 // NewReader simply calls the wrapped function value and returns the result.
-func (factory ReaderFactoryFunc) NewReader(r io.Reader) *internal.Reader {
+func (factory ReaderFactoryFunc) NewReader(r io.Reader) *spirit.Reader {
 	return factory(r)
 }
 
@@ -81,11 +81,11 @@ func WithPrompts(oneLine, multiLine string) Option {
 	}
 }
 
-// WithReaderFactory can be used set factory function for initializing internal
+// WithReaderFactory can be used set factory function for initializing spirit
 // Reader. This is useful when you want REPL to use custom reader instance.
 func WithReaderFactory(factory ReaderFactory) Option {
 	if factory == nil {
-		factory = ReaderFactoryFunc(internal.NewReader)
+		factory = ReaderFactoryFunc(spirit.NewReader)
 	}
 
 	return func(repl *REPL) {
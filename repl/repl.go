@@ -1,4 +1,4 @@
-// Package repl provides a REPL implementation and options to expose internal
+// Package repl provides a REPL implementation and options to expose spirit
 // features through a read-eval-print-loop.
 package repl
 
@@ -7,14 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
 	"strings"
 
-	"github.com/issadarkthing/spirit/internal"
+	"github.com/issadarkthing/spirit/spirit"
 )
 
-// New returns a new instance of REPL with given internal Scope. Option values
+// New returns a new instance of REPL with given spirit Scope. Option values
 // can be used to configure REPL input, output etc.
-func New(scope internal.Scope, opts ...Option) *REPL {
+func New(scope spirit.Scope, opts ...Option) *REPL {
 	repl := &REPL{
 		scope:            scope,
 		currentNamespace: func() string { return "" },
@@ -31,7 +33,7 @@ func New(scope internal.Scope, opts ...Option) *REPL {
 	return repl
 }
 
-// NamespacedScope can be implemented by internal.Scope implementations to allow
+// NamespacedScope can be implemented by spirit.Scope implementations to allow
 // namespace based isolation (similar to Clojure). REPL will call CurrentNS()
 // method to get the current Namespace and display it as part of input prompt.
 type NamespacedScope interface {
@@ -40,7 +42,8 @@ type NamespacedScope interface {
 
 // REPL implements a read-eval-print loop for a generic Runtime.
 type REPL struct {
-	scope            internal.Scope
+	ctx              context.Context
+	scope            spirit.Scope
 	input            Input
 	output           io.Writer
 	mapInputErr      ErrMapper
@@ -62,7 +65,9 @@ type Input interface {
 }
 
 // Loop starts the read-eval-print loop. Loop runs until context is cancelled
-// or input stream returns an irrecoverable error (See WithInput()).
+// or input stream returns an irrecoverable error (See WithInput()). Each form
+// is evaluated with a context derived from ctx which is cancelled on interrupt
+// signal, so Ctrl-C aborts the running form only.
 func (repl *REPL) Loop(ctx context.Context) error {
 	repl.printBanner()
 	repl.setPrompt(false)
@@ -71,6 +76,8 @@ func (repl *REPL) Loop(ctx context.Context) error {
 		return errors.New("scope is not set")
 	}
 
+	repl.ctx = ctx
+
 	for ctx.Err() == nil {
 		err := repl.readEvalPrint()
 		if err != nil {
@@ -90,7 +97,7 @@ func (repl *REPL) readEvalPrint() error {
 	form, err := repl.read()
 	if err != nil {
 		switch err.(type) {
-		case internal.ReadError, internal.EvalError:
+		case spirit.ReadError, spirit.EvalError:
 			repl.print(err)
 		default:
 			return err
@@ -101,21 +108,30 @@ func (repl *REPL) readEvalPrint() error {
 		return nil
 	}
 
-	root := internal.RootScope(repl.scope)
-	spirit, ok := root.(*internal.Spirit)
+	root := spirit.RootScope(repl.scope)
+	sp, ok := root.(*spirit.Spirit)
 	if !ok {
 		return fmt.Errorf("InternalError: cannot find Spirit instance")
 	}
 
-	v, err := internal.Eval(repl.scope, form)
+	v, err := repl.eval(form)
 	if err != nil {
-		internal.ClearStack(&spirit.Stack)
+		spirit.ClearStack(&sp.Stack)
 		return repl.print(err)
 	}
 
 	return repl.print(v)
 }
 
+// eval evaluates the form until it completes or the user interrupts it.
+// An interrupt only aborts the current form and not the REPL session.
+func (repl *REPL) eval(form spirit.Value) (spirit.Value, error) {
+	ctx, stop := signal.NotifyContext(repl.ctx, os.Interrupt)
+	defer stop()
+
+	return spirit.EvalContext(ctx, repl.scope, form)
+}
+
 func (repl *REPL) Write(b []byte) (int, error) {
 	return repl.output.Write(b)
 }
@@ -124,7 +140,7 @@ func (repl *REPL) print(v interface{}) error {
 	return repl.printer(repl.output, v)
 }
 
-func (repl *REPL) read() (internal.Value, error) {
+func (repl *REPL) read() (spirit.Value, error) {
 	var src string
 	lineNo := 1
 
@@ -145,10 +161,11 @@ func (repl *REPL) read() (internal.Value, error) {
 
 		rd := repl.factory.NewReader(strings.NewReader(src))
 		rd.File = "REPL"
+		rd.SetScope(repl.scope)
 
 		form, err := rd.All()
 		if err != nil {
-			if errors.Is(err, internal.ErrEOF) {
+			if errors.Is(err, spirit.ErrEOF) {
 				lineNo++
 				continue
 			}
@@ -156,6 +173,12 @@ func (repl *REPL) read() (internal.Value, error) {
 			return nil, err
 		}
 
+		// input of only no-op forms, such as a reader conditional with no
+		// matching feature, reads as nothing.
+		if mod, ok := form.(spirit.Module); ok && len(mod) == 0 {
+			return nil, nil
+		}
+
 		return form, nil
 	}
 }
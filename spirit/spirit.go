@@ -0,0 +1,319 @@
+// Package spirit exposes the spirit interpreter for embedding in Go programs.
+// It provides the interpreter instance, the reader, value types and helpers
+// for converting values between Go and spirit.
+package spirit
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/issadarkthing/spirit/internal"
+	"github.com/issadarkthing/spirit/lib"
+)
+
+// DefaultNS is the namespace a new Spirit instance starts in.
+const DefaultNS = "user"
+
+type (
+	// Spirit is the root scope of an interpreter instance.
+	Spirit = internal.Spirit
+	// Scope manages value bindings.
+	Scope = internal.Scope
+	// Value represents data/forms in spirit.
+	Value = internal.Value
+	// Invokable represents any value that supports invocation.
+	Invokable = internal.Invokable
+	// Seq represents a sequence of values.
+	Seq = internal.Seq
+	// Assoc represents a value that can be mapped.
+	Assoc = internal.Assoc
+	// Hashable represents a value hashed by its structure.
+	Hashable = internal.Hashable
+	// Reader reads source into forms.
+	Reader = internal.Reader
+	// TagReader returns the value of a tagged literal read by read-edn.
+	// See Spirit.RegisterTag().
+	TagReader = internal.TagReader
+	// ReaderMacro customizes the Reader. See Reader.SetMacro().
+	ReaderMacro = internal.ReaderMacro
+	// Position is the positional information of a form.
+	Position = internal.Position
+	// Stack holds the function calls of an evaluation.
+	Stack = internal.Stack
+
+	// EvalError represents error during evaluation.
+	EvalError = internal.EvalError
+	// ReadError represents error during reading.
+	ReadError = internal.ReadError
+	// PermissionError is returned when a sandboxed instance uses a builtin
+	// it is not granted the capability for.
+	PermissionError = internal.PermissionError
+	// LimitError is returned when a sandboxed instance exceeds a limit.
+	LimitError = internal.LimitError
+	// StackOverflowError is returned when calls are nested deeper than the
+	// maximum depth.
+	StackOverflowError = internal.StackOverflowError
+	// ArithmeticError is returned when an operation has no exact result,
+	// such as a division by zero.
+	ArithmeticError = internal.ArithmeticError
+	// TransientError is returned when a transient is used after
+	// persistent! or by another thread.
+	TransientError = internal.TransientError
+
+	// Sandbox restricts what an instance can do. See WithSandbox().
+	Sandbox = internal.Sandbox
+	// Capability is a privilege granted to a sandboxed instance.
+	Capability = internal.Capability
+
+	Nil        = internal.Nil
+	Bool       = internal.Bool
+	Number     = internal.Number
+	Int        = internal.Int
+	BigInt     = internal.BigInt
+	Ratio      = internal.Ratio
+	BigDecimal = internal.BigDecimal
+	String     = internal.String
+	Character  = internal.Character
+	Keyword    = internal.Keyword
+	Symbol     = internal.Symbol
+	List       = internal.List
+	Vector     = internal.Vector
+	HashMap    = internal.HashMap
+	Set        = internal.Set
+	SortedMap  = internal.SortedMap
+	SortedSet  = internal.SortedSet
+	Module     = internal.Module
+	Fn         = internal.Fn
+	MultiFn    = internal.MultiFn
+	Any        = internal.Any
+	Type       = internal.Type
+
+	// TransientVector and TransientMap build a vector and a hash map in
+	// place, see transient and persistent!.
+	TransientVector = internal.TransientVector
+	TransientMap    = internal.TransientMap
+
+	// TaggedLiteral is a tagged literal read by read-edn without a reader
+	// for its tag.
+	TaggedLiteral = internal.TaggedLiteral
+	// Inst and UUID are read from the #inst and #uuid literals.
+	Inst = internal.Inst
+	UUID = internal.UUID
+	// Regex is a regular expression, read from the #"..." literal.
+	Regex = internal.Regex
+)
+
+// Capabilities that can be granted to a sandboxed instance.
+const (
+	CapFileRead  = internal.CapFileRead
+	CapFileWrite = internal.CapFileWrite
+	CapShell     = internal.CapShell
+	CapNetwork   = internal.CapNetwork
+	CapReflect   = internal.CapReflect
+	CapTime      = internal.CapTime
+	CapAll       = internal.CapAll
+)
+
+// ErrEOF is returned by the Reader when the stream ends in the middle of
+// a form.
+var ErrEOF = internal.ErrEOF
+
+// Option configures a Spirit instance created by NewSpirit().
+type Option func(cfg *config)
+
+type config struct {
+	loadCore bool
+	corePath string
+	sandbox  *Sandbox
+	paths    []string
+}
+
+// WithCorePath loads the standard library from the given file instead of
+// the copy bundled into the binary. An empty path selects the bundled copy.
+func WithCorePath(path string) Option {
+	return func(cfg *config) {
+		cfg.loadCore = true
+		cfg.corePath = path
+	}
+}
+
+// WithoutCore creates the instance without loading the standard library.
+// Only the builtins implemented in Go will be available.
+func WithoutCore() Option {
+	return func(cfg *config) {
+		cfg.loadCore = false
+	}
+}
+
+// WithSandbox restricts the instance to the capabilities and limits of the
+// sandbox. The standard library is loaded before the restrictions apply.
+// Builtins that require a capability which is not granted fail with a
+// PermissionError.
+func WithSandbox(sb Sandbox) Option {
+	return func(cfg *config) {
+		cfg.sandbox = &sb
+	}
+}
+
+// WithSearchPath appends the directories to the search path of imports
+// and required namespaces. Relative paths which are not found next to the
+// importing file are looked up in these directories in order.
+func WithSearchPath(dirs ...string) Option {
+	return func(cfg *config) {
+		cfg.paths = append(cfg.paths, dirs...)
+	}
+}
+
+// NewSpirit returns a new interpreter instance with the standard library
+// loaded and the current namespace set to DefaultNS.
+func NewSpirit(opts ...Option) (*Spirit, error) {
+	cfg := &config{loadCore: true}
+
+	for _, option := range opts {
+		option(cfg)
+	}
+
+	sp := internal.NewSpirit()
+	sp.AddPath(cfg.paths...)
+
+	if cfg.loadCore {
+		if err := loadCore(sp, cfg.corePath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sp.SwitchNS(NewSymbol(DefaultNS)); err != nil {
+		return nil, err
+	}
+
+	if cfg.sandbox != nil {
+		sp.Restrict(*cfg.sandbox)
+	}
+
+	return sp, nil
+}
+
+func loadCore(sp *Spirit, path string) error {
+	if path == "" {
+		_, err := sp.ReadEval(&namedReader{
+			Reader: strings.NewReader(lib.Core),
+			name:   lib.CoreFile,
+		})
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = sp.ReadEval(f)
+	return err
+}
+
+// namedReader reports a file name to the Reader for positional information.
+type namedReader struct {
+	*strings.Reader
+	name string
+}
+
+func (nr *namedReader) Name() string { return nr.name }
+
+// NewReader returns a reader which reads forms from r.
+func NewReader(r io.Reader) *Reader {
+	return internal.NewReader(r)
+}
+
+// NewScope returns a scope with no bindings whose lookups fall back to
+// parent.
+func NewScope(parent Scope) Scope {
+	return internal.NewScope(parent)
+}
+
+// Eval evaluates the form against the scope.
+func Eval(scope Scope, form Value) (Value, error) {
+	return internal.Eval(scope, form)
+}
+
+// EvalContext is like Eval but stops the evaluation with the context error
+// once ctx is cancelled or its deadline is exceeded.
+func EvalContext(ctx context.Context, scope Scope, form Value) (Value, error) {
+	return internal.EvalContext(ctx, scope, form)
+}
+
+// ReadEvalContext is like ReadEval but stops the evaluation once ctx is
+// done.
+func ReadEvalContext(ctx context.Context, scope Scope, r io.Reader) (Value, error) {
+	return internal.ReadEvalContext(ctx, scope, r)
+}
+
+// ReadEval reads all forms from r and evaluates them against the scope.
+// The result of the last form is returned.
+func ReadEval(scope Scope, r io.Reader) (Value, error) {
+	return internal.ReadEval(scope, r)
+}
+
+// ReadEvalStr is like ReadEval but reads the forms from src.
+func ReadEvalStr(scope Scope, src string) (Value, error) {
+	return internal.ReadEval(scope, strings.NewReader(src))
+}
+
+// RootScope returns the top most scope of the given scope.
+func RootScope(scope Scope) Scope {
+	return internal.RootScope(scope)
+}
+
+// ClearStack pops every call from the stack.
+func ClearStack(stack *Stack) {
+	internal.ClearStack(stack)
+}
+
+// Compare reports whether two values are equal. It is the Go counterpart
+// of the `=` function.
+func Compare(v1, v2 Value) bool {
+	return internal.Compare(v1, v2)
+}
+
+// ValueOf converts a Go value to a spirit Value. Functions are wrapped so
+// they can be invoked from spirit. See ToGo() for the reverse conversion.
+func ValueOf(v interface{}) Value {
+	return internal.ValueOf(v)
+}
+
+// ToGo converts a spirit Value to a Go value.
+func ToGo(v Value) interface{} {
+	return internal.ToGo(v)
+}
+
+// NewSymbol returns a symbol with the given name.
+func NewSymbol(name string) Symbol {
+	return Symbol{Value: name}
+}
+
+// NewList returns a list containing vals.
+func NewList(vals ...Value) *List {
+	return &List{Values: vals}
+}
+
+// NewVector returns a vector containing vals.
+func NewVector(vals ...Value) *Vector {
+	return internal.NewVector().Conj(vals...).(*Vector)
+}
+
+// NewSet returns a set containing the unique values of vals.
+func NewSet(vals ...Value) *Set {
+	return internal.NewSet().Conj(vals...).(*Set)
+}
+
+// NewHashMap returns a hash map from alternating keys and values. The last
+// key is ignored if it has no value.
+func NewHashMap(kvs ...Value) *HashMap {
+	hm := internal.NewHashMap()
+	for i := 0; i+1 < len(kvs); i += 2 {
+		hm = hm.Set(kvs[i], kvs[i+1]).(*HashMap)
+	}
+	return hm
+}
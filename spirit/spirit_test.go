@@ -0,0 +1,175 @@
+package spirit_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/issadarkthing/spirit/spirit"
+)
+
+const corePath = "../lib/core.st"
+
+func TestNewSpirit(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name    string
+		opts    []spirit.Option
+		src     string
+		want    spirit.Value
+		wantErr bool
+	}{
+		{
+			name: "BundledCore",
+			src:  "(map inc [1 2])",
+			want: spirit.NewVector(spirit.Number(2), spirit.Number(3)),
+		},
+		{
+			name: "WithCore",
+			opts: []spirit.Option{spirit.WithCorePath(corePath)},
+			src:  "(inc 1)",
+			want: spirit.Number(2),
+		},
+		{
+			name:    "WithoutCore",
+			opts:    []spirit.Option{spirit.WithoutCore()},
+			src:     "(inc 1)",
+			wantErr: true,
+		},
+		{
+			name: "BuiltinWithoutCore",
+			opts: []spirit.Option{spirit.WithoutCore()},
+			src:  "(+ 1 2)",
+			want: spirit.Number(3),
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			sp, err := spirit.NewSpirit(tt.opts...)
+			if err != nil {
+				t.Fatalf("NewSpirit() unexpected error: %v", err)
+			}
+
+			if ns := sp.CurrentNS(); ns != spirit.DefaultNS {
+				t.Errorf("CurrentNS() got = %s, want %s", ns, spirit.DefaultNS)
+			}
+
+			got, err := sp.ReadEvalStr(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadEvalStr() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !spirit.Compare(got, tt.want) {
+				t.Errorf("ReadEvalStr() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSpirit_Sandbox(t *testing.T) {
+	sp, err := spirit.NewSpirit(spirit.WithSandbox(spirit.Sandbox{
+		Capabilities: spirit.CapTime,
+		MaxSteps:     100000,
+	}))
+	if err != nil {
+		t.Fatalf("NewSpirit() unexpected error: %v", err)
+	}
+
+	if _, err := sp.ReadEvalStr(`(map inc [1 2 3])`); err != nil {
+		t.Errorf("ReadEvalStr() unexpected error: %v", err)
+	}
+
+	_, err = sp.ReadEvalStr(`($- "ls")`)
+	if !errors.As(err, &spirit.PermissionError{}) {
+		t.Errorf("ReadEvalStr() error = %v, want PermissionError", err)
+	}
+}
+
+func TestNewSpirit_MissingCore(t *testing.T) {
+	_, err := spirit.NewSpirit(spirit.WithCorePath("./does-not-exist.st"))
+	if err == nil {
+		t.Errorf("NewSpirit() expected error for missing core library")
+	}
+}
+
+func TestSpirit_BindGo(t *testing.T) {
+	sp, err := spirit.NewSpirit(spirit.WithoutCore())
+	if err != nil {
+		t.Fatalf("NewSpirit() unexpected error: %v", err)
+	}
+
+	if err := sp.BindGo("double", func(n int) int { return n * 2 }); err != nil {
+		t.Fatalf("BindGo() unexpected error: %v", err)
+	}
+
+	got, err := sp.ReadEvalStr("(double 21)")
+	if err != nil {
+		t.Fatalf("ReadEvalStr() unexpected error: %v", err)
+	}
+
+	if spirit.ToGo(got) != int64(42) {
+		t.Errorf("ReadEvalStr() got = %v, want 42", got)
+	}
+
+	fn, err := sp.Resolve("double")
+	if err != nil {
+		t.Fatalf("Resolve() unexpected error: %v", err)
+	}
+
+	if _, ok := fn.(spirit.Invokable); !ok {
+		t.Errorf("Resolve() got = %T, want Invokable", fn)
+	}
+}
+
+func TestToGo(t *testing.T) {
+	t.Parallel()
+
+	table := []struct {
+		name string
+		v    spirit.Value
+		want interface{}
+	}{
+		{
+			name: "Nil",
+			v:    spirit.Nil{},
+			want: nil,
+		},
+		{
+			name: "Number",
+			v:    spirit.Number(1.5),
+			want: 1.5,
+		},
+		{
+			name: "Keyword",
+			v:    spirit.Keyword("name"),
+			want: "name",
+		},
+		{
+			name: "Vector",
+			v:    spirit.NewVector(spirit.Number(1), spirit.String("a")),
+			want: []interface{}{float64(1), "a"},
+		},
+		{
+			name: "EmptyList",
+			v:    spirit.NewList(),
+			want: []interface{}{},
+		},
+		{
+			name: "HashMap",
+			v:    spirit.NewHashMap(spirit.Keyword("a"), spirit.Bool(true)),
+			want: map[interface{}]interface{}{"a": true},
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spirit.ToGo(tt.v)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToGo() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}